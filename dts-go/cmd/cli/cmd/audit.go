@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	jobv1 "github.com/nedson202/dts-go/proto/job/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Read the audit log of job changes",
+}
+
+var listAuditEventsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List audit events, newest first",
+	Run: func(cmd *cobra.Command, args []string) {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		jobID, _ := cmd.Flags().GetString("job-id")
+		actor, _ := cmd.Flags().GetString("actor")
+		since, _ := cmd.Flags().GetDuration("since")
+		until, _ := cmd.Flags().GetString("until")
+		pageSize, _ := cmd.Flags().GetInt32("page-size")
+		pageToken, _ := cmd.Flags().GetString("page-token")
+
+		req := &jobv1.ListAuditEventsRequest{
+			Namespace: namespace,
+			JobId:     jobID,
+			Actor:     actor,
+			PageSize:  pageSize,
+			PageToken: pageToken,
+		}
+		end := time.Now()
+		if until != "" {
+			var err error
+			if end, err = time.Parse(time.RFC3339, until); err != nil {
+				logger.Fatal().Err(err).Msg("Failed to parse --until")
+			}
+			req.EndTime = timestamppb.New(end)
+		}
+		if since > 0 {
+			req.StartTime = timestamppb.New(end.Add(-since))
+		}
+
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect")
+		}
+		defer conn.Close()
+
+		client := jobv1.NewJobServiceClient(conn)
+
+		resp, err := client.ListAuditEvents(context.Background(), req)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to list audit events")
+		}
+
+		for _, event := range resp.Events {
+			action := strings.TrimPrefix(event.Action.String(), "AUDIT_ACTION_")
+			fmt.Printf("%s %s job %s by %s (request %s)\n", event.Timestamp.AsTime().Format(time.RFC3339), action, event.JobId, event.Actor, event.RequestId)
+			for _, change := range event.Changes {
+				fmt.Printf("  %s: %q -> %q\n", change.Field, change.Before, change.After)
+			}
+		}
+		if resp.NextPageToken != "" {
+			fmt.Printf("Next page token: %s\n", resp.NextPageToken)
+		}
+	},
+}
+
+func init() {
+	auditCmd.AddCommand(listAuditEventsCmd)
+
+	listAuditEventsCmd.Flags().String("namespace", "", "Namespace to list events of (default \"default\")")
+	listAuditEventsCmd.Flags().String("job-id", "", "Only list events of this job")
+	listAuditEventsCmd.Flags().String("actor", "", "Only list events caused by this subject")
+	listAuditEventsCmd.Flags().Duration("since", 0, "Only list events this long before --until (default 720h)")
+	listAuditEventsCmd.Flags().String("until", "", "Only list events up to this RFC 3339 time (default now)")
+	listAuditEventsCmd.Flags().Int32("page-size", 50, "Page size (1-250)")
+	listAuditEventsCmd.Flags().String("page-token", "", "Page token returned by a previous call")
+}
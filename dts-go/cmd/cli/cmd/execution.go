@@ -1,7 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	executionv1 "github.com/nedson202/dts-go/proto/execution/v1"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 )
 
 var executionCmd = &cobra.Command{
@@ -10,4 +17,48 @@ var executionCmd = &cobra.Command{
 	Long:  `Execute jobs and retrieve execution status using the Execution service.`,
 }
 
-// Add subcommands for execution operations here
+var executionLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Print the logs of an execution",
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+		follow, _ := cmd.Flags().GetBool("follow")
+		namespace, _ := cmd.Flags().GetString("namespace")
+
+		conn, err := grpc.Dial("localhost:50053", dialOptions()...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect")
+		}
+		defer conn.Close()
+
+		client := executionv1.NewExecutionServiceClient(conn)
+
+		stream, err := client.StreamExecutionLogs(context.Background(), &executionv1.StreamExecutionLogsRequest{
+			Id:        id,
+			Follow:    follow,
+			Namespace: namespace,
+		})
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to stream execution logs")
+		}
+
+		for {
+			line, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				logger.Fatal().Err(err).Msg("Failed to stream execution logs")
+			}
+			fmt.Printf("%s [%s] %s\n", line.Timestamp.AsTime().Format("2006-01-02T15:04:05.000Z07:00"), line.Stream, line.Line)
+		}
+	},
+}
+
+func init() {
+	executionCmd.AddCommand(executionLogsCmd)
+
+	executionLogsCmd.Flags().String("id", "", "ID of the execution")
+	executionLogsCmd.Flags().String("namespace", "", "Namespace of the execution (default \"default\")")
+	executionLogsCmd.Flags().BoolP("follow", "f", false, "Follow the log output until the execution finishes")
+}
@@ -26,6 +26,8 @@ var createJobCmd = &cobra.Command{
 		description, _ := cmd.Flags().GetString("description")
 		cronExpression, _ := cmd.Flags().GetString("cron")
 		metadata, _ := cmd.Flags().GetString("metadata")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		priority, _ := cmd.Flags().GetInt32("priority")
 
 		metadataMap := make(map[string]string)
 		if metadata != "" {
@@ -34,7 +36,7 @@ var createJobCmd = &cobra.Command{
 			}
 		}
 
-		conn, err := grpc.Dial("localhost:50054", grpc.WithInsecure())
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
 		if err != nil {
 			logger.Fatal().Err(err).Msg("Failed to connect")
 		}
@@ -47,6 +49,9 @@ var createJobCmd = &cobra.Command{
 			Description:    description,
 			CronExpression: cronExpression,
 			Metadata:       metadataMap,
+			Priority:       priority,
+			Namespace:      namespace,
+			Resources:      resourcesFromFlags(cmd),
 		})
 
 		if err != nil {
@@ -62,8 +67,9 @@ var getJobCmd = &cobra.Command{
 	Short: "Get a job by ID",
 	Run: func(cmd *cobra.Command, args []string) {
 		id, _ := cmd.Flags().GetString("id")
+		namespace, _ := cmd.Flags().GetString("namespace")
 
-		conn, err := grpc.Dial("localhost:50054", grpc.WithInsecure())
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
 		if err != nil {
 			logger.Fatal().Err(err).Msg("Failed to connect")
 		}
@@ -72,7 +78,8 @@ var getJobCmd = &cobra.Command{
 		client := jobv1.NewJobServiceClient(conn)
 
 		resp, err := client.GetJob(context.Background(), &jobv1.GetJobRequest{
-			Id: id,
+			Id:        id,
+			Namespace: namespace,
 		})
 
 		if err != nil {
@@ -93,8 +100,9 @@ var listJobsCmd = &cobra.Command{
 		}
 		status, _ := cmd.Flags().GetString("status")
 		lastID, _ := cmd.Flags().GetString("last-id")
+		namespace, _ := cmd.Flags().GetString("namespace")
 
-		conn, err := grpc.Dial("localhost:50054", grpc.WithInsecure())
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
 		if err != nil {
 			logger.Fatal().Err(err).Msg("Failed to connect")
 		}
@@ -103,9 +111,10 @@ var listJobsCmd = &cobra.Command{
 		client := jobv1.NewJobServiceClient(conn)
 
 		resp, err := client.ListJobs(context.Background(), &jobv1.ListJobsRequest{
-			PageSize: pageSize,
-			Status:   status,
-			LastId:   lastID,
+			PageSize:  pageSize,
+			Status:    status,
+			LastId:    lastID,
+			Namespace: namespace,
 		})
 
 		if err != nil {
@@ -131,6 +140,8 @@ var updateJobCmd = &cobra.Command{
 		cronExpression, _ := cmd.Flags().GetString("cron")
 		status, _ := cmd.Flags().GetString("status")
 		metadata, _ := cmd.Flags().GetString("metadata")
+		priority, _ := cmd.Flags().GetInt32("priority")
+		namespace, _ := cmd.Flags().GetString("namespace")
 
 		metadataMap := make(map[string]string)
 		if metadata != "" {
@@ -139,7 +150,7 @@ var updateJobCmd = &cobra.Command{
 			}
 		}
 
-		conn, err := grpc.Dial("localhost:50054", grpc.WithInsecure())
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
 		if err != nil {
 			logger.Fatal().Err(err).Msg("Failed to connect")
 		}
@@ -154,6 +165,9 @@ var updateJobCmd = &cobra.Command{
 			CronExpression: cronExpression,
 			Status:         jobv1.JobStatus(jobv1.JobStatus_value[status]),
 			Metadata:       metadataMap,
+			Priority:       priority,
+			Resources:      resourcesFromFlags(cmd),
+			Namespace:      namespace,
 		})
 
 		if err != nil {
@@ -169,8 +183,9 @@ var deleteJobCmd = &cobra.Command{
 	Short: "Delete a job",
 	Run: func(cmd *cobra.Command, args []string) {
 		id, _ := cmd.Flags().GetString("id")
+		namespace, _ := cmd.Flags().GetString("namespace")
 
-		conn, err := grpc.Dial("localhost:50054", grpc.WithInsecure())
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
 		if err != nil {
 			logger.Fatal().Err(err).Msg("Failed to connect")
 		}
@@ -179,7 +194,8 @@ var deleteJobCmd = &cobra.Command{
 		client := jobv1.NewJobServiceClient(conn)
 
 		resp, err := client.DeleteJob(context.Background(), &jobv1.DeleteJobRequest{
-			Id: id,
+			Id:        id,
+			Namespace: namespace,
 		})
 
 		if err != nil {
@@ -190,23 +206,57 @@ var deleteJobCmd = &cobra.Command{
 	},
 }
 
+var triggerJobCmd = &cobra.Command{
+	Use:   "trigger",
+	Short: "Run a job now, outside its schedule",
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+		namespace, _ := cmd.Flags().GetString("namespace")
+
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect")
+		}
+		defer conn.Close()
+
+		client := jobv1.NewJobServiceClient(conn)
+
+		resp, err := client.TriggerJob(context.Background(), &jobv1.TriggerJobRequest{
+			Id:        id,
+			Namespace: namespace,
+		})
+
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to trigger job")
+		}
+
+		fmt.Println(resp.Message)
+	},
+}
+
 func init() {
 	jobCmd.AddCommand(createJobCmd)
 	jobCmd.AddCommand(getJobCmd)
 	jobCmd.AddCommand(listJobsCmd)
 	jobCmd.AddCommand(updateJobCmd)
 	jobCmd.AddCommand(deleteJobCmd)
+	jobCmd.AddCommand(triggerJobCmd)
 
 	createJobCmd.Flags().String("name", "", "Name of the job")
 	createJobCmd.Flags().String("description", "", "Description of the job")
 	createJobCmd.Flags().String("cron", "", "Cron expression for the job")
 	createJobCmd.Flags().String("metadata", "", "Metadata for the job (JSON format)")
+	createJobCmd.Flags().String("namespace", "", "Namespace of the job (default \"default\")")
+	createJobCmd.Flags().Int32("priority", 0, "Priority of the job (5 or higher is dispatched on the high priority lane, negative on the low one)")
+	addResourceFlags(createJobCmd)
 
 	getJobCmd.Flags().String("id", "", "ID of the job")
+	getJobCmd.Flags().String("namespace", "", "Namespace of the job (default \"default\")")
 
 	listJobsCmd.Flags().Int32("page-size", 250, "Page size (1-250, default 250)")
 	listJobsCmd.Flags().String("status", "", "Status filter")
 	listJobsCmd.Flags().String("last-id", "", "Last ID for pagination")
+	listJobsCmd.Flags().String("namespace", "", "Namespace to list jobs from (default \"default\")")
 
 	updateJobCmd.Flags().String("id", "", "ID of the job")
 	updateJobCmd.Flags().String("name", "", "Name of the job")
@@ -214,8 +264,33 @@ func init() {
 	updateJobCmd.Flags().String("cron", "", "Cron expression for the job")
 	updateJobCmd.Flags().String("status", "", "Status of the job")
 	updateJobCmd.Flags().String("metadata", "", "Metadata for the job (JSON format)")
+	updateJobCmd.Flags().Int32("priority", 0, "Priority of the job")
+	updateJobCmd.Flags().String("namespace", "", "Namespace of the job (default \"default\")")
+	addResourceFlags(updateJobCmd)
 
 	deleteJobCmd.Flags().String("id", "", "ID of the job")
+	deleteJobCmd.Flags().String("namespace", "", "Namespace of the job (default \"default\")")
+
+	triggerJobCmd.Flags().String("id", "", "ID of the job")
+	triggerJobCmd.Flags().String("namespace", "", "Namespace of the job (default \"default\")")
+}
+
+func addResourceFlags(cmd *cobra.Command) {
+	cmd.Flags().Int32("cpu", 0, "CPU units an execution of the job reserves")
+	cmd.Flags().Int32("memory", 0, "Memory an execution of the job reserves")
+	cmd.Flags().Int32("storage", 0, "Storage an execution of the job reserves")
+}
+
+// resourcesFromFlags returns nil when no resource flag was given, so updates
+// leave the job's requirements unchanged.
+func resourcesFromFlags(cmd *cobra.Command) *jobv1.ResourceRequirements {
+	if !cmd.Flags().Changed("cpu") && !cmd.Flags().Changed("memory") && !cmd.Flags().Changed("storage") {
+		return nil
+	}
+	cpu, _ := cmd.Flags().GetInt32("cpu")
+	memory, _ := cmd.Flags().GetInt32("memory")
+	storage, _ := cmd.Flags().GetInt32("storage")
+	return &jobv1.ResourceRequirements{Cpu: cpu, Memory: memory, Storage: storage}
 }
 
 func printJobResponse(j *jobv1.JobResponse) {
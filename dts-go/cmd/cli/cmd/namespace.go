@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	jobv1 "github.com/nedson202/dts-go/proto/job/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+var namespaceCmd = &cobra.Command{
+	Use:   "namespace",
+	Short: "Manage namespaces",
+	Long:  `Create, list and delete the namespaces jobs and executions are scoped to.`,
+}
+
+var createNamespaceCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a namespace",
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		description, _ := cmd.Flags().GetString("description")
+
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect")
+		}
+		defer conn.Close()
+
+		client := jobv1.NewJobServiceClient(conn)
+
+		resp, err := client.CreateNamespace(context.Background(), &jobv1.CreateNamespaceRequest{
+			Name:        name,
+			Description: description,
+		})
+
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to create namespace")
+		}
+
+		fmt.Printf("Namespace created: %s\n", resp.Name)
+	},
+}
+
+var listNamespacesCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List namespaces",
+	Run: func(cmd *cobra.Command, args []string) {
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect")
+		}
+		defer conn.Close()
+
+		client := jobv1.NewJobServiceClient(conn)
+
+		resp, err := client.ListNamespaces(context.Background(), &jobv1.ListNamespacesRequest{})
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to list namespaces")
+		}
+
+		for _, ns := range resp.Namespaces {
+			fmt.Printf("%s\t%s\n", ns.Name, ns.Description)
+		}
+	},
+}
+
+var deleteNamespaceCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete an empty namespace",
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect")
+		}
+		defer conn.Close()
+
+		client := jobv1.NewJobServiceClient(conn)
+
+		resp, err := client.DeleteNamespace(context.Background(), &jobv1.DeleteNamespaceRequest{
+			Name: name,
+		})
+
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to delete namespace")
+		}
+
+		fmt.Printf("Namespace deleted: %v\n", resp.Success)
+	},
+}
+
+func init() {
+	namespaceCmd.AddCommand(createNamespaceCmd)
+	namespaceCmd.AddCommand(listNamespacesCmd)
+	namespaceCmd.AddCommand(deleteNamespaceCmd)
+
+	createNamespaceCmd.Flags().String("name", "", "Name of the namespace")
+	createNamespaceCmd.Flags().String("description", "", "Description of the namespace")
+
+	deleteNamespaceCmd.Flags().String("name", "", "Name of the namespace")
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	jobv1 "github.com/nedson202/dts-go/proto/job/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+var roleBindingCmd = &cobra.Command{
+	Use:   "rolebinding",
+	Short: "Manage role bindings",
+	Long:  `Grant and revoke the viewer, operator, editor and admin roles of subjects in a namespace.`,
+}
+
+var createRoleBindingCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Grant a subject a role in a namespace",
+	Run: func(cmd *cobra.Command, args []string) {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		subject, _ := cmd.Flags().GetString("subject")
+		role, _ := cmd.Flags().GetString("role")
+
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect")
+		}
+		defer conn.Close()
+
+		client := jobv1.NewJobServiceClient(conn)
+
+		resp, err := client.CreateRoleBinding(context.Background(), &jobv1.CreateRoleBindingRequest{
+			Namespace: namespace,
+			Subject:   subject,
+			Role:      role,
+		})
+
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to create role binding")
+		}
+
+		fmt.Printf("Granted %s the %s role in namespace %s\n", resp.Subject, resp.Role, resp.Namespace)
+	},
+}
+
+var listRoleBindingsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the role bindings of a namespace",
+	Run: func(cmd *cobra.Command, args []string) {
+		namespace, _ := cmd.Flags().GetString("namespace")
+
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect")
+		}
+		defer conn.Close()
+
+		client := jobv1.NewJobServiceClient(conn)
+
+		resp, err := client.ListRoleBindings(context.Background(), &jobv1.ListRoleBindingsRequest{
+			Namespace: namespace,
+		})
+
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to list role bindings")
+		}
+
+		for _, binding := range resp.RoleBindings {
+			fmt.Printf("%s\t%s\n", binding.Subject, binding.Role)
+		}
+	},
+}
+
+var deleteRoleBindingCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Revoke the role of a subject in a namespace",
+	Run: func(cmd *cobra.Command, args []string) {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		subject, _ := cmd.Flags().GetString("subject")
+
+		conn, err := grpc.Dial("localhost:50054", dialOptions()...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect")
+		}
+		defer conn.Close()
+
+		client := jobv1.NewJobServiceClient(conn)
+
+		resp, err := client.DeleteRoleBinding(context.Background(), &jobv1.DeleteRoleBindingRequest{
+			Namespace: namespace,
+			Subject:   subject,
+		})
+
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to delete role binding")
+		}
+
+		fmt.Printf("Role binding deleted: %v\n", resp.Success)
+	},
+}
+
+func init() {
+	roleBindingCmd.AddCommand(createRoleBindingCmd)
+	roleBindingCmd.AddCommand(listRoleBindingsCmd)
+	roleBindingCmd.AddCommand(deleteRoleBindingCmd)
+
+	createRoleBindingCmd.Flags().String("namespace", "", "Namespace the role applies to, or * for all namespaces (default \"default\")")
+	createRoleBindingCmd.Flags().String("subject", "", "Subject of the API key or token to grant the role to")
+	createRoleBindingCmd.Flags().String("role", "", "Role to grant: viewer, operator, editor or admin")
+
+	listRoleBindingsCmd.Flags().String("namespace", "", "Namespace to list role bindings of (default \"default\")")
+
+	deleteRoleBindingCmd.Flags().String("namespace", "", "Namespace of the role binding (default \"default\")")
+	deleteRoleBindingCmd.Flags().String("subject", "", "Subject of the role binding")
+}
@@ -1,7 +1,13 @@
 package cmd
 
 import (
+	"os"
+
+	"github.com/nedson202/dts-go/pkg/auth"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/mtls"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 )
 
 var RootCmd = &cobra.Command{
@@ -10,8 +16,49 @@ var RootCmd = &cobra.Command{
 	Long:  `A command-line interface for testing and interacting with the Job, Scheduler, and Execution services of the Distributed Task Scheduler.`,
 }
 
+var (
+	apiKey        string
+	token         string
+	tlsCertFile   string
+	tlsKeyFile    string
+	tlsCAFile     string
+	tlsServerName string
+)
+
 func init() {
 	RootCmd.AddCommand(jobCmd)
 	RootCmd.AddCommand(schedulerCmd)
 	RootCmd.AddCommand(executionCmd)
+	RootCmd.AddCommand(namespaceCmd)
+	RootCmd.AddCommand(roleBindingCmd)
+	RootCmd.AddCommand(auditCmd)
+
+	RootCmd.PersistentFlags().StringVar(&apiKey, "api-key", os.Getenv("DTS_API_KEY"), "API key to authenticate with (default $DTS_API_KEY)")
+	RootCmd.PersistentFlags().StringVar(&token, "token", os.Getenv("DTS_TOKEN"), "Bearer token to authenticate with (default $DTS_TOKEN)")
+	RootCmd.PersistentFlags().StringVar(&tlsCertFile, "tls-cert", os.Getenv("DTS_TLS_CERT_FILE"), "Client certificate for mTLS (default $DTS_TLS_CERT_FILE)")
+	RootCmd.PersistentFlags().StringVar(&tlsKeyFile, "tls-key", os.Getenv("DTS_TLS_KEY_FILE"), "Key of the client certificate (default $DTS_TLS_KEY_FILE)")
+	RootCmd.PersistentFlags().StringVar(&tlsCAFile, "tls-ca", os.Getenv("DTS_TLS_CA_FILE"), "CA bundle to verify the services with; enables TLS (default $DTS_TLS_CA_FILE)")
+	RootCmd.PersistentFlags().StringVar(&tlsServerName, "tls-server-name", os.Getenv("DTS_TLS_SERVER_NAME"), "Name to verify the services' certificates against (default $DTS_TLS_SERVER_NAME)")
+}
+
+// dialOptions returns the options every command dials the services with.
+func dialOptions() []grpc.DialOption {
+	credentials, err := mtls.New(mtls.Options{
+		CertFile:   tlsCertFile,
+		KeyFile:    tlsKeyFile,
+		CAFile:     tlsCAFile,
+		ServerName: tlsServerName,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load TLS credentials")
+	}
+
+	opts := []grpc.DialOption{credentials.DialOption()}
+	if apiKey != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.NewAPIKeyCredentials(apiKey)))
+	}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.NewBearerCredentials(token)))
+	}
+	return opts
 }
@@ -7,11 +7,18 @@ import (
 	"syscall"
 
 	"github.com/nedson202/dts-go/internal/execution"
+	"github.com/nedson202/dts-go/pkg/auth"
 	"github.com/nedson202/dts-go/pkg/client"
 	"github.com/nedson202/dts-go/pkg/config"
-	"github.com/nedson202/dts-go/pkg/database"
+	"github.com/nedson202/dts-go/pkg/health"
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/mtls"
+	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/rbac"
 	executionServer "github.com/nedson202/dts-go/pkg/services/execution"
+	"github.com/nedson202/dts-go/pkg/store"
+	"github.com/nedson202/dts-go/pkg/tracing"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -22,34 +29,86 @@ func main() {
 		logger.Fatal().Err(err).Msg("Failed to load config")
 	}
 
-	cassandraClient, err := database.NewCassandraClient(cfg.CassandraHosts, cfg.CassandraKeyspace)
+	if err := logger.Setup(logger.Options{Level: cfg.LogLevel, Format: cfg.LogFormat, RedactKeys: cfg.LogRedactKeys}); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg, "execution-service")
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to configure tracing")
+	}
+	defer shutdownTracing(context.Background())
+
+	db, err := store.Open(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to open storage")
+	}
+	defer db.Close()
+
+	credentials, err := mtls.FromConfig(cfg)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to create Cassandra client")
+		logger.Fatal().Err(err).Msg("Failed to load TLS credentials")
 	}
-	defer cassandraClient.Close()
 
-	jobClient, err := client.NewJobClient(cfg.JobServiceAddr)
+	authenticator, err := auth.NewAuthenticator(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to configure authentication")
+	}
+
+	// Role bindings are only enforced for authenticated callers
+	var authorizer *rbac.Authorizer
+	if authenticator != nil {
+		authorizer = rbac.NewAuthorizer(db, cfg.AuthAdminSubjects)
+	}
+
+	jobClientOpts := []grpc.DialOption{credentials.DialOption()}
+	if cfg.AuthServiceAPIKey != "" {
+		jobClientOpts = append(jobClientOpts, grpc.WithPerRPCCredentials(auth.NewAPIKeyCredentials(cfg.AuthServiceAPIKey)))
+	}
+	jobClient, err := client.NewJobClient(cfg.JobServiceAddr, jobClientOpts...)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Failed to create job client")
 	}
 
+	// Messages on the in-memory queue never leave this process
+	if cfg.QueueBackend == queue.BackendMemory {
+		logger.Fatal().Msg("The in-memory queue cannot connect separately running services; use the kafka queue backend")
+	}
+	q, err := queue.Open(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to open queue")
+	}
 
 	service, err := execution.NewService(execution.ServiceConfig{
-		CassandraClient: cassandraClient,
-		JobClient:       jobClient,
-		Brokers:         cfg.KafkaBrokers,
+		Store:     db,
+		JobClient: jobClient,
+		Queue:     q,
+		Config:    cfg,
 	})
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Failed to create execution service")
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Start the task manager
-	if err := service.StartTaskManager(context.Background()); err != nil {
+	if err := service.StartTaskManager(ctx); err != nil {
 		logger.Fatal().Err(err).Msg("Failed to start task manager")
 	}
 
+	// Start the reaper for executions abandoned by dead workers
+	service.StartReaper(ctx)
+
+	// Pick up changes to the worker pool size without a restart
+	service.WatchConfig(ctx, cfg)
+
+	checker := health.NewChecker()
+	checker.AddReadiness(cfg.StorageBackend, db.Ping)
+	checker.AddReadiness(cfg.QueueBackend, service.PingQueue)
+
 	// Create and run server
-	server := executionServer.NewServer(service, cfg.ExecutionServiceGRPCPort, cfg.ExecutionServiceHTTPPort)
+	server := executionServer.NewServer(service, cfg.ExecutionServiceGRPCPort, cfg.ExecutionServiceHTTPPort, authenticator, authorizer, credentials, checker)
 
 	// Start the server in a new goroutine
 	go func() {
@@ -69,5 +128,11 @@ func main() {
 		logger.Error().Err(err).Msg("Error stopping task manager")
 	}
 
+	// Stop the reaper before closing the producer it re-enqueues with
+	cancel()
+	if err := service.Close(); err != nil {
+		logger.Error().Err(err).Msg("Error closing queue producer")
+	}
+
 	logger.Info().Msg("Server exiting")
 }
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+
 	"github.com/nedson202/dts-go/internal/job"
+	"github.com/nedson202/dts-go/pkg/auth"
 	"github.com/nedson202/dts-go/pkg/config"
-	"github.com/nedson202/dts-go/pkg/database"
+	"github.com/nedson202/dts-go/pkg/health"
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/mtls"
+	"github.com/nedson202/dts-go/pkg/rbac"
 	jobServer "github.com/nedson202/dts-go/pkg/services/job"
+	"github.com/nedson202/dts-go/pkg/store"
+	"github.com/nedson202/dts-go/pkg/tracing"
 )
 
 func main() {
@@ -17,24 +24,50 @@ func main() {
 		logger.Fatal().Err(err).Msg("Failed to load config")
 	}
 
+	if err := logger.Setup(logger.Options{Level: cfg.LogLevel, Format: cfg.LogFormat, RedactKeys: cfg.LogRedactKeys}); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg, "job-service")
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to configure tracing")
+	}
+	defer shutdownTracing(context.Background())
+
 	// Use localhost if no Cassandra hosts are provided
 	if len(cfg.CassandraHosts) == 0 {
 		cfg.CassandraHosts = []string{"localhost"}
 		logger.Info().Msg("No Cassandra hosts provided, using localhost")
 	}
 
-	// Initialize Cassandra client
-	cassandraClient, err := database.NewCassandraClient(cfg.CassandraHosts, cfg.CassandraKeyspace)
+	// Open the job and execution storage
+	db, err := store.Open(cfg)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to create Cassandra client")
+		logger.Fatal().Err(err).Msg("Failed to open storage")
 	}
-	defer cassandraClient.Close()
+	defer db.Close()
 
 	// Log Kafka brokers for debugging
 	logger.Info().Msgf("Kafka Brokers: %v", cfg.KafkaBrokers)
 
 	// Create job service
-	jobService := job.NewService(cassandraClient)
+	jobService := job.NewService(db, cfg)
+
+	authenticator, err := auth.NewAuthenticator(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to configure authentication")
+	}
+
+	credentials, err := mtls.FromConfig(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load TLS credentials")
+	}
+
+	// Role bindings are only enforced for authenticated callers
+	var authorizer *rbac.Authorizer
+	if authenticator != nil {
+		authorizer = rbac.NewAuthorizer(db, cfg.AuthAdminSubjects)
+	}
 
 	// Use separate ports for gRPC and HTTP
 	grpcPort := cfg.JobServiceGRPCPort
@@ -42,8 +75,11 @@ func main() {
 
 	logger.Info().Msgf("Starting server on gRPC port %s and HTTP port %s", grpcPort, httpPort)
 
+	checker := health.NewChecker()
+	checker.AddReadiness(cfg.StorageBackend, db.Ping)
+
 	// Create and run server
-	server := jobServer.NewServer(jobService, grpcPort, httpPort)
+	server := jobServer.NewServer(jobService, grpcPort, httpPort, authenticator, authorizer, credentials, checker)
 	if err := server.Run(); err != nil {
 		logger.Fatal().Err(err).Msg("Failed to run server")
 	}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/database"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	executionpb "github.com/nedson202/dts-go/proto/execution/v1"
+)
+
+// dataMigrations rewrite existing rows in ways CQL alone cannot. They are
+// recorded in the migrations table and ordered together with the .cql files.
+var dataMigrations = map[string]func(*database.CassandraClient) error{
+	"010_backfill_execution_status":       backfillExecutionStatus,
+	"012_backfill_execution_query_tables": backfillExecutionQueryTables,
+	"017_backfill_job_namespaces":         backfillJobNamespaces,
+	"020_backfill_execution_namespaces":   backfillExecutionNamespaces,
+	"027_backfill_jobs_by_next_run":       backfillJobsByNextRun,
+}
+
+// legacyExecutionStatuses maps free-form statuses written before the
+// ExecutionStatus enum existed to their enum names.
+var legacyExecutionStatuses = map[string]string{
+	"COMPLETED": executionpb.ExecutionStatus_SUCCEEDED.String(),
+}
+
+func migrateExecutionStatus(status string) string {
+	if migrated, ok := legacyExecutionStatuses[status]; ok {
+		return migrated
+	}
+	return status
+}
+
+func backfillExecutionStatus(client *database.CassandraClient) error {
+	updated := 0
+	iter := client.Session.Query(`SELECT job_id, id, status, start_time, scheduled_time, trigger_source FROM job_executions`).Iter()
+	for {
+		var jobID, id gocql.UUID
+		var status, triggerSource string
+		var startTime, scheduledTime time.Time
+		if !iter.Scan(&jobID, &id, &status, &startTime, &scheduledTime, &triggerSource) {
+			break
+		}
+
+		if scheduledTime.IsZero() {
+			scheduledTime = startTime
+		}
+		if triggerSource == "" {
+			triggerSource = executionpb.TriggerSource_TRIGGER_SOURCE_SCHEDULE.String()
+		}
+
+		err := client.Session.Query(
+			`UPDATE job_executions SET status = ?, scheduled_time = ?, trigger_source = ? WHERE job_id = ? AND id = ?`,
+			migrateExecutionStatus(status), scheduledTime, triggerSource, jobID, id,
+		).Exec()
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		updated++
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	logger.Info().Msgf("Backfilled %d executions", updated)
+
+	iter = client.Session.Query(`SELECT execution_id, attempt, status FROM execution_attempts`).Iter()
+	for {
+		var executionID gocql.UUID
+		var attempt int
+		var status string
+		if !iter.Scan(&executionID, &attempt, &status) {
+			break
+		}
+		if migrateExecutionStatus(status) == status {
+			continue
+		}
+
+		err := client.Session.Query(
+			`UPDATE execution_attempts SET status = ? WHERE execution_id = ? AND attempt = ?`,
+			migrateExecutionStatus(status), executionID, attempt,
+		).Exec()
+		if err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+func backfillExecutionQueryTables(client *database.CassandraClient) error {
+	copied := 0
+	iter := client.Session.Query(`SELECT job_id, id, status FROM job_executions`).Iter()
+	for {
+		var jobID, id gocql.UUID
+		var status string
+		if !iter.Scan(&jobID, &id, &status) {
+			break
+		}
+
+		day := id.Time().UTC().Format("2006-01-02")
+		batch := client.Session.NewBatch(gocql.UnloggedBatch)
+		batch.Query(`INSERT INTO executions_by_day (day, id, job_id) VALUES (?, ?, ?)`, day, id, jobID)
+		batch.Query(`INSERT INTO executions_by_status (status, day, id, job_id) VALUES (?, ?, ?, ?)`, status, day, id, jobID)
+		if err := client.Session.ExecuteBatch(batch); err != nil {
+			iter.Close()
+			return err
+		}
+		copied++
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	logger.Info().Msgf("Copied %d executions into the query tables", copied)
+	return nil
+}
+
+func backfillJobNamespaces(client *database.CassandraClient) error {
+	assigned := 0
+	iter := client.Session.Query(`SELECT id, namespace FROM jobs`).Iter()
+	for {
+		var id gocql.UUID
+		var namespace string
+		if !iter.Scan(&id, &namespace) {
+			break
+		}
+
+		batch := client.Session.NewBatch(gocql.LoggedBatch)
+		if namespace == "" {
+			namespace = models.DefaultNamespace
+			batch.Query(`UPDATE jobs SET namespace = ? WHERE id = ?`, namespace, id)
+			assigned++
+		}
+		batch.Query(`INSERT INTO jobs_by_namespace (namespace, job_id) VALUES (?, ?)`, namespace, id)
+		if err := client.Session.ExecuteBatch(batch); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	logger.Info().Msgf("Assigned %d jobs to the %s namespace", assigned, models.DefaultNamespace)
+	return nil
+}
+
+func backfillExecutionNamespaces(client *database.CassandraClient) error {
+	jobNamespaces := make(map[gocql.UUID]string)
+	namespaceOf := func(jobID gocql.UUID) (string, error) {
+		if namespace, ok := jobNamespaces[jobID]; ok {
+			return namespace, nil
+		}
+		var namespace string
+		err := client.Session.Query(`SELECT namespace FROM jobs WHERE id = ?`, jobID).Scan(&namespace)
+		if err != nil && err != gocql.ErrNotFound {
+			return "", err
+		}
+		if namespace == "" {
+			namespace = models.DefaultNamespace
+		}
+		jobNamespaces[jobID] = namespace
+		return namespace, nil
+	}
+
+	copied := 0
+	iter := client.Session.Query(`SELECT job_id, id, status FROM job_executions`).Iter()
+	for {
+		var jobID, id gocql.UUID
+		var status string
+		if !iter.Scan(&jobID, &id, &status) {
+			break
+		}
+
+		namespace, err := namespaceOf(jobID)
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		day := id.Time().UTC().Format("2006-01-02")
+		batch := client.Session.NewBatch(gocql.UnloggedBatch)
+		batch.Query(`UPDATE job_executions SET namespace = ? WHERE job_id = ? AND id = ?`, namespace, jobID, id)
+		batch.Query(`INSERT INTO executions_by_namespace_day (namespace, day, id, job_id) VALUES (?, ?, ?, ?)`, namespace, day, id, jobID)
+		batch.Query(`INSERT INTO executions_by_namespace_status (namespace, status, day, id, job_id) VALUES (?, ?, ?, ?, ?)`, namespace, status, day, id, jobID)
+		if err := client.Session.ExecuteBatch(batch); err != nil {
+			iter.Close()
+			return err
+		}
+		copied++
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	logger.Info().Msgf("Copied %d executions into the namespace query tables", copied)
+	return nil
+}
+
+func backfillJobsByNextRun(client *database.CassandraClient) error {
+	indexed := 0
+	iter := client.Session.Query(`SELECT id, priority, next_run FROM jobs`).Iter()
+	for {
+		var id gocql.UUID
+		var priority int
+		var nextRun time.Time
+		if !iter.Scan(&id, &priority, &nextRun) {
+			break
+		}
+		if nextRun.IsZero() {
+			continue
+		}
+
+		job := models.Job{ID: id}
+		batch := client.Session.NewBatch(gocql.UnloggedBatch)
+		batch.Query(`INSERT INTO jobs_by_next_run (priority, shard, next_run, job_id) VALUES (?, ?, ?, ?)`, priority, job.DueJobShard(), nextRun, id)
+		batch.Query(`INSERT INTO job_priorities (id, priority) VALUES ('global', ?)`, priority)
+		if err := client.Session.ExecuteBatch(batch); err != nil {
+			iter.Close()
+			return err
+		}
+		indexed++
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	logger.Info().Msgf("Indexed %d jobs by next run", indexed)
+	return nil
+}
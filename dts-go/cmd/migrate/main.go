@@ -10,6 +10,7 @@ import (
 	"github.com/nedson202/dts-go/pkg/config"
 	"github.com/nedson202/dts-go/pkg/database"
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/store"
 )
 
 func main() {
@@ -20,6 +21,26 @@ func main() {
 		logger.Fatal().Err(err).Msg("Failed to load config")
 	}
 
+	if err := logger.Setup(logger.Options{Level: cfg.LogLevel, Format: cfg.LogFormat, RedactKeys: cfg.LogRedactKeys}); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+
+	switch cfg.StorageBackend {
+	case store.BackendCassandra:
+		migrateCassandra(cfg)
+	case store.BackendPostgres, store.BackendSQLite:
+		if err := migrateSQL(cfg); err != nil {
+			logger.Fatal().Err(err).Msgf("Failed to migrate %s", cfg.StorageBackend)
+		}
+	default:
+		logger.Info().Msgf("The %s storage backend has no migrations", cfg.StorageBackend)
+		return
+	}
+
+	logger.Info().Msg("Migration process completed")
+}
+
+func migrateCassandra(cfg *config.Config) {
 	// Initialize Cassandra client
 	cassandraClient, err := database.NewCassandraClient(cfg.CassandraHosts, cfg.CassandraKeyspace)
 	if err != nil {
@@ -45,6 +66,9 @@ func main() {
 			migrationFiles = append(migrationFiles, file.Name())
 		}
 	}
+	for name := range dataMigrations {
+		migrationFiles = append(migrationFiles, name)
+	}
 
 	// Sort migration files
 	sort.Strings(migrationFiles)
@@ -57,8 +81,6 @@ func main() {
 			continue
 		}
 	}
-
-	logger.Info().Msg("Migration process completed")
 }
 
 func createMigrationsTable(client *database.CassandraClient) error {
@@ -81,6 +103,13 @@ func executeMigration(client *database.CassandraClient, filename string) error {
 		return nil
 	}
 
+	if migrate, ok := dataMigrations[filename]; ok {
+		if err := migrate(client); err != nil {
+			return err
+		}
+		return recordMigration(client, filename)
+	}
+
 	// Read migration file
 	content, err := os.ReadFile(filepath.Join("migrations", filename))
 	if err != nil {
@@ -104,7 +133,10 @@ func executeMigration(client *database.CassandraClient, filename string) error {
 		}
 	}
 
-	// Record migration as applied
+	return recordMigration(client, filename)
+}
+
+func recordMigration(client *database.CassandraClient, filename string) error {
 	if err := client.Session.Query("INSERT INTO migrations (id, applied_at) VALUES (?, ?)", filename, time.Now()).Exec(); err != nil {
 		return err
 	}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+
+	"github.com/nedson202/dts-go/pkg/config"
+	"github.com/nedson202/dts-go/pkg/store"
+)
+
+// migrateSQL brings the schema of a Postgres or SQLite database up to date.
+// The migrations themselves are embedded in the binary; see SQLStore.Migrate.
+// SQLite databases are also migrated whenever a service opens them.
+func migrateSQL(cfg *config.Config) error {
+	var (
+		s   *store.SQLStore
+		err error
+	)
+	if cfg.StorageBackend == store.BackendSQLite {
+		s, err = store.OpenSQLite(cfg.SQLitePath)
+	} else {
+		s, err = store.OpenPostgres(cfg.PostgresDSN)
+	}
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return s.Migrate(context.Background())
+}
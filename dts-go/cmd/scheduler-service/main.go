@@ -5,13 +5,15 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/nedson202/dts-go/pkg/config"
-	"github.com/nedson202/dts-go/pkg/database"
+	"github.com/nedson202/dts-go/pkg/health"
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/mtls"
 	"github.com/nedson202/dts-go/pkg/queue"
 	"github.com/nedson202/dts-go/pkg/services/scheduler"
+	"github.com/nedson202/dts-go/pkg/store"
+	"github.com/nedson202/dts-go/pkg/tracing"
 )
 
 func main() {
@@ -24,30 +26,61 @@ func main() {
 		os.Exit(1)
 	}
 
-	cassandraClient, err := database.NewCassandraClient(cfg.CassandraHosts, cfg.CassandraKeyspace)
+	if err := logger.Setup(logger.Options{Level: cfg.LogLevel, Format: cfg.LogFormat, RedactKeys: cfg.LogRedactKeys}); err != nil {
+		logger.Error().Err(err).Msg("Invalid logging configuration")
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg, "scheduler-service")
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to create Cassandra client")
+		logger.Error().Err(err).Msg("Failed to configure tracing")
 		os.Exit(1)
 	}
-	defer cassandraClient.Close()
+	defer shutdownTracing(context.Background())
 
-	kafkaClient, err := queue.NewKafkaClient(cfg.KafkaBrokers, "scheduler-service", "")
+	db, err := store.Open(cfg)
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to create Kafka client")
+		logger.Error().Err(err).Msg("Failed to open storage")
 		os.Exit(1)
 	}
-	defer kafkaClient.Close()
+	defer db.Close()
 
-	checkInterval := 1 * time.Minute
-	server, err := scheduler.NewServer(cassandraClient, kafkaClient, checkInterval)
+	// Messages on the in-memory queue never leave this process
+	if cfg.QueueBackend == queue.BackendMemory {
+		logger.Error().Msg("The in-memory queue cannot connect separately running services; use the kafka queue backend")
+		os.Exit(1)
+	}
+	q, err := queue.Open(cfg)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to open queue")
+		os.Exit(1)
+	}
+	// The scheduler only publishes, so it needs no consumer group
+	producer, err := q.NewProducer()
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to create scheduler server")
+		logger.Error().Err(err).Msg("Failed to create queue producer")
 		os.Exit(1)
 	}
+	defer producer.Close()
+
+	credentials, err := mtls.FromConfig(cfg)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to load TLS credentials")
+		os.Exit(1)
+	}
+
+	checker := health.NewChecker()
+	checker.AddReadiness(cfg.StorageBackend, db.Ping)
+	checker.AddReadiness(cfg.QueueBackend, producer.Ping)
+
+	server := scheduler.NewServer(db, producer, cfg, credentials, checker)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Pick up changes to the check interval and batch size without a restart
+	server.WatchConfig(ctx, cfg)
+
 	go func() {
 		if err := server.Run(ctx); err != nil {
 			logger.Error().Err(err).Msg("Scheduler stopped unexpectedly")
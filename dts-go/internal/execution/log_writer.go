@@ -0,0 +1,101 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/store"
+)
+
+// ExecutionLogWriter stores the log output of a single execution. Lines are
+// numbered in the order they are written, across all streams and attempts.
+type ExecutionLogWriter struct {
+	// ctx is the context of the attempt the lines are written for.
+	ctx         context.Context
+	executions  store.ExecutionStore
+	executionID gocql.UUID
+	mu          sync.Mutex
+	seq         int64
+}
+
+// NewExecutionLogWriter continues numbering after any lines logged by
+// earlier attempts of the execution.
+func NewExecutionLogWriter(ctx context.Context, executions store.ExecutionStore, executionID gocql.UUID) (*ExecutionLogWriter, error) {
+	lastSeq, err := executions.GetLastExecutionLogSeq(ctx, executionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExecutionLogWriter{
+		ctx:         ctx,
+		executions:  executions,
+		executionID: executionID,
+		seq:         lastSeq,
+	}, nil
+}
+
+// Logf records an executor-emitted line on the system stream. Failures are
+// logged rather than returned so that log capture never fails an execution.
+func (w *ExecutionLogWriter) Logf(format string, args ...interface{}) {
+	if err := w.WriteLine(models.LogStreamSystem, fmt.Sprintf(format, args...)); err != nil {
+		logger.Error().Err(err).Msgf("Error writing log line for execution %s", w.executionID)
+	}
+}
+
+func (w *ExecutionLogWriter) WriteLine(stream, line string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.seq++
+	return w.executions.CreateExecutionLogLine(w.ctx, &models.ExecutionLogLine{
+		ExecutionID: w.executionID,
+		Seq:         w.seq,
+		Stream:      stream,
+		Timestamp:   time.Now(),
+		Line:        line,
+	})
+}
+
+// Stream returns a writer that splits its input into lines on the given
+// stream, e.g. to capture the stdout or stderr of a process. Call Close to
+// flush a trailing line without a newline.
+func (w *ExecutionLogWriter) Stream(stream string) io.WriteCloser {
+	return &streamWriter{logWriter: w, stream: stream}
+}
+
+type streamWriter struct {
+	logWriter *ExecutionLogWriter
+	stream    string
+	buf       []byte
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for {
+		i := bytes.IndexByte(s.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := s.logWriter.WriteLine(s.stream, string(bytes.TrimRight(s.buf[:i], "\r"))); err != nil {
+			return 0, err
+		}
+		s.buf = s.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (s *streamWriter) Close() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	line := string(s.buf)
+	s.buf = nil
+	return s.logWriter.WriteLine(s.stream, line)
+}
@@ -0,0 +1,24 @@
+package execution
+
+import (
+	"github.com/nedson202/dts-go/pkg/metrics"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+var (
+	attemptOutcomes = metrics.NewCounterVec("dts_execution_attempts_total",
+		"Finished execution attempts, by namespace, job and status (SUCCEEDED, FAILED or LOST).", "namespace", "job_id", "status")
+	attemptDuration = metrics.NewHistogramVec("dts_execution_attempt_duration_seconds",
+		"Run time of finished execution attempts, by namespace, job and status.", nil, "namespace", "job_id", "status")
+)
+
+// recordAttemptOutcome counts a finished attempt and, when its start is
+// known, observes how long it ran.
+func recordAttemptOutcome(namespace string, attempt *models.ExecutionAttempt) {
+	namespace = namespaceOrDefault(namespace)
+	jobID := attempt.JobID.String()
+	attemptOutcomes.Inc(namespace, jobID, attempt.Status)
+	if !attempt.StartTime.IsZero() && attempt.EndTime != nil {
+		attemptDuration.Observe(attempt.EndTime.Sub(attempt.StartTime).Seconds(), namespace, jobID, attempt.Status)
+	}
+}
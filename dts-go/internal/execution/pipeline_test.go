@@ -0,0 +1,426 @@
+package execution_test
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/internal/execution"
+	"github.com/nedson202/dts-go/internal/job"
+	"github.com/nedson202/dts-go/internal/scheduler"
+	"github.com/nedson202/dts-go/pkg/client"
+	"github.com/nedson202/dts-go/pkg/config"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/store"
+	pb "github.com/nedson202/dts-go/proto/execution/v1"
+	jobpb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// pipeline runs the job service, the scheduler and the execution service in
+// one process, sharing a MemoryStore and a MemoryQueue.
+type pipeline struct {
+	db          *store.MemoryStore
+	jobs        *job.Service
+	executions  *execution.Service
+	scheduler   *scheduler.Scheduler
+	outboxRelay *scheduler.OutboxRelay
+}
+
+func startPipeline(t *testing.T) *pipeline {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cfg := config.Default()
+	cfg.QueueBackend = queue.BackendMemory
+	cfg.StorageBackend = store.BackendMemory
+	db := store.NewMemoryStore()
+	q := queue.NewMemoryQueue()
+
+	// The execution service reaches the job service over gRPC
+	jobs := job.NewService(db, cfg)
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	jobpb.RegisterJobServiceServer(grpcServer, jobs)
+	go grpcServer.Serve(listener)
+	jobClient, err := client.NewJobClient("passthrough:///job-service",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := execution.NewService(execution.ServiceConfig{
+		Store:     db,
+		JobClient: jobClient,
+		Queue:     q,
+		Config:    cfg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.StartTaskManager(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	producer, err := q.NewProducer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	queueManager := scheduler.NewQueueManager(producer, cfg)
+
+	t.Cleanup(func() {
+		service.StopTaskManager()
+		cancel()
+		service.Close()
+		jobClient.Close()
+		grpcServer.Stop()
+	})
+
+	return &pipeline{
+		db:          db,
+		jobs:        jobs,
+		executions:  service,
+		scheduler:   scheduler.NewScheduler(db, queueManager, cfg),
+		outboxRelay: scheduler.NewOutboxRelay(db, queueManager, cfg.Scheduler.OutboxRelayInterval),
+	}
+}
+
+// createDueJob creates a job in the default namespace whose next run is
+// already due.
+func (p *pipeline) createDueJob(t *testing.T, ctx context.Context, req *jobpb.CreateJobRequest) *models.Job {
+	t.Helper()
+	resp, err := p.jobs.CreateJob(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := gocql.ParseUUID(resp.JobId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.db.SetJobNextRun(ctx, id, time.Now().Add(-2*time.Minute).Truncate(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	created, err := p.db.GetJob(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// waitFor waits until done reports true.
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForExecution waits until the job has an execution with the given
+// status.
+func (p *pipeline) waitForExecution(t *testing.T, ctx context.Context, jobID gocql.UUID, status pb.ExecutionStatus) *models.Execution {
+	t.Helper()
+	var found *models.Execution
+	waitFor(t, "a "+status.String()+" execution", func() bool {
+		executions, _, err := p.db.ListExecutions(ctx, models.DefaultNamespace, 10, "", jobID, "", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, execution := range executions {
+			if execution.Status == status.String() {
+				found = execution
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+func TestPipelineRunsDueJob(t *testing.T) {
+	ctx := context.Background()
+	p := startPipeline(t)
+
+	created := p.createDueJob(t, ctx, &jobpb.CreateJobRequest{
+		Name:           "nightly",
+		CronExpression: "0 3 * * *",
+		Resources:      &jobpb.ResourceRequirements{Cpu: 2, Memory: 512, Storage: 1024},
+	})
+	scheduledTime := created.NextRun
+
+	if err := p.scheduler.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The scheduler advanced the job and took its capacity and quota slot
+	scheduled, err := p.db.GetJob(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled.Status != jobpb.JobStatus_SCHEDULED.String() || !scheduled.NextRun.After(time.Now()) {
+		t.Fatalf("got job status %s and next run %v, want SCHEDULED in the future", scheduled.Status, scheduled.NextRun)
+	}
+	if running, _ := p.db.CountRunningExecutions(ctx, models.DefaultNamespace); running != 1 {
+		t.Fatalf("got %d running executions after dispatch, want 1", running)
+	}
+
+	// Nothing reaches the execution service until the outbox is relayed
+	if relayed := p.outboxRelay.RelayPending(ctx); relayed != 1 {
+		t.Fatalf("relayed %d outbox entries, want 1", relayed)
+	}
+	execution := p.waitForExecution(t, ctx, created.ID, pb.ExecutionStatus_SUCCEEDED)
+
+	if !execution.ScheduledTime.Equal(scheduledTime) || execution.AttemptCount != 1 {
+		t.Fatalf("got execution scheduled at %v with %d attempts, want %v with 1", execution.ScheduledTime, execution.AttemptCount, scheduledTime)
+	}
+	attempts, err := p.db.ListExecutionAttempts(ctx, execution.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 1 || attempts[0].Status != pb.ExecutionStatus_SUCCEEDED.String() {
+		t.Fatalf("got attempts %+v, want one that succeeded", attempts)
+	}
+	lines, err := p.db.ListExecutionLogLines(ctx, execution.ID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) == 0 {
+		t.Fatal("execution wrote no log lines")
+	}
+
+	// The executor reported back to the job service and gives back what the
+	// scheduler took once the execution is recorded
+	completed, err := p.db.GetJob(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed.Status != jobpb.JobStatus_COMPLETED.String() || completed.LastRun == nil {
+		t.Fatalf("got job status %s and last run %v, want COMPLETED with a last run", completed.Status, completed.LastRun)
+	}
+	waitFor(t, "the dispatch to be released", func() bool {
+		running, _ := p.db.CountRunningExecutions(ctx, models.DefaultNamespace)
+		pool, _ := p.db.GetResourcePool(ctx)
+		return running == 0 && len(pool.Reservations) == 0
+	})
+
+	// A second pass finds nothing due and nothing left to relay
+	if err := p.scheduler.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if relayed := p.outboxRelay.RelayPending(ctx); relayed != 0 {
+		t.Fatalf("relayed %d outbox entries on the second pass, want 0", relayed)
+	}
+}
+
+func TestPipelineRetriesFailedRunUntilGivenUp(t *testing.T) {
+	ctx := context.Background()
+	p := startPipeline(t)
+
+	created := p.createDueJob(t, ctx, &jobpb.CreateJobRequest{Name: "doomed", CronExpression: "0 3 * * *"})
+	if err := p.scheduler.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Deleting the job makes reporting the run back to the job service fail
+	if _, err := p.jobs.DeleteJob(ctx, &jobpb.DeleteJobRequest{Id: created.ID.String()}); err != nil {
+		t.Fatal(err)
+	}
+	p.outboxRelay.RelayPending(ctx)
+
+	failed := p.waitForExecution(t, ctx, created.ID, pb.ExecutionStatus_FAILED)
+	waitFor(t, "the dispatch to be released", func() bool {
+		running, _ := p.db.CountRunningExecutions(ctx, models.DefaultNamespace)
+		return running == 0
+	})
+
+	// Every attempt is recorded on the one execution of the run
+	resp, err := p.executions.GetExecution(ctx, &pb.GetExecutionRequest{Id: failed.ID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Attempts) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(resp.Attempts))
+	}
+	for i, attempt := range resp.Attempts {
+		if int(attempt.Attempt) != i+1 || attempt.Status != pb.ExecutionStatus_FAILED || attempt.Error == "" {
+			t.Fatalf("attempt %d is %+v, want a failed attempt %d with its error", i, attempt, i+1)
+		}
+	}
+	if resp.Error == "" || resp.EndTime == nil {
+		t.Fatalf("failed execution has error %q and end time %v", resp.Error, resp.EndTime)
+	}
+
+	// The execution log says why each attempt failed and what happened next
+	lines, err := p.db.ListExecutionLogLines(ctx, failed.ID, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log []string
+	for _, line := range lines {
+		log = append(log, line.Line)
+	}
+	for _, want := range []string{
+		"Attempt 1 of execution " + failed.ID.String() + " failed: " + resp.Attempts[0].Error,
+		"Attempt 2 of 3 will be queued for retry",
+		"Attempt 3 of execution " + failed.ID.String() + " failed: " + resp.Attempts[2].Error,
+		"Giving up after 3 of 3 attempts",
+	} {
+		if !slices.Contains(log, want) {
+			t.Fatalf("execution log %q is missing %q", log, want)
+		}
+	}
+}
+
+// logStream collects the lines sent on a StreamExecutionLogs stream.
+type logStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	mu    sync.Mutex
+	lines []string
+}
+
+func (s *logStream) Context() context.Context { return s.ctx }
+
+func (s *logStream) Send(line *pb.ExecutionLogLine) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, line.Line)
+	return nil
+}
+
+func (s *logStream) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.lines)
+}
+
+func TestFollowExecutionLogsAcrossRetries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	p := startPipeline(t)
+
+	run := &models.Execution{
+		ID:        gocql.TimeUUID(),
+		JobID:     gocql.TimeUUID(),
+		Namespace: models.DefaultNamespace,
+		Status:    pb.ExecutionStatus_RUNNING.String(),
+		StartTime: time.Now(),
+	}
+	if err := p.db.CreateExecution(ctx, run); err != nil {
+		t.Fatal(err)
+	}
+	executionLog, err := execution.NewExecutionLogWriter(ctx, p.db, run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setStatus := func(executionStatus pb.ExecutionStatus) {
+		run.Status = executionStatus.String()
+		if err := p.db.UpdateExecution(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream := &logStream{ctx: ctx}
+	done := make(chan error, 1)
+	go func() {
+		done <- p.executions.StreamExecutionLogs(&pb.StreamExecutionLogsRequest{Id: run.ID.String(), Follow: true}, stream)
+	}()
+
+	executionLog.Logf("first attempt")
+	waitFor(t, "the first line", func() bool { return len(stream.received()) == 1 })
+
+	// Waiting for a retry does not end the stream
+	setStatus(pb.ExecutionStatus_QUEUED)
+	time.Sleep(1500 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("stream ended with %v while the execution was QUEUED", err)
+	default:
+	}
+	setStatus(pb.ExecutionStatus_RUNNING)
+	executionLog.Logf("second attempt")
+	executionLog.Logf("second attempt failed")
+	setStatus(pb.ExecutionStatus_FAILED)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stream.received(), []string{"first attempt", "second attempt", "second attempt failed"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestPipelineRunsTriggeredJob(t *testing.T) {
+	ctx := context.Background()
+	p := startPipeline(t)
+
+	created := p.createDueJob(t, ctx, &jobpb.CreateJobRequest{Name: "adhoc", CronExpression: "0 3 * * *"})
+	if _, err := p.jobs.TriggerJob(ctx, &jobpb.TriggerJobRequest{Id: created.ID.String()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The trigger takes the quota slot up front and leaves the schedule alone
+	if running, _ := p.db.CountRunningExecutions(ctx, models.DefaultNamespace); running != 1 {
+		t.Fatalf("got %d running executions after the trigger, want 1", running)
+	}
+	if relayed := p.outboxRelay.RelayPending(ctx); relayed != 1 {
+		t.Fatalf("relayed %d outbox entries, want 1", relayed)
+	}
+	execution := p.waitForExecution(t, ctx, created.ID, pb.ExecutionStatus_SUCCEEDED)
+	if execution.TriggerSource != pb.TriggerSource_TRIGGER_SOURCE_MANUAL.String() {
+		t.Fatalf("got trigger source %s, want TRIGGER_SOURCE_MANUAL", execution.TriggerSource)
+	}
+	waitFor(t, "the dispatch to be released", func() bool {
+		running, _ := p.db.CountRunningExecutions(ctx, models.DefaultNamespace)
+		return running == 0
+	})
+	job, err := p.db.GetJob(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !job.NextRun.Equal(created.NextRun) {
+		t.Fatalf("got next run %v after the trigger, want it unchanged at %v", job.NextRun, created.NextRun)
+	}
+
+	resp, err := p.jobs.ListAuditEvents(ctx, &jobpb.ListAuditEventsRequest{JobId: created.ID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var triggered int
+	for _, event := range resp.Events {
+		if event.Action == jobpb.AuditAction_AUDIT_ACTION_TRIGGER {
+			triggered++
+		}
+	}
+	if triggered != 1 {
+		t.Fatalf("got %d trigger audit events in %+v, want 1", triggered, resp.Events)
+	}
+}
+
+func TestPipelineRefusesTriggerOfCancelledJob(t *testing.T) {
+	ctx := context.Background()
+	p := startPipeline(t)
+
+	created := p.createDueJob(t, ctx, &jobpb.CreateJobRequest{Name: "stopped", CronExpression: "0 3 * * *"})
+	if _, err := p.jobs.CancelJob(ctx, &jobpb.CancelJobRequest{Id: created.ID.String()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.jobs.TriggerJob(ctx, &jobpb.TriggerJobRequest{Id: created.ID.String()}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("triggering a cancelled job returned %v, want FailedPrecondition", err)
+	}
+	if relayed := p.outboxRelay.RelayPending(ctx); relayed != 0 {
+		t.Fatalf("relayed %d outbox entries, want 0", relayed)
+	}
+}
@@ -0,0 +1,172 @@
+package execution
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/client"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/store"
+)
+
+var _ TaskProcessor = (*PriorityTaskConsumer)(nil)
+
+// PriorityLane is a task topic and the number of messages taken from it per
+// round while every lane has work queued.
+type PriorityLane struct {
+	Topic  string
+	Weight int
+}
+
+type priorityLaneConsumer struct {
+	PriorityLane
+	consumer queue.Consumer
+}
+
+// PriorityTaskConsumer consumes several task topics with weighted
+// round-robin, so a backlog in a low priority lane cannot hold up messages in
+// a higher one. Lanes are visited in the order they are given.
+type PriorityTaskConsumer struct {
+	lanes    []*priorityLaneConsumer
+	executor *TaskExecutor
+	pool     *workerPool
+}
+
+type PriorityTaskConsumerArgs struct {
+	Executions        store.ExecutionStore
+	Queue             queue.Queue
+	GroupID           string
+	JobClient         *client.JobClient
+	Producer          queue.Producer
+	Lanes             []PriorityLane
+	RetryTopic        string
+	MaxRetries        int
+	WorkerID          string
+	HeartbeatInterval time.Duration
+	WorkerPoolSize    int
+}
+
+func NewPriorityTaskConsumer(args PriorityTaskConsumerArgs) (*PriorityTaskConsumer, error) {
+	if len(args.Lanes) == 0 {
+		return nil, fmt.Errorf("at least one priority lane is required")
+	}
+
+	consumer := &PriorityTaskConsumer{pool: newWorkerPool(args.WorkerPoolSize)}
+	for _, lane := range args.Lanes {
+		if lane.Weight < 1 {
+			lane.Weight = 1
+		}
+		laneConsumer, err := args.Queue.NewConsumer(args.GroupID, lane.Topic)
+		if err != nil {
+			consumer.Stop()
+			return nil, err
+		}
+		consumer.lanes = append(consumer.lanes, &priorityLaneConsumer{PriorityLane: lane, consumer: laneConsumer})
+	}
+	consumer.executor = NewTaskExecutor(TaskExecutorArgs{
+		Executions:        args.Executions,
+		JobClient:         args.JobClient,
+		Producer:          args.Producer,
+		RetryTopic:        args.RetryTopic,
+		MaxRetries:        args.MaxRetries,
+		WorkerID:          args.WorkerID,
+		HeartbeatInterval: args.HeartbeatInterval,
+	})
+
+	return consumer, nil
+}
+
+func (pc *PriorityTaskConsumer) Start(topic string) error {
+	for _, lane := range pc.lanes {
+		logger.Info().Msgf("Starting PriorityTaskConsumer for topic: %s (weight %d)", lane.Topic, lane.Weight)
+		if err := lane.consumer.Consume(); err != nil {
+			return err
+		}
+	}
+
+	go pc.run()
+	return nil
+}
+
+func (pc *PriorityTaskConsumer) run() {
+	for {
+		handled, ok := pc.round()
+		if !ok {
+			return
+		}
+		if handled > 0 {
+			continue
+		}
+
+		// Every lane is empty; wait for the next message on any of them.
+		message, ok := pc.wait()
+		if !ok {
+			return
+		}
+		pc.execute(message)
+	}
+}
+
+// round takes up to Weight queued messages from each lane in turn without
+// blocking. It reports false once a lane has been closed.
+func (pc *PriorityTaskConsumer) round() (int, bool) {
+	handled := 0
+	for _, lane := range pc.lanes {
+		for i := 0; i < lane.Weight; i++ {
+			select {
+			case message, ok := <-lane.consumer.Messages():
+				if !ok {
+					return handled, false
+				}
+				pc.execute(message)
+				handled++
+				continue
+			default:
+			}
+			break
+		}
+	}
+	return handled, true
+}
+
+func (pc *PriorityTaskConsumer) wait() (*queue.Message, bool) {
+	cases := make([]reflect.SelectCase, len(pc.lanes))
+	for i, lane := range pc.lanes {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(lane.consumer.Messages())}
+	}
+	_, value, ok := reflect.Select(cases)
+	if !ok {
+		return nil, false
+	}
+	return value.Interface().(*queue.Message), true
+}
+
+// execute hands message to the worker pool, waiting for a free worker so that
+// lanes are not drained faster than their tasks can run.
+func (pc *PriorityTaskConsumer) execute(message *queue.Message) {
+	pc.pool.Go(func() {
+		if err := pc.executor.executeTask(message); err != nil {
+			logger.Error().Msgf("Error executing task: %v", err)
+		}
+	})
+}
+
+func (pc *PriorityTaskConsumer) Stop() error {
+	logger.Info().Msgf("Stopping PriorityTaskConsumer")
+	var errs []error
+	for _, lane := range pc.lanes {
+		if err := lane.consumer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close consumer for topic %s: %w", lane.Topic, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred while stopping priority lanes: %v", errs)
+	}
+	return nil
+}
+
+func (pc *PriorityTaskConsumer) SetWorkerPoolSize(size int) {
+	pc.pool.Resize(size)
+}
@@ -0,0 +1,182 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/store"
+	pb "github.com/nedson202/dts-go/proto/execution/v1"
+)
+
+// Reaper finds executions whose worker stopped sending heartbeats, marks the
+// attempt as LOST and re-enqueues the execution on the retry topic.
+type Reaper struct {
+	jobs             store.JobStore
+	executions       store.ExecutionStore
+	producer         queue.Producer
+	retryTopic       string
+	interval         time.Duration
+	heartbeatTimeout time.Duration
+	maxRetries       int
+}
+
+type ReaperArgs struct {
+	Store            store.Store
+	Producer         queue.Producer
+	RetryTopic       string
+	Interval         time.Duration
+	HeartbeatTimeout time.Duration
+	MaxRetries       int
+}
+
+func NewReaper(args ReaperArgs) *Reaper {
+	return &Reaper{
+		jobs:             args.Store,
+		executions:       args.Store,
+		producer:         args.Producer,
+		retryTopic:       args.RetryTopic,
+		interval:         args.Interval,
+		heartbeatTimeout: args.HeartbeatTimeout,
+		maxRetries:       args.MaxRetries,
+	}
+}
+
+func (r *Reaper) Start(ctx context.Context) {
+	logger.Info().Msgf("Starting execution reaper with interval %v and heartbeat timeout %v", r.interval, r.heartbeatTimeout)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("Execution reaper stopped due to context cancellation")
+			return
+		case <-ticker.C:
+			if err := r.ReapStaleExecutions(ctx); err != nil {
+				logger.Error().Err(err).Msg("Error reaping stale executions")
+			}
+		}
+	}
+}
+
+func (r *Reaper) ReapStaleExecutions(ctx context.Context) error {
+	heartbeats, err := r.executions.ListHeartbeats(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing execution heartbeats: %w", err)
+	}
+
+	deadline := time.Now().Add(-r.heartbeatTimeout)
+	reaped := 0
+	for _, heartbeat := range heartbeats {
+		if heartbeat.HeartbeatAt.After(deadline) {
+			continue
+		}
+		if err := r.reap(ctx, heartbeat); err != nil {
+			logger.Error().Err(err).Msgf("Error reaping execution %s", heartbeat.ExecutionID)
+			continue
+		}
+		reaped++
+	}
+
+	if reaped > 0 {
+		logger.Info().Msgf("Reaped %d lost executions", reaped)
+	}
+	return nil
+}
+
+func (r *Reaper) reap(ctx context.Context, heartbeat *models.ExecutionHeartbeat) error {
+	claimed, err := r.executions.ClaimStaleHeartbeat(ctx, heartbeat)
+	if err != nil {
+		return fmt.Errorf("error claiming heartbeat: %w", err)
+	}
+	if !claimed {
+		// The worker sent a heartbeat in the meantime, or another reaper got here first.
+		return nil
+	}
+
+	logger.Warn().Msgf("Execution %s of job %s lost its heartbeat from worker %s at %v", heartbeat.ExecutionID, heartbeat.JobID, heartbeat.WorkerID, heartbeat.HeartbeatAt)
+
+	now := time.Now()
+	lostErr := fmt.Sprintf("no heartbeat from worker %s since %s", heartbeat.WorkerID, heartbeat.HeartbeatAt.Format(time.RFC3339))
+
+	attempt := &models.ExecutionAttempt{
+		ExecutionID: heartbeat.ExecutionID,
+		Attempt:     heartbeat.RetryCount + 1,
+		Status:      pb.ExecutionStatus_LOST.String(),
+		EndTime:     &now,
+		Error:       lostErr,
+	}
+	if err := r.executions.UpdateExecutionAttempt(ctx, attempt); err != nil {
+		return fmt.Errorf("error marking attempt as lost: %w", err)
+	}
+
+	execution, err := r.executions.GetJobExecution(ctx, heartbeat.JobID, heartbeat.ExecutionID)
+	if err != nil {
+		return fmt.Errorf("error retrieving execution: %w", err)
+	}
+	attempt.JobID = heartbeat.JobID
+	recordAttemptOutcome(execution.Namespace, attempt)
+	willRetry := heartbeat.RetryCount+1 < r.maxRetries
+	execution.Status = pb.ExecutionStatus_LOST.String()
+	execution.EndTime = &now
+	execution.Error = lostErr
+	if willRetry {
+		execution.Status = pb.ExecutionStatus_QUEUED.String()
+		execution.EndTime = nil
+	}
+	if err := r.executions.UpdateExecution(ctx, execution); err != nil {
+		return fmt.Errorf("error marking execution as lost: %w", err)
+	}
+	if executionLog, err := NewExecutionLogWriter(ctx, r.executions, execution.ID); err != nil {
+		logger.Error().Err(err).Msgf("Error opening log for execution %s", execution.ID)
+	} else {
+		executionLog.Logf("Attempt %d of execution %s was lost: %s", attempt.Attempt, execution.ID, lostErr)
+	}
+
+	if !willRetry {
+		logger.Info().Msgf("Max retries reached for idempotency key %s. Not re-enqueueing lost execution %s", heartbeat.IdempotencyKey, heartbeat.ExecutionID)
+		if err := r.executions.ReleaseResources(ctx, heartbeat.IdempotencyKey); err != nil {
+			return fmt.Errorf("error releasing resources: %w", err)
+		}
+		if err := r.executions.ReleaseDispatch(ctx, execution.Namespace, heartbeat.IdempotencyKey); err != nil {
+			return fmt.Errorf("error releasing dispatch: %w", err)
+		}
+		return nil
+	}
+
+	// The retry carries everything the scheduler put in the original
+	// message, so that it runs and is released in the right namespace
+	scheduledJob := ScheduledJob{
+		IdempotencyKey: heartbeat.IdempotencyKey,
+		JobID:          heartbeat.JobID.String(),
+		StartTime:      now,
+		RetryCount:     heartbeat.RetryCount + 1,
+		ScheduledTime:  execution.ScheduledTime,
+		Namespace:      execution.Namespace,
+		ExecutionID:    heartbeat.ExecutionID.String(),
+		TriggerSource:  execution.TriggerSource,
+	}
+	if job, err := r.jobs.GetJob(ctx, heartbeat.JobID); err == nil {
+		scheduledJob.Priority = job.Priority
+	} else {
+		logger.Warn().Err(err).Msgf("Could not look up job %s for the priority of its retry", heartbeat.JobID)
+	}
+	jobJSON, err := json.Marshal(scheduledJob)
+	if err != nil {
+		return fmt.Errorf("error marshaling job for retry: %w", err)
+	}
+
+	produceCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := r.producer.Produce(produceCtx, r.retryTopic, []byte(scheduledJob.IdempotencyKey), jobJSON); err != nil {
+		return fmt.Errorf("failed to publish retry message: %w", err)
+	}
+
+	logger.Info().Msgf("Re-enqueued lost execution %s of job %s (retry %d)", heartbeat.ExecutionID, heartbeat.JobID, scheduledJob.RetryCount)
+	return nil
+}
@@ -2,64 +2,116 @@ package execution
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/gocql/gocql"
 	"github.com/nedson202/dts-go/pkg/client"
 	"github.com/nedson202/dts-go/pkg/config"
-	"github.com/nedson202/dts-go/pkg/database"
 	"github.com/nedson202/dts-go/pkg/logger"
 	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/store"
 	pb "github.com/nedson202/dts-go/proto/execution/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+const (
+	logPageSize     = 500
+	logPollInterval = time.Second
+)
+
+// ReloadableSettings are the settings Reconfigure applies to a running
+// service.
+var ReloadableSettings = []string{"execution.worker_pool_size"}
+
 type Service struct {
 	pb.UnimplementedExecutionServiceServer
-	cassandraClient *database.CassandraClient
-	taskManager     *TaskManager
+	jobs        store.JobStore
+	executions  store.ExecutionStore
+	taskManager *TaskManager
+	reaper      *Reaper
+	producer    queue.Producer
+	// listLookbackDays bounds how far back listing across jobs looks for executions
+	listLookbackDays int
 }
 
 type ServiceConfig struct {
-	CassandraClient *database.CassandraClient
-	JobClient       *client.JobClient
-	Brokers         []string
+	Store     store.Store
+	JobClient *client.JobClient
+	Queue     queue.Queue
+	Config    *config.Config
 }
 
 func NewService(serviceConfig ServiceConfig) (*Service, error) {
-	cfg, err := config.LoadConfig()
+	cfg := serviceConfig.Config
+	taskManager := NewTaskManager()
+
+	// Retries of both the task processors and the reaper are published by
+	// one producer
+	producer, err := serviceConfig.Queue.NewProducer()
 	if err != nil {
 		return nil, err
 	}
 
-	taskManager := NewTaskManager()
-
-	// Add regular task processor
-	taskManager.AddTaskProcessor(TaskProcessorArgs{
-		Topic:            cfg.TaskTopic,
-		CassandraClient: serviceConfig.CassandraClient,
-		Brokers:         serviceConfig.Brokers,
-		GroupID:         "task_execution_group",
-		JobClient:       serviceConfig.JobClient,
+	// Add the task processor for the priority lanes, highest first
+	err = taskManager.AddPriorityTaskProcessor(TaskProcessorArgs{
+		Topic:             cfg.TaskTopic,
+		Executions:        serviceConfig.Store,
+		Queue:             serviceConfig.Queue,
+		Producer:          producer,
+		GroupID:           "task_execution_group",
+		JobClient:         serviceConfig.JobClient,
+		RetryTopic:        cfg.TaskRetryTopic,
+		MaxRetries:        cfg.Execution.Retry.MaxRetries,
+		WorkerID:          cfg.Execution.WorkerID,
+		HeartbeatInterval: cfg.Execution.HeartbeatInterval,
+		WorkerPoolSize:    cfg.Execution.WorkerPoolSize,
+	}, []PriorityLane{
+		{Topic: cfg.TaskHighPriorityTopic, Weight: cfg.TaskHighPriorityWeight},
+		{Topic: cfg.TaskTopic, Weight: cfg.TaskNormalPriorityWeight},
+		{Topic: cfg.TaskLowPriorityTopic, Weight: cfg.TaskLowPriorityWeight},
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	// Add retry task processor
 	err = taskManager.AddTaskRetryProcessor(TaskProcessorArgs{
-		Topic:            cfg.TaskRetryTopic,
-		CassandraClient: serviceConfig.CassandraClient,
-		Brokers:          serviceConfig.Brokers,
-		GroupID:          "task_retry_execution_group",
-		JobClient:        serviceConfig.JobClient,
+		Topic:             cfg.TaskRetryTopic,
+		Executions:        serviceConfig.Store,
+		Queue:             serviceConfig.Queue,
+		Producer:          producer,
+		GroupID:           "task_retry_execution_group",
+		JobClient:         serviceConfig.JobClient,
+		RetryTopic:        cfg.TaskRetryTopic,
+		MaxRetries:        cfg.Execution.Retry.MaxRetries,
+		WorkerID:          cfg.Execution.WorkerID,
+		HeartbeatInterval: cfg.Execution.HeartbeatInterval,
+		WorkerPoolSize:    cfg.Execution.WorkerPoolSize,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	reaper := NewReaper(ReaperArgs{
+		Store:            serviceConfig.Store,
+		Producer:         producer,
+		RetryTopic:       cfg.TaskRetryTopic,
+		Interval:         cfg.Execution.ReaperInterval,
+		HeartbeatTimeout: cfg.Execution.HeartbeatTimeout,
+		MaxRetries:       cfg.Execution.Retry.MaxRetries,
+	})
+
 	return &Service{
-		cassandraClient: serviceConfig.CassandraClient,
-		taskManager:     taskManager,
+		jobs:             serviceConfig.Store,
+		executions:       serviceConfig.Store,
+		taskManager:      taskManager,
+		reaper:           reaper,
+		producer:         producer,
+		listLookbackDays: cfg.CassandraDataRetentionDays,
 	}, nil
 }
 
@@ -69,15 +121,21 @@ func (s *Service) GetExecution(ctx context.Context, req *pb.GetExecutionRequest)
 		return nil, status.Errorf(codes.InvalidArgument, "Invalid execution ID")
 	}
 
-	execution, err := models.GetExecution(s.cassandraClient, id)
+	execution, err := s.executions.GetExecution(ctx, namespaceOrDefault(req.Namespace), id)
 	if err != nil {
-		if err == gocql.ErrNotFound {
+		if errors.Is(err, store.ErrNotFound) {
 			return nil, status.Errorf(codes.NotFound, "Execution not found")
 		}
-		logger.Error().Err(err).Msg("Error retrieving execution from Cassandra")
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving execution")
 		return nil, status.Errorf(codes.Internal, "Failed to retrieve execution")
 	}
 
+	execution.Attempts, err = s.executions.ListExecutionAttempts(ctx, execution.ID)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving execution attempts")
+		return nil, status.Errorf(codes.Internal, "Failed to retrieve execution attempts")
+	}
+
 	return execution.ToProto(), nil
 }
 
@@ -87,38 +145,133 @@ func (s *Service) ListExecutions(ctx context.Context, req *pb.ListExecutionsRequ
 		pageSize = 250
 	}
 
-	var lastID gocql.UUID
+	namespace := namespaceOrDefault(req.Namespace)
+	var jobID gocql.UUID
 	var err error
-	if req.LastId != "" {
-		lastID, err = gocql.ParseUUID(req.LastId)
+	if req.JobId != "" {
+		jobID, err = gocql.ParseUUID(req.JobId)
 		if err != nil {
-			return nil, status.Errorf(codes.InvalidArgument, "Invalid last ID")
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid job ID")
 		}
+
+		// A job's executions are read from its own partition, so check ownership first.
+		job, err := s.jobs.GetJob(ctx, jobID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving job")
+			return nil, status.Errorf(codes.Internal, "Failed to list executions")
+		}
+		if errors.Is(err, store.ErrNotFound) || job.Namespace != namespace {
+			return nil, status.Errorf(codes.NotFound, "Job not found")
+		}
+	}
+
+	var statusFilter string
+	if req.Status != pb.ExecutionStatus_UNSPECIFIED {
+		statusFilter = req.Status.String()
 	}
 
-	executions, err := models.ListExecutions(s.cassandraClient, pageSize, lastID, req.JobId, req.Status)
+	executions, nextPageToken, err := s.executions.ListExecutions(ctx, namespace, pageSize, req.PageToken, jobID, statusFilter, s.listLookbackDays)
 	if err != nil {
-		logger.Error().Err(err).Msg("Error listing executions from Cassandra")
+		if errors.Is(err, store.ErrInvalidPageToken) {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid page token")
+		}
+		logger.Ctx(ctx).Error().Err(err).Msg("Error listing executions")
 		return nil, status.Errorf(codes.Internal, "Failed to list executions")
 	}
 
 	var pbExecutions []*pb.ExecutionResponse
 	for _, execution := range executions {
+		execution.Attempts, err = s.executions.ListExecutionAttempts(ctx, execution.ID)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving execution attempts")
+			return nil, status.Errorf(codes.Internal, "Failed to list executions")
+		}
 		pbExecutions = append(pbExecutions, execution.ToProto())
 	}
 
-	var nextLastID string
-	if len(executions) > 0 {
-		nextLastID = executions[len(executions)-1].ID.String()
-	}
-
 	return &pb.ListExecutionsResponse{
-		Executions: pbExecutions,
-		Total:      int32(len(pbExecutions)),
-		NextPage:   nextLastID,
+		Executions:    pbExecutions,
+		Total:         int32(len(pbExecutions)),
+		NextPageToken: nextPageToken,
 	}, nil
 }
 
+// StreamExecutionLogs sends the stored log lines of an execution. With follow
+// set, it keeps polling for new lines until the execution is no longer running.
+func (s *Service) StreamExecutionLogs(req *pb.StreamExecutionLogsRequest, stream pb.ExecutionService_StreamExecutionLogsServer) error {
+	ctx := stream.Context()
+	id, err := gocql.ParseUUID(req.Id)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid execution ID")
+	}
+
+	namespace := namespaceOrDefault(req.Namespace)
+	if _, err := s.executions.GetExecution(ctx, namespace, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return status.Errorf(codes.NotFound, "Execution not found")
+		}
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving execution")
+		return status.Errorf(codes.Internal, "Failed to retrieve execution")
+	}
+
+	afterSeq := req.AfterSeq
+	finished := false
+	for {
+		lines, err := s.executions.ListExecutionLogLines(ctx, id, afterSeq, logPageSize)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving execution logs")
+			return status.Errorf(codes.Internal, "Failed to retrieve execution logs")
+		}
+		for _, line := range lines {
+			if err := stream.Send(line.ToProto()); err != nil {
+				return err
+			}
+			afterSeq = line.Seq
+		}
+		if len(lines) == logPageSize {
+			continue
+		}
+		if !req.Follow || finished {
+			return nil
+		}
+
+		execution, err := s.executions.GetExecution(ctx, namespace, id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return status.Errorf(codes.NotFound, "Execution not found")
+			}
+			logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving execution")
+			return status.Errorf(codes.Internal, "Failed to retrieve execution")
+		}
+		if isFinalStatus(execution.Status) {
+			// Drain anything written before the execution finished
+			finished = true
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// isFinalStatus reports whether an execution with the given status will not
+// run again. A QUEUED execution is waiting for its next attempt.
+func isFinalStatus(executionStatus string) bool {
+	switch executionStatus {
+	case pb.ExecutionStatus_QUEUED.String(), pb.ExecutionStatus_RUNNING.String():
+		return false
+	}
+	return true
+}
+
+// PingQueue checks that the queue is reachable.
+func (s *Service) PingQueue(ctx context.Context) error {
+	return s.producer.Ping(ctx)
+}
+
 func (s *Service) StartTaskManager(ctx context.Context) error {
 	return s.taskManager.StartTaskManager(ctx)
 }
@@ -127,9 +280,47 @@ func (s *Service) StopTaskManager() error {
 	return s.taskManager.StopTaskManager()
 }
 
+// Reconfigure applies the reloadable settings of cfg. Tasks already running
+// finish on their workers.
+func (s *Service) Reconfigure(cfg *config.Config) {
+	s.taskManager.SetWorkerPoolSize(cfg.Execution.WorkerPoolSize)
+}
+
+// WatchConfig applies changes to the reloadable settings of cfg until ctx is
+// done.
+func (s *Service) WatchConfig(ctx context.Context, cfg *config.Config) {
+	config.Watch(ctx, cfg, ReloadableSettings, s.Reconfigure)
+}
+
+// StartReaper runs the lost execution reaper until ctx is cancelled.
+func (s *Service) StartReaper(ctx context.Context) {
+	go s.reaper.Start(ctx)
+}
+
+// Close closes the producer of the service. Call it once the task manager
+// and the reaper have stopped.
+func (s *Service) Close() error {
+	return s.producer.Close()
+}
+
+func namespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return models.DefaultNamespace
+	}
+	return namespace
+}
+
 type ScheduledJob struct {
 	IdempotencyKey string    `json:"IdempotencyKey"`
 	JobID          string    `json:"JobID"`
 	StartTime      time.Time `json:"StartTime"`
 	RetryCount     int       `json:"RetryCount"`
+	// ScheduledTime is the logical fire time; it is zero for messages that predate it.
+	ScheduledTime time.Time `json:"ScheduledTime"`
+	Priority      int       `json:"Priority,omitempty"`
+	Namespace     string    `json:"Namespace,omitempty"`
+	// ExecutionID is set once the first attempt has created the execution.
+	ExecutionID string `json:"ExecutionID,omitempty"`
+	// TriggerSource is the name of a TriggerSource value; it defaults to TRIGGER_SOURCE_SCHEDULE.
+	TriggerSource string `json:"TriggerSource,omitempty"`
 }
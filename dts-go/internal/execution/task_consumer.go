@@ -1,48 +1,68 @@
 package execution
 
 import (
+	"time"
+
 	"github.com/nedson202/dts-go/pkg/client"
-	"github.com/nedson202/dts-go/pkg/database"
 	"github.com/nedson202/dts-go/pkg/logger"
 	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/store"
 )
 
 var _ TaskProcessor = (*TaskConsumer)(nil)
 
 type TaskConsumer struct {
-	kafkaClient *queue.KafkaClient
-	executor    *TaskExecutor
+	consumer queue.Consumer
+	executor *TaskExecutor
+	pool     *workerPool
 }
 
 type TaskConsumerArgs struct {
-	CassandraClient *database.CassandraClient
-	Brokers         []string
-	GroupID         string
-	JobClient       *client.JobClient
-	Topic           string
+	Executions        store.ExecutionStore
+	Queue             queue.Queue
+	GroupID           string
+	JobClient         *client.JobClient
+	Producer          queue.Producer
+	Topic             string
+	RetryTopic        string
+	MaxRetries        int
+	WorkerID          string
+	HeartbeatInterval time.Duration
+	WorkerPoolSize    int
 }
 
 func NewTaskConsumer(args TaskConsumerArgs) (*TaskConsumer, error) {
-	kafkaClient, err := queue.NewKafkaClient(args.Brokers, args.GroupID, args.Topic)
-	executor := NewTaskExecutor(args.CassandraClient, args.JobClient, kafkaClient)
+	consumer, err := args.Queue.NewConsumer(args.GroupID, args.Topic)
 	if err != nil {
 		return nil, err
 	}
+	executor := NewTaskExecutor(TaskExecutorArgs{
+		Executions:        args.Executions,
+		JobClient:         args.JobClient,
+		Producer:          args.Producer,
+		RetryTopic:        args.RetryTopic,
+		MaxRetries:        args.MaxRetries,
+		WorkerID:          args.WorkerID,
+		HeartbeatInterval: args.HeartbeatInterval,
+	})
 
-	return &TaskConsumer{kafkaClient: kafkaClient, executor: executor}, nil
+	return &TaskConsumer{consumer: consumer, executor: executor, pool: newWorkerPool(args.WorkerPoolSize)}, nil
 }
 
 func (tc *TaskConsumer) Start(topic string) error {
 	logger.Info().Msgf("Starting TaskConsumer for topic: %s", topic)
-	if err := tc.kafkaClient.Consume(); err != nil {
+	if err := tc.consumer.Consume(); err != nil {
 		return err
 	}
 
 	go func() {
-		for message := range tc.kafkaClient.Messages() {
-			if err := tc.executor.executeTask(message); err != nil {
-				logger.Error().Msgf("Error executing task: %v", err)
-			}
+		for message := range tc.consumer.Messages() {
+			message := message
+			tc.pool.Go(func() {
+				if err := tc.executor.executeTask(message); err != nil {
+					logger.Error().Msgf("Error executing task: %v", err)
+				}
+			})
 		}
 	}()
 
@@ -51,5 +71,9 @@ func (tc *TaskConsumer) Start(topic string) error {
 
 func (tc *TaskConsumer) Stop() error {
 	logger.Info().Msgf("Stopping TaskConsumer")
-	return tc.kafkaClient.Close()
+	return tc.consumer.Close()
+}
+
+func (tc *TaskConsumer) SetWorkerPoolSize(size int) {
+	tc.pool.Resize(size)
 }
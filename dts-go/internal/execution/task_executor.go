@@ -8,129 +8,385 @@ import (
 
 	"github.com/gocql/gocql"
 	"github.com/nedson202/dts-go/pkg/client"
-	"github.com/nedson202/dts-go/pkg/config"
-	"github.com/nedson202/dts-go/pkg/database"
 	"github.com/nedson202/dts-go/pkg/logger"
 	"github.com/nedson202/dts-go/pkg/models"
 	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/store"
+	"github.com/nedson202/dts-go/pkg/tracing"
+	pb "github.com/nedson202/dts-go/proto/execution/v1"
 	jobpb "github.com/nedson202/dts-go/proto/job/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TaskExecutor struct {
-	cassandraClient *database.CassandraClient
-	jobClient       *client.JobClient
-	kafkaClient     *queue.KafkaClient
-	maxRetries      int
+	executions        store.ExecutionStore
+	jobClient         *client.JobClient
+	producer          queue.Producer
+	retryTopic        string
+	maxRetries        int
+	workerID          string
+	heartbeatInterval time.Duration
 }
 
-func NewTaskExecutor(cassandraClient *database.CassandraClient, jobClient *client.JobClient, kafkaClient *queue.KafkaClient) *TaskExecutor {
+type TaskExecutorArgs struct {
+	Executions store.ExecutionStore
+	JobClient  *client.JobClient
+	// Producer publishes retries to RetryTopic.
+	Producer   queue.Producer
+	RetryTopic string
+	// MaxRetries is the number of times a failed or lost execution is
+	// re-enqueued before it is given up on.
+	MaxRetries        int
+	WorkerID          string
+	HeartbeatInterval time.Duration
+}
+
+func NewTaskExecutor(args TaskExecutorArgs) *TaskExecutor {
 	return &TaskExecutor{
-		cassandraClient: cassandraClient,
-		jobClient:       jobClient,
-		kafkaClient:     kafkaClient,
-		maxRetries:      3,
+		executions:        args.Executions,
+		jobClient:         args.JobClient,
+		producer:          args.Producer,
+		retryTopic:        args.RetryTopic,
+		maxRetries:        args.MaxRetries,
+		workerID:          args.WorkerID,
+		heartbeatInterval: args.HeartbeatInterval,
 	}
 }
 
-func (tc *TaskExecutor) executeTask(message []byte) error {
+func (tc *TaskExecutor) executeTask(message *queue.Message) (err error) {
+	ctx, span := startProcessSpan(message)
+	defer func() { tracing.End(span, err) }()
+
 	var scheduledJob ScheduledJob
-	if err := json.Unmarshal(message, &scheduledJob); err != nil {
+	if err := json.Unmarshal(message.Value, &scheduledJob); err != nil {
 		return fmt.Errorf("error unmarshaling message: %w", err)
 	}
 
-	return tc.processAndRetry(scheduledJob)
+	return tc.processAndRetry(ctx, scheduledJob)
 }
 
-func (tc *TaskExecutor) executeRetryTask(message []byte) error {
+func (tc *TaskExecutor) executeRetryTask(message *queue.Message) (err error) {
+	ctx, span := startProcessSpan(message)
+	defer func() { tracing.End(span, err) }()
+
 	var scheduledJob ScheduledJob
-	if err := json.Unmarshal(message, &scheduledJob); err != nil {
+	if err := json.Unmarshal(message.Value, &scheduledJob); err != nil {
 		return fmt.Errorf("error unmarshaling message: %w", err)
 	}
 
 	if scheduledJob.RetryCount >= tc.maxRetries {
 		logger.Info().Msgf("Max retries reached for idempotency key %s. Retry count: %d", scheduledJob.IdempotencyKey, scheduledJob.RetryCount)
+		tc.releaseResources(ctx, tc.dispatchNamespace(ctx, scheduledJob), scheduledJob.IdempotencyKey)
 		return nil
 	}
 
-	return tc.processAndRetry(scheduledJob)
+	return tc.processAndRetry(ctx, scheduledJob)
+}
+
+// dispatchNamespace returns the namespace a run was dispatched in, preferring
+// that of its execution, if one was created, over that of the message.
+func (tc *TaskExecutor) dispatchNamespace(ctx context.Context, scheduledJob ScheduledJob) string {
+	jobID, jobErr := gocql.ParseUUID(scheduledJob.JobID)
+	executionID, executionErr := gocql.ParseUUID(scheduledJob.ExecutionID)
+	if jobErr == nil && executionErr == nil {
+		execution, err := tc.executions.GetJobExecution(ctx, jobID, executionID)
+		if err == nil {
+			return execution.Namespace
+		}
+		logger.Ctx(ctx).Warn().Err(err).Msgf("Could not look up execution %s for its namespace", executionID)
+	}
+	return namespaceOrDefault(scheduledJob.Namespace)
+}
+
+// startProcessSpan starts the span covering the handling of a task message,
+// continuing the trace of the scheduler tick that dispatched it.
+func startProcessSpan(message *queue.Message) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(message.Context(), message.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", message.Topic),
+		),
+	)
 }
 
-func (tc *TaskExecutor) processAndRetry(scheduledJob ScheduledJob) error {
-	err := tc.processTask(scheduledJob)
+func (tc *TaskExecutor) processAndRetry(ctx context.Context, scheduledJob ScheduledJob) error {
+	execution, attempt, err := tc.startAttempt(ctx, scheduledJob)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error starting attempt for task %s", scheduledJob.JobID)
+
+		scheduledJob.RetryCount++
+		return tc.enqueueForRetry(ctx, scheduledJob)
+	}
+
+	// Retries of this run are recorded as further attempts of the same execution
+	scheduledJob.ExecutionID = execution.ID.String()
+	// Retry messages of lost executions used to leave the namespace out
+	if scheduledJob.Namespace == "" {
+		scheduledJob.Namespace = execution.Namespace
+	}
+
+	executionLog, err := NewExecutionLogWriter(ctx, tc.executions, execution.ID)
 	if err != nil {
-		logger.Error().Err(err).Msgf("Error processing task %s", scheduledJob.JobID)
+		err = fmt.Errorf("error opening log for execution %s: %w", execution.ID, err)
+	} else {
+		err = tc.processTask(ctx, scheduledJob, execution, attempt, executionLog)
+	}
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error processing task %s", scheduledJob.JobID)
+		willRetry := scheduledJob.RetryCount+1 < tc.maxRetries
+		tc.failAttempt(ctx, executionLog, execution, attempt, err, willRetry)
+		if !willRetry {
+			tc.releaseResources(ctx, execution.Namespace, scheduledJob.IdempotencyKey)
+		}
 
 		scheduledJob.RetryCount++
-		return tc.enqueueForRetry(scheduledJob)
+		return tc.enqueueForRetry(ctx, scheduledJob)
 	}
+
+	tc.releaseResources(ctx, execution.Namespace, scheduledJob.IdempotencyKey)
 	return nil
 }
 
-func (tc *TaskExecutor) enqueueForRetry(scheduledJob ScheduledJob) error {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return fmt.Errorf("error loading config: %w", err)
+// releaseResources returns the capacity and the concurrency quota slot the
+// scheduler took for this run once no further attempt will be made. The slot
+// is released in the namespace of the execution, which unlike that of the
+// message is always known.
+func (tc *TaskExecutor) releaseResources(ctx context.Context, namespace, idempotencyKey string) {
+	if err := tc.executions.ReleaseResources(ctx, idempotencyKey); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error releasing resources for idempotency key %s", idempotencyKey)
 	}
 
+	if err := tc.executions.ReleaseDispatch(ctx, namespace, idempotencyKey); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error releasing dispatch for idempotency key %s", idempotencyKey)
+	}
+}
+
+func (tc *TaskExecutor) enqueueForRetry(ctx context.Context, scheduledJob ScheduledJob) error {
 	jobJSON, err := json.Marshal(scheduledJob)
 	if err != nil {
 		return fmt.Errorf("error marshaling job for retry: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	if err := tc.kafkaClient.Produce(ctx, cfg.TaskRetryTopic, []byte(scheduledJob.IdempotencyKey), jobJSON); err != nil {
+	if err := tc.producer.Produce(ctx, tc.retryTopic, []byte(scheduledJob.IdempotencyKey), jobJSON); err != nil {
 		return fmt.Errorf("failed to publish retry message: %w", err)
 	}
 
 	return nil
 }
 
-func (tc *TaskExecutor) processTask(scheduledJob ScheduledJob) error {
+// startAttempt records a new attempt of the run described by scheduledJob,
+// creating the execution on the first attempt.
+func (tc *TaskExecutor) startAttempt(ctx context.Context, scheduledJob ScheduledJob) (*models.Execution, *models.ExecutionAttempt, error) {
 	if scheduledJob.JobID == "" {
-		return fmt.Errorf("job ID is empty in the message")
+		return nil, nil, fmt.Errorf("job ID is empty in the message")
 	}
 
 	jobID, err := gocql.ParseUUID(scheduledJob.JobID)
 	if err != nil {
-		return fmt.Errorf("error parsing job ID '%s': %w", scheduledJob.JobID, err)
+		return nil, nil, fmt.Errorf("error parsing job ID '%s': %w", scheduledJob.JobID, err)
 	}
 
-	// Create execution record
-	execution := &models.Execution{
-		ID:        gocql.TimeUUID(),
+	attempt := &models.ExecutionAttempt{
+		Attempt:   scheduledJob.RetryCount + 1,
 		JobID:     jobID,
-		Status:    "RUNNING",
-		StartTime: scheduledJob.StartTime,
+		Status:    pb.ExecutionStatus_RUNNING.String(),
+		StartTime: time.Now(),
+		WorkerID:  tc.workerID,
+	}
+
+	var execution *models.Execution
+	if scheduledJob.ExecutionID == "" {
+		triggerSource := scheduledJob.TriggerSource
+		if triggerSource == "" {
+			triggerSource = pb.TriggerSource_TRIGGER_SOURCE_SCHEDULE.String()
+		}
+		scheduledTime := scheduledJob.ScheduledTime
+		if scheduledTime.IsZero() {
+			scheduledTime = scheduledJob.StartTime
+		}
+
+		execution = &models.Execution{
+			ID:            gocql.TimeUUID(),
+			JobID:         jobID,
+			Status:        pb.ExecutionStatus_RUNNING.String(),
+			StartTime:     attempt.StartTime,
+			WorkerID:      tc.workerID,
+			ScheduledTime: scheduledTime,
+			TriggerSource: triggerSource,
+			AttemptCount:  attempt.Attempt,
+			Namespace:     namespaceOrDefault(scheduledJob.Namespace),
+		}
+		logger.Ctx(ctx).Info().Msgf("Creating execution for job %s", scheduledJob.JobID)
+
+		if err := tc.executions.CreateExecution(ctx, execution); err != nil {
+			return nil, nil, fmt.Errorf("error creating execution for job %s: %w", scheduledJob.JobID, err)
+		}
+		logger.Ctx(ctx).Info().Msgf("Execution created for job %s", scheduledJob.JobID)
+	} else {
+		executionID, err := gocql.ParseUUID(scheduledJob.ExecutionID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing execution ID '%s': %w", scheduledJob.ExecutionID, err)
+		}
+
+		execution, err = tc.executions.GetJobExecution(ctx, jobID, executionID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error retrieving execution %s: %w", executionID, err)
+		}
+
+		execution.Status = pb.ExecutionStatus_RUNNING.String()
+		execution.EndTime = nil
+		execution.Error = ""
+		execution.WorkerID = tc.workerID
+		execution.AttemptCount = attempt.Attempt
+		if err := tc.executions.UpdateExecution(ctx, execution); err != nil {
+			return nil, nil, fmt.Errorf("error updating execution for job %s: %w", scheduledJob.JobID, err)
+		}
+	}
+
+	attempt.ExecutionID = execution.ID
+	if err := tc.executions.CreateExecutionAttempt(ctx, attempt); err != nil {
+		return nil, nil, fmt.Errorf("error creating attempt %d of execution %s: %w", attempt.Attempt, execution.ID, err)
 	}
-	logger.Info().Msgf("Creating execution for job %s", scheduledJob.JobID)
+	logger.Ctx(ctx).Info().Msgf("Started attempt %d of execution %s for job %s", attempt.Attempt, execution.ID, scheduledJob.JobID)
+
+	return execution, attempt, nil
+}
+
+func (tc *TaskExecutor) processTask(ctx context.Context, scheduledJob ScheduledJob, execution *models.Execution, attempt *models.ExecutionAttempt, executionLog *ExecutionLogWriter) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "execute job", trace.WithAttributes(
+		attribute.String("dts.namespace", execution.Namespace),
+		attribute.String("dts.job_id", scheduledJob.JobID),
+		attribute.String("dts.execution_id", execution.ID.String()),
+		attribute.Int("dts.attempt", attempt.Attempt),
+		attribute.String("dts.worker_id", tc.workerID),
+	))
+	defer func() { tracing.End(span, err) }()
 
-	if err := models.CreateExecution(tc.cassandraClient, execution); err != nil {
-		return fmt.Errorf("error creating execution for job %s: %w", scheduledJob.JobID, err)
+	stopHeartbeat, err := tc.startHeartbeat(ctx, execution, attempt, scheduledJob)
+	if err != nil {
+		return fmt.Errorf("error starting heartbeat for job %s: %w", scheduledJob.JobID, err)
 	}
-	logger.Info().Msgf("Execution created for job %s", scheduledJob.JobID)
+	defer stopHeartbeat()
+
+	executionLog.Logf("Attempt %d of execution %s of job %s started on worker %s", attempt.Attempt, execution.ID, scheduledJob.JobID, tc.workerID)
 
 	// // Simulate job execution (replace this with actual job execution logic)
 	// time.Sleep(30 * time.Second)
 
-	// Update execution record
-	execution.Status = "COMPLETED"
+	// Update job status to COMPLETED
 	now := time.Now()
+	_, err = tc.jobClient.UpdateJob(ctx, scheduledJob.Namespace, scheduledJob.JobID, jobpb.JobStatus_COMPLETED, now)
+	if err != nil {
+		return fmt.Errorf("error updating status for job %s: %w", scheduledJob.JobID, err)
+	}
+	logger.Ctx(ctx).Info().Msgf("Job %s status updated to COMPLETED", scheduledJob.JobID)
+	executionLog.Logf("Reported job %s as COMPLETED to the job service", scheduledJob.JobID)
+
+	// Update attempt and execution records
+	attempt.Status = pb.ExecutionStatus_SUCCEEDED.String()
+	attempt.EndTime = &now
+	if err := tc.executions.UpdateExecutionAttempt(ctx, attempt); err != nil {
+		return fmt.Errorf("error updating attempt %d of execution %s: %w", attempt.Attempt, execution.ID, err)
+	}
+	recordAttemptOutcome(execution.Namespace, attempt)
+
+	execution.Status = pb.ExecutionStatus_SUCCEEDED.String()
 	execution.EndTime = &now
-	if err := models.UpdateExecution(tc.cassandraClient, execution); err != nil {
+	if err := tc.executions.UpdateExecution(ctx, execution); err != nil {
 		return fmt.Errorf("error updating execution for job %s: %w", scheduledJob.JobID, err)
 	}
-	logger.Info().Msgf("Execution updated for job %s", scheduledJob.JobID)
+	executionLog.Logf("Attempt %d of execution %s finished with status %s", attempt.Attempt, execution.ID, execution.Status)
+	logger.Ctx(ctx).Info().Msgf("Execution updated for job %s", scheduledJob.JobID)
 
-	// Update job status to COMPLETED
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	_, err = tc.jobClient.UpdateJob(ctx, scheduledJob.JobID, jobpb.JobStatus_COMPLETED, now)
-	if err != nil {
-		return fmt.Errorf("error updating status for job %s: %w", scheduledJob.JobID, err)
+	return nil
+}
+
+// failAttempt records why an attempt failed, also in the execution log
+// unless it could not be opened. The execution goes back to QUEUED if it will
+// be retried and is FAILED otherwise.
+func (tc *TaskExecutor) failAttempt(ctx context.Context, executionLog *ExecutionLogWriter, execution *models.Execution, attempt *models.ExecutionAttempt, cause error, willRetry bool) {
+	now := time.Now()
+	if executionLog != nil {
+		executionLog.Logf("Attempt %d of execution %s failed: %v", attempt.Attempt, execution.ID, cause)
+		if willRetry {
+			executionLog.Logf("Attempt %d of %d will be queued for retry", attempt.Attempt+1, tc.maxRetries)
+		} else {
+			executionLog.Logf("Giving up after %d of %d attempts", attempt.Attempt, tc.maxRetries)
+		}
 	}
-	logger.Info().Msgf("Job %s status updated to COMPLETED", scheduledJob.JobID)
 
-	return nil
+	attempt.Status = pb.ExecutionStatus_FAILED.String()
+	attempt.EndTime = &now
+	attempt.Error = cause.Error()
+	if err := tc.executions.UpdateExecutionAttempt(ctx, attempt); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error marking attempt %d of execution %s as failed", attempt.Attempt, execution.ID)
+	}
+	recordAttemptOutcome(execution.Namespace, attempt)
+
+	execution.Status = pb.ExecutionStatus_FAILED.String()
+	execution.EndTime = &now
+	if willRetry {
+		execution.Status = pb.ExecutionStatus_QUEUED.String()
+		execution.EndTime = nil
+	}
+	execution.Error = cause.Error()
+	if err := tc.executions.UpdateExecution(ctx, execution); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error marking execution %s as failed", execution.ID)
+	}
+}
+
+// startHeartbeat records that this worker owns the execution and keeps the
+// heartbeat fresh until the returned stop function is called.
+func (tc *TaskExecutor) startHeartbeat(ctx context.Context, execution *models.Execution, attempt *models.ExecutionAttempt, scheduledJob ScheduledJob) (func(), error) {
+	heartbeat := &models.ExecutionHeartbeat{
+		ExecutionID:    execution.ID,
+		JobID:          execution.JobID,
+		WorkerID:       tc.workerID,
+		IdempotencyKey: scheduledJob.IdempotencyKey,
+		RetryCount:     scheduledJob.RetryCount,
+		StartTime:      attempt.StartTime,
+		HeartbeatAt:    time.Now(),
+	}
+	if err := tc.executions.CreateHeartbeat(ctx, heartbeat); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(tc.heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// Heartbeats are kept out of the execution's trace
+				alive, err := tc.executions.TouchHeartbeat(context.Background(), execution.ID, time.Now())
+				if err != nil {
+					logger.Ctx(ctx).Error().Err(err).Msgf("Error recording heartbeat for execution %s", execution.ID)
+					continue
+				}
+				if !alive {
+					logger.Ctx(ctx).Warn().Msgf("Execution %s was reaped while still running on worker %s", execution.ID, tc.workerID)
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		if err := tc.executions.DeleteHeartbeat(ctx, execution.ID); err != nil {
+			logger.Ctx(ctx).Error().Err(err).Msgf("Error removing heartbeat for execution %s", execution.ID)
+		}
+	}, nil
 }
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/nedson202/dts-go/pkg/client"
-	"github.com/nedson202/dts-go/pkg/database"
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/store"
 )
 
 type TaskManager struct {
@@ -18,11 +20,17 @@ type TaskManager struct {
 }
 
 type TaskProcessorArgs struct {
-	Topic           string
-	CassandraClient *database.CassandraClient
-	Brokers         []string
-	GroupID         string
-	JobClient       *client.JobClient
+	Topic             string
+	Executions        store.ExecutionStore
+	Queue             queue.Queue
+	Producer          queue.Producer
+	GroupID           string
+	JobClient         *client.JobClient
+	RetryTopic        string
+	MaxRetries        int
+	WorkerID          string
+	HeartbeatInterval time.Duration
+	WorkerPoolSize    int
 }
 
 func NewTaskManager() *TaskManager {
@@ -39,11 +47,17 @@ func (tm *TaskManager) AddTaskProcessor(args TaskProcessorArgs) error {
 	defer tm.mu.Unlock()
 
 	processor, err := NewTaskConsumer(TaskConsumerArgs{
-		CassandraClient: args.CassandraClient,
-		Brokers:         args.Brokers,
-		GroupID:         args.GroupID,
-		JobClient:       args.JobClient,
-		Topic:           args.Topic,
+		Executions:        args.Executions,
+		Queue:             args.Queue,
+		Producer:          args.Producer,
+		GroupID:           args.GroupID,
+		JobClient:         args.JobClient,
+		Topic:             args.Topic,
+		RetryTopic:        args.RetryTopic,
+		MaxRetries:        args.MaxRetries,
+		WorkerID:          args.WorkerID,
+		HeartbeatInterval: args.HeartbeatInterval,
+		WorkerPoolSize:    args.WorkerPoolSize,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create task processor: %w", err)
@@ -53,21 +67,54 @@ func (tm *TaskManager) AddTaskProcessor(args TaskProcessorArgs) error {
 	return nil
 }
 
+// AddPriorityTaskProcessor registers a processor that consumes all priority
+// lanes with weighted round-robin. It is registered under args.Topic.
+func (tm *TaskManager) AddPriorityTaskProcessor(args TaskProcessorArgs, lanes []PriorityLane) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	processor, err := NewPriorityTaskConsumer(PriorityTaskConsumerArgs{
+		Executions:        args.Executions,
+		Queue:             args.Queue,
+		Producer:          args.Producer,
+		GroupID:           args.GroupID,
+		JobClient:         args.JobClient,
+		Lanes:             lanes,
+		RetryTopic:        args.RetryTopic,
+		MaxRetries:        args.MaxRetries,
+		WorkerID:          args.WorkerID,
+		HeartbeatInterval: args.HeartbeatInterval,
+		WorkerPoolSize:    args.WorkerPoolSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create priority task processor: %w", err)
+	}
+
+	tm.processors[args.Topic] = processor
+	return nil
+}
+
 func (tm *TaskManager) AddTaskRetryProcessor(args TaskProcessorArgs) error {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
 	processor, err := NewTaskRetryConsumer(TaskRetryConsumerArgs{
-		CassandraClient: args.CassandraClient,
-		Brokers:         args.Brokers,
-		GroupID:         args.GroupID,
-		JobClient:       args.JobClient,
-		Topic:           args.Topic,
+		Executions:        args.Executions,
+		Queue:             args.Queue,
+		Producer:          args.Producer,
+		GroupID:           args.GroupID,
+		JobClient:         args.JobClient,
+		Topic:             args.Topic,
+		RetryTopic:        args.RetryTopic,
+		MaxRetries:        args.MaxRetries,
+		WorkerID:          args.WorkerID,
+		HeartbeatInterval: args.HeartbeatInterval,
+		WorkerPoolSize:    args.WorkerPoolSize,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create task retry processor: %w", err)
 	}
-	
+
 	tm.processors[args.Topic] = processor
 	return nil
 }
@@ -109,6 +156,16 @@ func (tm *TaskManager) StopTaskManager() error {
 	return nil
 }
 
+// SetWorkerPoolSize changes how many tasks each processor runs at once.
+func (tm *TaskManager) SetWorkerPoolSize(size int) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	for _, processor := range tm.processors {
+		processor.SetWorkerPoolSize(size)
+	}
+}
+
 func (tm *TaskManager) GetProcessor(topic string) (TaskProcessor, bool) {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
@@ -3,4 +3,6 @@ package execution
 type TaskProcessor interface {
 	Start(topic string) error
 	Stop() error
+	// SetWorkerPoolSize changes how many tasks the processor runs at once.
+	SetWorkerPoolSize(size int)
 }
@@ -0,0 +1,55 @@
+package execution
+
+import "sync"
+
+// workerPool runs tasks on at most size goroutines at a time.
+type workerPool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	size    int
+	running int
+}
+
+func newWorkerPool(size int) *workerPool {
+	if size < 1 {
+		size = 1
+	}
+	pool := &workerPool{size: size}
+	pool.cond = sync.NewCond(&pool.mu)
+	return pool
+}
+
+// Go waits until fewer than size tasks are running, then runs task on a new
+// goroutine.
+func (p *workerPool) Go(task func()) {
+	p.mu.Lock()
+	for p.running >= p.size {
+		p.cond.Wait()
+	}
+	p.running++
+	p.mu.Unlock()
+
+	go func() {
+		defer p.release()
+		task()
+	}()
+}
+
+// Resize changes how many tasks run at once. Running tasks are not
+// interrupted; after shrinking, new tasks wait until enough of them finish.
+func (p *workerPool) Resize(size int) {
+	if size < 1 {
+		size = 1
+	}
+	p.mu.Lock()
+	p.size = size
+	p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+func (p *workerPool) release() {
+	p.mu.Lock()
+	p.running--
+	p.mu.Unlock()
+	p.cond.Signal()
+}
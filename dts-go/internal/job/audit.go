@@ -0,0 +1,138 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/auth"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/middleware"
+	"github.com/nedson202/dts-go/pkg/models"
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// anonymousActor is recorded when authentication is disabled.
+	anonymousActor = "anonymous"
+	// defaultAuditLookback is how far back events are listed when no start
+	// time is given.
+	defaultAuditLookback = 30 * 24 * time.Hour
+)
+
+// auditedJobFields lists the job fields compared by audit events, in the
+// order their changes are reported.
+var auditedJobFields = []string{"name", "description", "cron_expression", "status", "metadata", "priority", "resources", "namespace", "last_run"}
+
+// jobAuditFields renders the audited fields of a job as strings. A nil job
+// has no fields, so creations and deletions diff against nothing.
+func jobAuditFields(job *models.Job) map[string]string {
+	fields := make(map[string]string, len(auditedJobFields))
+	if job == nil {
+		return fields
+	}
+
+	fields["name"] = job.Name
+	fields["description"] = job.Description
+	fields["cron_expression"] = job.CronExpression
+	fields["status"] = job.Status
+	if len(job.Metadata) > 0 {
+		metadata, _ := json.Marshal(job.Metadata)
+		fields["metadata"] = string(metadata)
+	}
+	if job.Priority != 0 {
+		fields["priority"] = strconv.Itoa(job.Priority)
+	}
+	if !job.Resources.IsZero() {
+		resources, _ := json.Marshal(job.Resources)
+		fields["resources"] = string(resources)
+	}
+	fields["namespace"] = job.Namespace
+	if job.LastRun != nil {
+		fields["last_run"] = job.LastRun.UTC().Format(time.RFC3339Nano)
+	}
+	return fields
+}
+
+// recordAudit appends an audit event for a mutation of job. before and after
+// are the jobAuditFields of the job around the mutation. The mutation has
+// already been applied, so a failure is logged rather than returned.
+func (s *Service) recordAudit(ctx context.Context, action pb.AuditAction, job *models.Job, before, after map[string]string) {
+	event := &models.AuditEvent{
+		ID:        gocql.TimeUUID(),
+		Namespace: job.Namespace,
+		JobID:     job.ID,
+		Action:    action.String(),
+		Actor:     anonymousActor,
+		RequestID: middleware.RequestIDFromContext(ctx),
+	}
+	if identity, ok := auth.FromContext(ctx); ok {
+		event.Actor = identity.Subject
+	}
+	for _, field := range auditedJobFields {
+		if before[field] != after[field] {
+			event.Changes = append(event.Changes, models.FieldChange{Field: field, Before: before[field], After: after[field]})
+		}
+	}
+
+	if err := s.jobs.CreateAuditEvent(ctx, event); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("job_id", job.ID.String()).Str("action", event.Action).Msg("Error recording audit event")
+	}
+}
+
+func (s *Service) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 || pageSize > 250 {
+		pageSize = 250
+	}
+
+	filter := models.AuditEventFilter{
+		Namespace: namespaceOrDefault(req.Namespace),
+		Actor:     req.Actor,
+		End:       time.Now(),
+	}
+	if req.JobId != "" {
+		jobID, err := gocql.ParseUUID(req.JobId)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid job ID")
+		}
+		filter.JobID = jobID
+	}
+	if req.EndTime != nil {
+		filter.End = req.EndTime.AsTime()
+	}
+	filter.Start = filter.End.Add(-defaultAuditLookback)
+	if req.StartTime != nil {
+		filter.Start = req.StartTime.AsTime()
+	}
+	if filter.Start.After(filter.End) {
+		return nil, status.Errorf(codes.InvalidArgument, "Start time must not be after end time")
+	}
+
+	var before gocql.UUID
+	if req.PageToken != "" {
+		var err error
+		if before, err = gocql.ParseUUID(req.PageToken); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid page token")
+		}
+	}
+
+	events, next, err := s.jobs.ListAuditEvents(ctx, filter, pageSize, before)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error listing audit events")
+		return nil, status.Errorf(codes.Internal, "Failed to list audit events")
+	}
+
+	resp := &pb.ListAuditEventsResponse{}
+	for _, event := range events {
+		resp.Events = append(resp.Events, event.ToProto())
+	}
+	if next != (gocql.UUID{}) {
+		resp.NextPageToken = next.String()
+	}
+	return resp, nil
+}
@@ -0,0 +1,157 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/store"
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func namespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return models.DefaultNamespace
+	}
+	return namespace
+}
+
+// getNamespacedJob loads a job and checks that it belongs to the requested
+// namespace. Jobs of other namespaces are reported as not found so that their
+// existence is not revealed.
+func (s *Service) getNamespacedJob(ctx context.Context, namespace, jobID string) (*models.Job, error) {
+	id, err := gocql.ParseUUID(jobID)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid job ID")
+	}
+
+	job, err := s.jobs.GetJob(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "Job not found")
+		}
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving job")
+		return nil, status.Errorf(codes.Internal, "Failed to retrieve job")
+	}
+	if job.Namespace != namespaceOrDefault(namespace) {
+		return nil, status.Errorf(codes.NotFound, "Job not found")
+	}
+	return job, nil
+}
+
+func (s *Service) checkNamespaceExists(ctx context.Context, name string) error {
+	if _, err := s.jobs.GetNamespace(ctx, name); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return status.Errorf(codes.FailedPrecondition, "Namespace %s does not exist", name)
+		}
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving namespace")
+		return status.Errorf(codes.Internal, "Failed to retrieve namespace")
+	}
+	return nil
+}
+
+func (s *Service) CreateNamespace(ctx context.Context, req *pb.CreateNamespaceRequest) (*pb.Namespace, error) {
+	if err := models.ValidateNamespaceName(req.Name); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid namespace name: %v", err)
+	}
+
+	namespace := &models.Namespace{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	created, err := s.jobs.CreateNamespace(ctx, namespace)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error inserting namespace")
+		return nil, status.Errorf(codes.Internal, "Failed to create namespace")
+	}
+	if !created {
+		return nil, status.Errorf(codes.AlreadyExists, "Namespace %s already exists", req.Name)
+	}
+
+	return namespace.ToProto(), nil
+}
+
+func (s *Service) GetNamespace(ctx context.Context, req *pb.GetNamespaceRequest) (*pb.Namespace, error) {
+	namespace, err := s.jobs.GetNamespace(ctx, req.Name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "Namespace not found")
+		}
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving namespace")
+		return nil, status.Errorf(codes.Internal, "Failed to retrieve namespace")
+	}
+	return namespace.ToProto(), nil
+}
+
+func (s *Service) ListNamespaces(ctx context.Context, req *pb.ListNamespacesRequest) (*pb.ListNamespacesResponse, error) {
+	namespaces, err := s.jobs.ListNamespaces(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error listing namespaces")
+		return nil, status.Errorf(codes.Internal, "Failed to list namespaces")
+	}
+
+	resp := &pb.ListNamespacesResponse{}
+	for _, namespace := range namespaces {
+		resp.Namespaces = append(resp.Namespaces, namespace.ToProto())
+	}
+	return resp, nil
+}
+
+func (s *Service) UpdateNamespace(ctx context.Context, req *pb.UpdateNamespaceRequest) (*pb.Namespace, error) {
+	namespace, err := s.jobs.GetNamespace(ctx, req.Name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "Namespace not found")
+		}
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving namespace")
+		return nil, status.Errorf(codes.Internal, "Failed to retrieve namespace")
+	}
+
+	namespace.Description = req.Description
+	namespace.UpdatedAt = time.Now()
+	updated, err := s.jobs.UpdateNamespace(ctx, namespace)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error updating namespace")
+		return nil, status.Errorf(codes.Internal, "Failed to update namespace")
+	}
+	if !updated {
+		return nil, status.Errorf(codes.NotFound, "Namespace not found")
+	}
+	return namespace.ToProto(), nil
+}
+
+// DeleteNamespace removes an empty namespace. The default namespace cannot be
+// deleted.
+func (s *Service) DeleteNamespace(ctx context.Context, req *pb.DeleteNamespaceRequest) (*pb.DeleteNamespaceResponse, error) {
+	if req.Name == models.DefaultNamespace {
+		return nil, status.Errorf(codes.FailedPrecondition, "The default namespace cannot be deleted")
+	}
+	if err := s.checkNamespaceExists(ctx, req.Name); err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			return nil, status.Errorf(codes.NotFound, "Namespace not found")
+		}
+		return nil, err
+	}
+
+	count, err := s.jobs.CountNamespaceJobs(ctx, req.Name)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error counting jobs")
+		return nil, status.Errorf(codes.Internal, "Failed to delete namespace")
+	}
+	if count > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "Namespace %s still has %d jobs", req.Name, count)
+	}
+
+	if err := s.jobs.DeleteNamespace(ctx, req.Name); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error deleting namespace")
+		return nil, status.Errorf(codes.Internal, "Failed to delete namespace")
+	}
+	return &pb.DeleteNamespaceResponse{Success: true}, nil
+}
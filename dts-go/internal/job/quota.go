@@ -0,0 +1,164 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/store"
+	"github.com/nedson202/dts-go/pkg/utils"
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scheduleIntervalSamples is how many consecutive runs of a cron expression
+// are inspected to find its shortest interval.
+const scheduleIntervalSamples = 50
+
+func (s *Service) GetQuota(ctx context.Context, req *pb.GetQuotaRequest) (*pb.GetQuotaResponse, error) {
+	if req.Namespace == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Namespace is required")
+	}
+	return s.quotaResponse(ctx, req.Namespace)
+}
+
+func (s *Service) UpdateQuota(ctx context.Context, req *pb.UpdateQuotaRequest) (*pb.GetQuotaResponse, error) {
+	if req.Namespace == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Namespace is required")
+	}
+	if err := s.checkNamespaceExists(ctx, req.Namespace); err != nil {
+		return nil, err
+	}
+	quota := models.QuotaFromProto(req.Quota)
+	if quota.MaxJobs < 0 || quota.MinScheduleInterval < 0 || quota.MaxConcurrentExecutions < 0 || quota.MaxExecutionsPerHour < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Quota limits must not be negative")
+	}
+
+	if err := s.jobs.SetQuota(ctx, req.Namespace, quota); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error saving quota")
+		return nil, status.Errorf(codes.Internal, "Failed to update quota")
+	}
+	return s.quotaResponse(ctx, req.Namespace)
+}
+
+func (s *Service) quotaResponse(ctx context.Context, namespace string) (*pb.GetQuotaResponse, error) {
+	quota, err := s.jobs.GetQuota(ctx, namespace, s.defaultQuota)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving quota")
+		return nil, status.Errorf(codes.Internal, "Failed to retrieve quota")
+	}
+	usage, err := store.GetQuotaUsage(ctx, s.jobs, s.executions, namespace, time.Now())
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving quota usage")
+		return nil, status.Errorf(codes.Internal, "Failed to retrieve quota usage")
+	}
+
+	return &pb.GetQuotaResponse{
+		Namespace: namespace,
+		Quota:     quota.ToProto(),
+		Usage:     usage.ToProto(),
+	}, nil
+}
+
+// checkCreateQuota rejects a new job if its namespace is at its job limit or
+// the job would run more often than the namespace allows.
+func (s *Service) checkCreateQuota(ctx context.Context, job *models.Job) error {
+	quota, err := s.jobs.GetQuota(ctx, job.Namespace, s.defaultQuota)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving quota")
+		return status.Errorf(codes.Internal, "Failed to check quota")
+	}
+
+	if quota.MaxJobs > 0 {
+		count, err := s.jobs.CountNamespaceJobs(ctx, job.Namespace)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Msg("Error counting jobs")
+			return status.Errorf(codes.Internal, "Failed to check quota")
+		}
+		if count >= quota.MaxJobs {
+			return status.Errorf(codes.ResourceExhausted, "Namespace %s has reached its limit of %d jobs", job.Namespace, quota.MaxJobs)
+		}
+	}
+
+	return checkScheduleInterval(job, quota)
+}
+
+func (s *Service) checkScheduleQuota(ctx context.Context, job *models.Job) error {
+	quota, err := s.jobs.GetQuota(ctx, job.Namespace, s.defaultQuota)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving quota")
+		return status.Errorf(codes.Internal, "Failed to check quota")
+	}
+	return checkScheduleInterval(job, quota)
+}
+
+// reserveTriggeredRun takes the resources and the quota a triggered run of
+// job needs, rejecting it when the namespace is at its execution quotas or
+// the pool cannot fit it right now.
+func (s *Service) reserveTriggeredRun(ctx context.Context, job *models.Job, idempotencyKey string, now time.Time) error {
+	quota, err := s.jobs.GetQuota(ctx, job.Namespace, s.defaultQuota)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error retrieving quota")
+		return status.Errorf(codes.Internal, "Failed to check quota")
+	}
+	if quota.MaxExecutionsPerHour > 0 {
+		executions, err := s.executions.GetHourlyExecutions(ctx, job.Namespace, now)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Msg("Error counting hourly executions")
+			return status.Errorf(codes.Internal, "Failed to check quota")
+		}
+		if executions >= quota.MaxExecutionsPerHour {
+			return status.Errorf(codes.ResourceExhausted, "Namespace %s has reached its limit of %d executions per hour", job.Namespace, quota.MaxExecutionsPerHour)
+		}
+	}
+
+	if !job.Resources.IsZero() {
+		reserved, err := s.executions.ReserveResources(ctx, idempotencyKey, job.Resources)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Msg("Error reserving resources")
+			return status.Errorf(codes.Internal, "Failed to reserve resources")
+		}
+		if !reserved {
+			return status.Errorf(codes.ResourceExhausted, "Not enough resources are available to run job %s", job.ID)
+		}
+	}
+
+	acquired, err := s.executions.AcquireDispatch(ctx, job.Namespace, idempotencyKey, job.ID, now, quota.MaxConcurrentExecutions)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error recording dispatch")
+		s.releaseTriggeredRun(ctx, job, idempotencyKey)
+		return status.Errorf(codes.Internal, "Failed to check quota")
+	}
+	if !acquired {
+		s.releaseTriggeredRun(ctx, job, idempotencyKey)
+		return status.Errorf(codes.ResourceExhausted, "Namespace %s has reached its limit of %d concurrent executions", job.Namespace, quota.MaxConcurrentExecutions)
+	}
+	return nil
+}
+
+// releaseTriggeredRun gives back what reserveTriggeredRun took for a run that
+// is not dispatched after all.
+func (s *Service) releaseTriggeredRun(ctx context.Context, job *models.Job, idempotencyKey string) {
+	if err := s.executions.ReleaseResources(ctx, idempotencyKey); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error releasing resources of job %s", job.ID)
+	}
+	if err := s.executions.ReleaseDispatch(ctx, job.Namespace, idempotencyKey); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error releasing dispatch of job %s", job.ID)
+	}
+}
+
+func checkScheduleInterval(job *models.Job, quota models.Quota) error {
+	if quota.MinScheduleInterval <= 0 {
+		return nil
+	}
+	interval, err := utils.MinScheduleInterval(job.CronExpression, time.Now(), scheduleIntervalSamples)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid cron expression: %v", err)
+	}
+	if interval > 0 && interval < quota.MinScheduleInterval {
+		return status.Errorf(codes.ResourceExhausted, "Cron expression runs every %v, namespace %s allows at most every %v", interval, job.Namespace, quota.MinScheduleInterval)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/config"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/store"
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestService(t *testing.T, cfg *config.Config) (*Service, *store.MemoryStore) {
+	t.Helper()
+	db := store.NewMemoryStore()
+	return NewService(db, cfg), db
+}
+
+// setQuota sets the quota of the default namespace.
+func setQuota(t *testing.T, s *Service, quota *pb.Quota) {
+	t.Helper()
+	if _, err := s.UpdateQuota(context.Background(), &pb.UpdateQuotaRequest{Namespace: models.DefaultNamespace, Quota: quota}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createJob(t *testing.T, s *Service, req *pb.CreateJobRequest) string {
+	t.Helper()
+	resp, err := s.CreateJob(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.JobId
+}
+
+func wantCode(t *testing.T, err error, code codes.Code, what string) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Fatalf("%s: got %v, want %s", what, err, code)
+	}
+}
+
+func TestCreateJobAtJobLimit(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t, config.Default())
+	setQuota(t, s, &pb.Quota{MaxJobs: 2})
+
+	first := createJob(t, s, &pb.CreateJobRequest{Name: "first", CronExpression: "0 3 * * *"})
+	createJob(t, s, &pb.CreateJobRequest{Name: "second", CronExpression: "0 3 * * *"})
+	_, err := s.CreateJob(ctx, &pb.CreateJobRequest{Name: "third", CronExpression: "0 3 * * *"})
+	wantCode(t, err, codes.ResourceExhausted, "creating a job at the limit")
+
+	// Deleting a job makes room again
+	if _, err := s.DeleteJob(ctx, &pb.DeleteJobRequest{Id: first}); err != nil {
+		t.Fatal(err)
+	}
+	createJob(t, s, &pb.CreateJobRequest{Name: "third", CronExpression: "0 3 * * *"})
+
+	// Other namespaces have limits of their own
+	if _, err := s.CreateNamespace(ctx, &pb.CreateNamespaceRequest{Name: "payments"}); err != nil {
+		t.Fatal(err)
+	}
+	createJob(t, s, &pb.CreateJobRequest{Name: "elsewhere", CronExpression: "0 3 * * *", Namespace: "payments"})
+}
+
+func TestCreateJobAtConfiguredDefaultLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.QuotaMaxJobs = 1
+	s, _ := newTestService(t, cfg)
+
+	createJob(t, s, &pb.CreateJobRequest{Name: "first", CronExpression: "0 3 * * *"})
+	_, err := s.CreateJob(context.Background(), &pb.CreateJobRequest{Name: "second", CronExpression: "0 3 * * *"})
+	wantCode(t, err, codes.ResourceExhausted, "creating a job beyond the default limit")
+}
+
+func TestScheduleIntervalQuota(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t, config.Default())
+	setQuota(t, s, &pb.Quota{MinScheduleIntervalSeconds: int32((10 * time.Minute).Seconds())})
+
+	_, err := s.CreateJob(ctx, &pb.CreateJobRequest{Name: "busy", CronExpression: "*/5 * * * *"})
+	wantCode(t, err, codes.ResourceExhausted, "creating a job that runs every 5 minutes")
+	// The shortest gap counts, not the average
+	_, err = s.CreateJob(ctx, &pb.CreateJobRequest{Name: "bursty", CronExpression: "0,1 * * * *"})
+	wantCode(t, err, codes.ResourceExhausted, "creating a job that runs twice a minute apart")
+
+	id := createJob(t, s, &pb.CreateJobRequest{Name: "calm", CronExpression: "*/10 * * * *"})
+	_, err = s.UpdateJob(ctx, &pb.UpdateJobRequest{Id: id, CronExpression: "* * * * *"})
+	wantCode(t, err, codes.ResourceExhausted, "updating a job to run every minute")
+	if _, err := s.UpdateJob(ctx, &pb.UpdateJobRequest{Id: id, CronExpression: "0 * * * *"}); err != nil {
+		t.Fatalf("updating a job to run hourly: %v", err)
+	}
+}
+
+func TestUpdateQuotaRejectsNegativeLimits(t *testing.T) {
+	s, _ := newTestService(t, config.Default())
+	_, err := s.UpdateQuota(context.Background(), &pb.UpdateQuotaRequest{Namespace: models.DefaultNamespace, Quota: &pb.Quota{MaxJobs: -1}})
+	wantCode(t, err, codes.InvalidArgument, "setting a negative limit")
+	_, err = s.UpdateQuota(context.Background(), &pb.UpdateQuotaRequest{Namespace: "missing", Quota: &pb.Quota{MaxJobs: 1}})
+	wantCode(t, err, codes.FailedPrecondition, "setting the quota of a missing namespace")
+}
+
+func TestTriggerJobAtConcurrencyQuota(t *testing.T) {
+	ctx := context.Background()
+	s, db := newTestService(t, config.Default())
+	setQuota(t, s, &pb.Quota{MaxConcurrentExecutions: 1})
+	id := createJob(t, s, &pb.CreateJobRequest{
+		Name:           "adhoc",
+		CronExpression: "0 3 * * *",
+		Resources:      &pb.ResourceRequirements{Cpu: 10},
+	})
+
+	if _, err := s.TriggerJob(ctx, &pb.TriggerJobRequest{Id: id}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.TriggerJob(ctx, &pb.TriggerJobRequest{Id: id})
+	wantCode(t, err, codes.ResourceExhausted, "triggering a second run at the concurrency limit")
+
+	// The rejected run gave back the capacity it had reserved
+	pool, err := db.GetResourcePool(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.Reservations) != 1 || pool.Available.CPU != 90 {
+		t.Fatalf("got reservations %v leaving %d CPU, want only that of the first run", pool.Reservations, pool.Available.CPU)
+	}
+
+	quota, err := s.GetQuota(ctx, &pb.GetQuotaRequest{Namespace: models.DefaultNamespace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota.Usage.ConcurrentExecutions != 1 || quota.Usage.ExecutionsThisHour != 1 || quota.Usage.Jobs != 1 {
+		t.Fatalf("got usage %+v, want one job with one running execution this hour", quota.Usage)
+	}
+
+	// Releasing the slot of the first run, as the executor does when it
+	// finishes, lets the next one through
+	var key string
+	for reserved := range pool.Reservations {
+		key = reserved
+	}
+	if err := db.ReleaseDispatch(ctx, models.DefaultNamespace, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ReleaseResources(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.TriggerJob(ctx, &pb.TriggerJobRequest{Id: id}); err != nil {
+		t.Fatalf("triggering after the running execution was released: %v", err)
+	}
+}
+
+func TestTriggerJobAtHourlyQuota(t *testing.T) {
+	ctx := context.Background()
+	s, db := newTestService(t, config.Default())
+	setQuota(t, s, &pb.Quota{MaxExecutionsPerHour: 1})
+	id := createJob(t, s, &pb.CreateJobRequest{Name: "adhoc", CronExpression: "0 3 * * *"})
+
+	if _, err := s.TriggerJob(ctx, &pb.TriggerJobRequest{Id: id}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.TriggerJob(ctx, &pb.TriggerJobRequest{Id: id})
+	wantCode(t, err, codes.ResourceExhausted, "triggering a second run in the hour")
+
+	// Unlike the concurrency slot, finishing the run does not give the hour back
+	entries, err := db.ListPendingOutboxEntries(ctx, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for shard := 1; shard < models.OutboxShards; shard++ {
+		shardEntries, err := db.ListPendingOutboxEntries(ctx, shard, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, shardEntries...)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d outbox entries, want 1", len(entries))
+	}
+	if err := db.ReleaseDispatch(ctx, models.DefaultNamespace, entries[0].ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.TriggerJob(ctx, &pb.TriggerJobRequest{Id: id})
+	wantCode(t, err, codes.ResourceExhausted, "triggering again after the run finished")
+}
@@ -0,0 +1,77 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/auth"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/rbac"
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Service) CreateRoleBinding(ctx context.Context, req *pb.CreateRoleBindingRequest) (*pb.RoleBinding, error) {
+	if req.Subject == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Subject is required")
+	}
+	role, err := rbac.ParseRole(req.Role)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid role: %v", err)
+	}
+	namespace := namespaceOrDefault(req.Namespace)
+	if namespace != models.AllNamespaces {
+		if err := s.checkNamespaceExists(ctx, namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	binding := &models.RoleBinding{
+		Namespace: namespace,
+		Subject:   req.Subject,
+		Role:      string(role),
+		CreatedAt: time.Now(),
+	}
+	if identity, ok := auth.FromContext(ctx); ok {
+		binding.CreatedBy = identity.Subject
+	}
+	if err := s.jobs.SetRoleBinding(ctx, binding); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error saving role binding")
+		return nil, status.Errorf(codes.Internal, "Failed to create role binding")
+	}
+
+	return binding.ToProto(), nil
+}
+
+func (s *Service) ListRoleBindings(ctx context.Context, req *pb.ListRoleBindingsRequest) (*pb.ListRoleBindingsResponse, error) {
+	bindings, err := s.jobs.ListRoleBindings(ctx, namespaceOrDefault(req.Namespace))
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error listing role bindings")
+		return nil, status.Errorf(codes.Internal, "Failed to list role bindings")
+	}
+
+	resp := &pb.ListRoleBindingsResponse{}
+	for _, binding := range bindings {
+		resp.RoleBindings = append(resp.RoleBindings, binding.ToProto())
+	}
+	return resp, nil
+}
+
+func (s *Service) DeleteRoleBinding(ctx context.Context, req *pb.DeleteRoleBindingRequest) (*pb.DeleteRoleBindingResponse, error) {
+	if req.Subject == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Subject is required")
+	}
+
+	deleted, err := s.jobs.DeleteRoleBinding(ctx, namespaceOrDefault(req.Namespace), req.Subject)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error deleting role binding")
+		return nil, status.Errorf(codes.Internal, "Failed to delete role binding")
+	}
+	if !deleted {
+		return nil, status.Errorf(codes.NotFound, "Role binding not found")
+	}
+
+	return &pb.DeleteRoleBindingResponse{Success: true}, nil
+}
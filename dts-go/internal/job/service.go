@@ -6,10 +6,15 @@ import (
 	"time"
 
 	"github.com/gocql/gocql"
-	"github.com/nedson202/dts-go/pkg/database"
+	"github.com/gofrs/uuid"
+	"github.com/nedson202/dts-go/internal/scheduler"
+	"github.com/nedson202/dts-go/pkg/config"
 	"github.com/nedson202/dts-go/pkg/logger"
 	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/store"
+	"github.com/nedson202/dts-go/pkg/tracing"
 	"github.com/nedson202/dts-go/pkg/utils"
+	executionpb "github.com/nedson202/dts-go/proto/execution/v1"
 	pb "github.com/nedson202/dts-go/proto/job/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -17,12 +22,19 @@ import (
 
 type Service struct {
 	pb.UnimplementedJobServiceServer
-	cassandraClient *database.CassandraClient
+	jobs         store.JobStore
+	executions   store.ExecutionStore
+	defaultQuota models.Quota
+	// cfg selects the task topics triggered runs are dispatched to.
+	cfg *config.Config
 }
 
-func NewService(cassandraClient *database.CassandraClient) *Service {
+func NewService(db store.Store, cfg *config.Config) *Service {
 	return &Service{
-		cassandraClient: cassandraClient,
+		jobs:         db,
+		executions:   db,
+		defaultQuota: models.DefaultQuota(cfg),
+		cfg:          cfg,
 	}
 }
 
@@ -31,7 +43,12 @@ func (s *Service) CreateJob(ctx context.Context, req *pb.CreateJobRequest) (*pb.
 	if err := utils.ValidateCronExpression(req.CronExpression); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "Invalid cron expression: %v", err)
 	}
-	
+
+	resources := models.ResourcesFromProto(req.Resources)
+	if err := resources.Validate(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid resources: %v", err)
+	}
+
 	job := &models.Job{
 		ID:             gocql.TimeUUID(),
 		Name:           req.Name,
@@ -41,34 +58,38 @@ func (s *Service) CreateJob(ctx context.Context, req *pb.CreateJobRequest) (*pb.
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 		Metadata:       req.Metadata,
+		Resources:      resources,
+		Priority:       int(req.Priority),
+		Namespace:      req.Namespace,
+		TraceContext:   tracing.Inject(ctx),
 	}
 
 	if job.Status == pb.JobStatus_UNSPECIFIED.String() {
 		job.Status = pb.JobStatus_PENDING.String()
 	}
+	job.Namespace = namespaceOrDefault(job.Namespace)
+	if err := s.checkNamespaceExists(ctx, job.Namespace); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCreateQuota(ctx, job); err != nil {
+		return nil, err
+	}
 
-	err := models.CreateJob(s.cassandraClient, job)
+	err := s.jobs.CreateJob(ctx, job)
 	if err != nil {
-		logger.Error().Err(err).Msg("Error inserting job into Cassandra")
+		logger.Ctx(ctx).Error().Err(err).Msg("Error inserting job")
 		return nil, status.Errorf(codes.Internal, "Failed to create job")
 	}
+	s.recordAudit(ctx, pb.AuditAction_AUDIT_ACTION_CREATE, job, jobAuditFields(nil), jobAuditFields(job))
 
 	return &pb.CreateJobResponse{JobId: job.ID.String()}, nil
 }
 
 func (s *Service) GetJob(ctx context.Context, req *pb.GetJobRequest) (*pb.JobResponse, error) {
-	id, err := gocql.ParseUUID(req.Id)
+	job, err := s.getNamespacedJob(ctx, req.Namespace, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "Invalid job ID")
-	}
-
-	job, err := models.GetJob(s.cassandraClient, id)
-	if err != nil {
-		if err == gocql.ErrNotFound {
-			return nil, status.Errorf(codes.NotFound, "Job not found")
-		}
-		logger.Error().Err(err).Msg("Error retrieving job from Cassandra")
-		return nil, status.Errorf(codes.Internal, "Failed to retrieve job")
+		return nil, err
 	}
 
 	return job.ToProto(), nil
@@ -92,9 +113,9 @@ func (s *Service) ListJobs(ctx context.Context, req *pb.ListJobsRequest) (*pb.Li
 		lastID = nilUUID
 	}
 
-	jobs, err := models.ListJobs(s.cassandraClient, pageSize, lastID, req.Status)
+	jobs, err := s.jobs.ListJobs(ctx, namespaceOrDefault(req.Namespace), pageSize, lastID, req.Status)
 	if err != nil {
-		logger.Error().Err(err).Msg("Error listing jobs from Cassandra")
+		logger.Ctx(ctx).Error().Err(err).Msg("Error listing jobs")
 		return nil, status.Errorf(codes.Internal, "Failed to list jobs")
 	}
 
@@ -116,20 +137,12 @@ func (s *Service) ListJobs(ctx context.Context, req *pb.ListJobsRequest) (*pb.Li
 }
 
 func (s *Service) UpdateJob(ctx context.Context, req *pb.UpdateJobRequest) (*pb.JobResponse, error) {
-	id, err := gocql.ParseUUID(req.Id)
-	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "Invalid job ID")
-	}
-
 	// Fetch the existing job
-	existingJob, err := models.GetJob(s.cassandraClient, id)
+	existingJob, err := s.getNamespacedJob(ctx, req.Namespace, req.Id)
 	if err != nil {
-		if err == gocql.ErrNotFound {
-			return nil, status.Errorf(codes.NotFound, "Job not found")
-		}
-		logger.Error().Err(err).Msg("Error retrieving job from Cassandra")
-		return nil, status.Errorf(codes.Internal, "Failed to retrieve job")
+		return nil, err
 	}
+	before := jobAuditFields(existingJob)
 
 	// Update only the fields that are provided in the request
 	if req.Name != "" {
@@ -138,18 +151,35 @@ func (s *Service) UpdateJob(ctx context.Context, req *pb.UpdateJobRequest) (*pb.
 	if req.Description != "" {
 		existingJob.Description = req.Description
 	}
+	cronChanged := false
 	if req.CronExpression != "" {
 		if err := utils.ValidateCronExpression(req.CronExpression); err != nil {
 			return nil, status.Errorf(codes.InvalidArgument, "Invalid cron expression: %v", err)
 		}
+		cronChanged = req.CronExpression != existingJob.CronExpression
 		existingJob.CronExpression = req.CronExpression
 	}
+	if cronChanged {
+		if err := s.checkScheduleQuota(ctx, existingJob); err != nil {
+			return nil, err
+		}
+	}
 	if req.Status != pb.JobStatus_UNSPECIFIED {
 		existingJob.Status = req.Status.String()
 	}
 	if req.Metadata != nil {
 		existingJob.Metadata = req.Metadata
 	}
+	if req.Priority != 0 {
+		existingJob.Priority = int(req.Priority)
+	}
+	if req.Resources != nil {
+		resources := models.ResourcesFromProto(req.Resources)
+		if err := resources.Validate(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid resources: %v", err)
+		}
+		existingJob.Resources = resources
+	}
 
 	if req.LastRun != nil {
 		lastRunTime := req.LastRun.AsTime()
@@ -158,60 +188,113 @@ func (s *Service) UpdateJob(ctx context.Context, req *pb.UpdateJobRequest) (*pb.
 
 	existingJob.UpdatedAt = time.Now()
 
-	err = models.UpdateJob(s.cassandraClient, existingJob)
+	err = s.jobs.UpdateJob(ctx, existingJob)
 	if err != nil {
-		logger.Error().Err(err).Msg("Error updating job in Cassandra")
+		logger.Ctx(ctx).Error().Err(err).Msg("Error updating job")
 		return nil, status.Errorf(codes.Internal, "Failed to update job")
 	}
 
+	// A new schedule starts counting from now; otherwise next_run belongs to the scheduler.
+	if cronChanged {
+		nextRun, err := utils.CalculateNextRun(existingJob.CronExpression, time.Now())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid cron expression: %v", err)
+		}
+		if err := s.jobs.SetJobNextRun(ctx, existingJob.ID, nextRun); err != nil {
+			logger.Ctx(ctx).Error().Err(err).Msg("Error updating job next run")
+			return nil, status.Errorf(codes.Internal, "Failed to update job")
+		}
+		existingJob.NextRun = nextRun
+	}
+	s.recordAudit(ctx, pb.AuditAction_AUDIT_ACTION_UPDATE, existingJob, before, jobAuditFields(existingJob))
+
 	return existingJob.ToProto(), nil
 }
 
 func (s *Service) DeleteJob(ctx context.Context, req *pb.DeleteJobRequest) (*pb.DeleteJobResponse, error) {
-	id, err := gocql.ParseUUID(req.Id)
+	job, err := s.getNamespacedJob(ctx, req.Namespace, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "Invalid job ID")
+		return nil, err
 	}
 
-	err = models.DeleteJob(s.cassandraClient, id)
+	err = s.jobs.DeleteJob(ctx, job)
 	if err != nil {
-		logger.Error().Err(err).Msg("Error deleting job from Cassandra")
+		logger.Ctx(ctx).Error().Err(err).Msg("Error deleting job")
 		return nil, status.Errorf(codes.Internal, "Failed to delete job")
 	}
+	s.recordAudit(ctx, pb.AuditAction_AUDIT_ACTION_DELETE, job, jobAuditFields(job), jobAuditFields(nil))
 
 	return &pb.DeleteJobResponse{Success: true}, nil
 }
 
 func (s *Service) CancelJob(ctx context.Context, req *pb.CancelJobRequest) (*pb.CancelJobResponse, error) {
-	id, err := gocql.ParseUUID(req.Id)
+	job, err := s.getNamespacedJob(ctx, req.Namespace, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "Invalid job ID")
-	}
-
-	job, err := models.GetJob(s.cassandraClient, id)
-	if err != nil {
-		if err == gocql.ErrNotFound {
-			return nil, status.Errorf(codes.NotFound, "Job not found")
-		}
-		logger.Error().Err(err).Msg("Error retrieving job from Cassandra")
-		return nil, status.Errorf(codes.Internal, "Failed to retrieve job")
+		return nil, err
 	}
 
 	if job.Status == pb.JobStatus_COMPLETED.String() || job.Status == pb.JobStatus_FAILED.String() || job.Status == pb.JobStatus_CANCELLED.String() {
 		return nil, status.Errorf(codes.FailedPrecondition, "Cannot cancel job with status: %s", job.Status)
 	}
 
+	before := jobAuditFields(job)
 	job.Status = pb.JobStatus_CANCELLED.String()
 	job.UpdatedAt = time.Now()
 
-	err = models.UpdateJob(s.cassandraClient, job)
+	err = s.jobs.UpdateJob(ctx, job)
 	if err != nil {
-		logger.Error().Err(err).Msg("Error updating job in Cassandra")
+		logger.Ctx(ctx).Error().Err(err).Msg("Error updating job")
 		return nil, status.Errorf(codes.Internal, "Failed to cancel job")
 	}
+	s.recordAudit(ctx, pb.AuditAction_AUDIT_ACTION_CANCEL, job, before, jobAuditFields(job))
 
 	return &pb.CancelJobResponse{
 		Success: true,
 		Message: fmt.Sprintf("Job %s has been cancelled", job.ID),
 	}, nil
 }
+
+// TriggerJob dispatches a run of a job now through the outbox, taking its
+// resources and quota the way the scheduler does for a due run. The run does
+// not move the job's next scheduled run.
+func (s *Service) TriggerJob(ctx context.Context, req *pb.TriggerJobRequest) (*pb.TriggerJobResponse, error) {
+	job, err := s.getNamespacedJob(ctx, req.Namespace, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == pb.JobStatus_CANCELLED.String() {
+		return nil, status.Errorf(codes.FailedPrecondition, "Cannot trigger job with status: %s", job.Status)
+	}
+
+	now := time.Now()
+	run := &scheduler.ScheduledJob{
+		IdempotencyKey: gocql.TimeUUID().String(),
+		JobID:          uuid.FromStringOrNil(job.ID.String()),
+		StartTime:      now,
+		ScheduledTime:  now,
+		Priority:       job.Priority,
+		Namespace:      job.Namespace,
+		TriggerSource:  executionpb.TriggerSource_TRIGGER_SOURCE_MANUAL.String(),
+	}
+	entry, err := scheduler.NewOutboxEntry(s.cfg, run)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error building outbox entry")
+		return nil, status.Errorf(codes.Internal, "Failed to trigger job")
+	}
+	entry.TraceContext = tracing.Inject(ctx)
+
+	if err := s.reserveTriggeredRun(ctx, job, run.IdempotencyKey, now); err != nil {
+		return nil, err
+	}
+	if _, err := s.executions.CreateOutboxEntry(ctx, entry); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Error writing outbox entry")
+		s.releaseTriggeredRun(ctx, job, run.IdempotencyKey)
+		return nil, status.Errorf(codes.Internal, "Failed to trigger job")
+	}
+	s.recordAudit(ctx, pb.AuditAction_AUDIT_ACTION_TRIGGER, job, nil, nil)
+
+	return &pb.TriggerJobResponse{
+		Success: true,
+		Message: fmt.Sprintf("Job %s has been triggered", job.ID),
+	}, nil
+}
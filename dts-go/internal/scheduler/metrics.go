@@ -0,0 +1,12 @@
+package scheduler
+
+import "github.com/nedson202/dts-go/pkg/metrics"
+
+var (
+	tickDuration = metrics.NewHistogramVec("dts_scheduler_tick_duration_seconds",
+		"Time taken by one pass over the jobs that are due.", nil)
+	lastTickTimestamp = metrics.NewGaugeVec("dts_scheduler_last_tick_timestamp_seconds",
+		"Unix time the last pass over due jobs finished; alert when it stops advancing.")
+	scheduledJobs = metrics.NewCounterVec("dts_scheduler_jobs_total",
+		"Due jobs processed by the scheduler, by outcome: due, scheduled, deferred, skipped or failed.", "outcome")
+)
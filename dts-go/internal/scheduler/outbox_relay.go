@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/store"
+	"github.com/nedson202/dts-go/pkg/tracing"
+)
+
+const (
+	outboxBatchSize     = 100
+	outboxLeaseDuration = 30 * time.Second
+)
+
+// OutboxRelay publishes pending outbox entries to the queue and marks them sent.
+// Delivery is at-least-once: an entry published just before a crash is
+// published again once its lease expires.
+type OutboxRelay struct {
+	executions   store.ExecutionStore
+	queueManager *QueueManager
+	interval     time.Duration
+}
+
+func NewOutboxRelay(executions store.ExecutionStore, queueManager *QueueManager, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		executions:   executions,
+		queueManager: queueManager,
+		interval:     interval,
+	}
+}
+
+func (r *OutboxRelay) Start(ctx context.Context) {
+	logger.Info().Msgf("Starting outbox relay with interval: %v", r.interval)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("Outbox relay stopped due to context cancellation")
+			return
+		case <-ticker.C:
+			r.RelayPending(ctx)
+		}
+	}
+}
+
+// RelayPending publishes every pending entry across all shards and returns the
+// number of entries sent.
+func (r *OutboxRelay) RelayPending(ctx context.Context) int {
+	sent := 0
+	for shard := 0; shard < models.OutboxShards; shard++ {
+		entries, err := r.executions.ListPendingOutboxEntries(ctx, shard, outboxBatchSize)
+		if err != nil {
+			logger.Error().Err(err).Msgf("Error listing outbox entries for shard %d", shard)
+			continue
+		}
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return sent
+			}
+			if r.relay(ctx, entry) {
+				sent++
+			}
+		}
+	}
+	if sent > 0 {
+		logger.Info().Msgf("Relayed %d outbox entries", sent)
+	}
+	return sent
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, entry *models.OutboxEntry) bool {
+	now := time.Now()
+	if entry.ClaimedUntil.After(now) {
+		return false
+	}
+
+	// Publish under the trace of the dispatch that wrote the entry.
+	ctx = tracing.Extract(ctx, entry.TraceContext)
+
+	claimed, err := r.executions.ClaimOutboxEntry(ctx, entry, now, now.Add(outboxLeaseDuration))
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error claiming outbox entry %s", entry.ID)
+		return false
+	}
+	if !claimed {
+		return false
+	}
+
+	if err := r.queueManager.Publish(ctx, entry); err != nil {
+		// Leave the entry pending; it is retried once the lease expires.
+		return false
+	}
+
+	if err := r.executions.MarkOutboxEntrySent(ctx, entry, time.Now()); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error marking outbox entry %s as sent", entry.ID)
+		return false
+	}
+	return true
+}
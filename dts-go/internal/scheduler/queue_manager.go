@@ -5,43 +5,81 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/gocql/gocql"
 	"github.com/nedson202/dts-go/pkg/config"
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
 	"github.com/nedson202/dts-go/pkg/queue"
 )
 
+// Jobs at or above highPriorityThreshold go to the high priority topic and
+// jobs below lowPriorityThreshold to the low priority one; everything else
+// uses the regular task topic.
+const (
+	highPriorityThreshold = 5
+	lowPriorityThreshold  = 0
+)
+
 type QueueManager struct {
-	kafkaClient *queue.KafkaClient
+	producer queue.Producer
+	cfg      *config.Config
 }
 
-func NewQueueManager(kafkaClient *queue.KafkaClient) *QueueManager {
+func NewQueueManager(producer queue.Producer, cfg *config.Config) *QueueManager {
 	return &QueueManager{
-		kafkaClient: kafkaClient,
+		producer: producer,
+		cfg:      cfg,
 	}
 }
 
-func (qm *QueueManager) EnqueueJob(ctx context.Context, job *ScheduledJob) error {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
-	}
+// NewOutboxEntry builds the outbox entry that will dispatch job to the task
+// topic once relayed.
+func (qm *QueueManager) NewOutboxEntry(job *ScheduledJob) (*models.OutboxEntry, error) {
+	return NewOutboxEntry(qm.cfg, job)
+}
 
+// NewOutboxEntry builds the outbox entry that will dispatch job to the task
+// topic of its priority once relayed, for callers that dispatch runs without
+// a scheduler.
+func NewOutboxEntry(cfg *config.Config, job *ScheduledJob) (*models.OutboxEntry, error) {
 	jobJSON, err := json.Marshal(job)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to marshal job")
-		return fmt.Errorf("failed to marshal job: %v", err)
+		return nil, fmt.Errorf("failed to marshal job: %v", err)
 	}
 
-	err = qm.kafkaClient.Produce(ctx, cfg.TaskTopic, []byte(job.IdempotencyKey), jobJSON)
+	// The idempotency key identifies the dispatch, so it doubles as the entry ID.
+	id, err := gocql.ParseUUID(job.IdempotencyKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid idempotency key %q: %w", job.IdempotencyKey, err)
+	}
+
+	return models.NewOutboxEntry(id, taskTopicForPriority(cfg, job.Priority), []byte(job.IdempotencyKey), jobJSON), nil
+}
+
+func taskTopicForPriority(cfg *config.Config, priority int) string {
+	switch {
+	case priority >= highPriorityThreshold:
+		return cfg.TaskHighPriorityTopic
+	case priority < lowPriorityThreshold:
+		return cfg.TaskLowPriorityTopic
+	default:
+		return cfg.TaskTopic
+	}
+}
+
+// Publish sends an outbox entry to the queue.
+func (qm *QueueManager) Publish(ctx context.Context, entry *models.OutboxEntry) error {
+	err := qm.producer.Produce(ctx, entry.Topic, entry.Key, entry.Payload)
 	if err != nil {
-		logger.Error().Err(err).Msgf("Failed to publish job %s to Kafka", job.JobID)
-		return fmt.Errorf("failed to publish job to Kafka: %v", err)
+		logger.Ctx(ctx).Error().Err(err).Msgf("Failed to publish outbox entry %s", entry.ID)
+		return fmt.Errorf("failed to publish job: %v", err)
 	}
 
-	logger.Info().Msgf("Job enqueued successfully %s", job.JobID)
+	logger.Ctx(ctx).Info().Msgf("Outbox entry %s published to %s", entry.ID, entry.Topic)
 	return nil
 }
 
 func (qm *QueueManager) Close() error {
-	return qm.kafkaClient.Close()
+	return qm.producer.Close()
 }
@@ -2,56 +2,91 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofrs/uuid"
-	"github.com/nedson202/dts-go/pkg/client"
 	"github.com/nedson202/dts-go/pkg/config"
-	"github.com/nedson202/dts-go/pkg/database"
 	"github.com/nedson202/dts-go/pkg/logger"
 	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/store"
+	"github.com/nedson202/dts-go/pkg/tracing"
+	"github.com/nedson202/dts-go/pkg/utils"
 	jobpb "github.com/nedson202/dts-go/proto/job/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type Scheduler struct {
-	cassandraClient *database.CassandraClient
-	checkInterval   time.Duration
-	queueManager    *QueueManager
-	jobClient       *client.JobClient
-}
+// errInsufficientResources is returned by scheduleJob when the resource pool
+// cannot fit a job right now; the job stays due and is retried next tick.
+var errInsufficientResources = errors.New("insufficient resources")
 
-func NewScheduler(cassandraClient *database.CassandraClient, checkInterval time.Duration, queueManager *QueueManager) (*Scheduler, error) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
-	}
+// errConcurrencyQuotaExceeded is returned by scheduleJob when the namespace of
+// a job already has as many unfinished executions as its quota allows; the
+// job stays due and is retried next tick.
+var errConcurrencyQuotaExceeded = errors.New("concurrent execution quota exceeded")
 
-	jobServiceAddr := cfg.JobServiceAddr
-	if jobServiceAddr == "" {
-		return nil, fmt.Errorf("job service address is not set")
-	}
+// errRateQuotaExceeded is returned by scheduleJob when the namespace of a job
+// has used up its executions for the hour; the occurrence is skipped.
+var errRateQuotaExceeded = errors.New("hourly execution quota exceeded")
 
-	jobClient, err := client.NewJobClient(jobServiceAddr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create job client: %w", err)
-	}
+// ReloadableSettings are the settings Reconfigure applies to a running
+// scheduler.
+var ReloadableSettings = []string{"scheduler.check_interval", "scheduler.batch_size"}
+
+type Scheduler struct {
+	jobs         store.JobStore
+	executions   store.ExecutionStore
+	queueManager *QueueManager
+	defaultQuota models.Quota
+	// checkInterval and batchSize may be changed by Reconfigure while the
+	// loop runs; intervalChanged tells the loop to pick up a new interval.
+	checkInterval   atomic.Int64
+	batchSize       atomic.Int64
+	intervalChanged chan struct{}
+	// lastTick is the Unix time in nanoseconds the loop last finished a pass
+	// over due jobs, or started.
+	lastTick atomic.Int64
+}
 
-	// Create a scheduler without the job client first
+func NewScheduler(db store.Store, queueManager *QueueManager, cfg *config.Config) *Scheduler {
 	scheduler := &Scheduler{
-		cassandraClient: cassandraClient,
-		checkInterval:   checkInterval,
+		jobs:            db,
+		executions:      db,
 		queueManager:    queueManager,
-		jobClient:       jobClient,
+		defaultQuota:    models.DefaultQuota(cfg),
+		intervalChanged: make(chan struct{}, 1),
 	}
+	scheduler.checkInterval.Store(int64(cfg.Scheduler.CheckInterval))
+	scheduler.batchSize.Store(int64(cfg.Scheduler.BatchSize))
 
-	logger.Info().Msgf("Initializing Scheduler with check interval: %v", checkInterval)
-	return scheduler, nil
+	logger.Info().Msgf("Initializing Scheduler with check interval %v and batch size %d", cfg.Scheduler.CheckInterval, cfg.Scheduler.BatchSize)
+	return scheduler
+}
+
+// Reconfigure applies the reloadable settings of cfg. A pass over due jobs
+// that is under way finishes with the old batch size; a new check interval
+// restarts the wait for the next pass.
+func (s *Scheduler) Reconfigure(cfg *config.Config) {
+	s.batchSize.Store(int64(cfg.Scheduler.BatchSize))
+	if previous := s.checkInterval.Swap(int64(cfg.Scheduler.CheckInterval)); previous != int64(cfg.Scheduler.CheckInterval) {
+		select {
+		case s.intervalChanged <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Scheduler) interval() time.Duration {
+	return time.Duration(s.checkInterval.Load())
 }
 
 func (s *Scheduler) Start(ctx context.Context) {
 	logger.Info().Msg("Starting Scheduler")
-	ticker := time.NewTicker(s.checkInterval)
+	s.lastTick.Store(time.Now().UnixNano())
+	ticker := time.NewTicker(s.interval())
 	defer ticker.Stop()
 
 	for {
@@ -59,84 +94,277 @@ func (s *Scheduler) Start(ctx context.Context) {
 		case <-ctx.Done():
 			logger.Info().Msg("Scheduler stopped due to context cancellation")
 			return
+		case <-s.intervalChanged:
+			logger.Info().Msgf("Scheduler check interval is now %v", s.interval())
+			ticker.Reset(s.interval())
 		case <-ticker.C:
 			logger.Info().Msg("Running periodic job check")
 			s.ProcessPendingJobs(ctx)
+			s.lastTick.Store(time.Now().UnixNano())
 		}
 	}
 }
 
-func (s *Scheduler) ProcessPendingJobs(ctx context.Context) error {
-	if s.jobClient == nil {
-		logger.Warn().Msg("Job client not yet connected. Skipping job processing.")
-		return nil
-	}
-
+func (s *Scheduler) ProcessPendingJobs(ctx context.Context) (err error) {
 	startTime := time.Now().Truncate(time.Minute)
-	logger.Info().Msg("Fetching pending jobs")
-	jobs, err := models.GetJobsDueForExecution(s.cassandraClient, 100) // Limit to 100 jobs per cycle
+	tickStart := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "scheduler tick")
+	defer func() { tracing.End(span, err) }()
+
+	// Jobs come highest priority first, so they get the available resources
+	// and are the ones a full batch holds
+	logger.Ctx(ctx).Info().Msg("Fetching pending jobs")
+	jobs, err := s.jobs.GetJobsDueForExecution(ctx, int(s.batchSize.Load()))
 	if err != nil {
-		logger.Error().Err(err).Msg("Error fetching pending jobs")
+		logger.Ctx(ctx).Error().Err(err).Msg("Error fetching pending jobs")
 		return err
 	}
-	logger.Info().Msgf("Found %d pending jobs", len(jobs))
+	span.SetAttributes(attribute.Int("dts.due_jobs", len(jobs)))
+	logger.Ctx(ctx).Info().Msgf("Found %d pending jobs", len(jobs))
+	scheduledJobs.Add(float64(len(jobs)), "due")
 
 	scheduledCount := 0
+	deferredCount := 0
+	skippedCount := 0
 	for _, job := range jobs {
-		logger.Info().Msgf("Processing job: %s", job.ID)
-		if err := s.scheduleJob(ctx, job); err != nil {
-			logger.Error().Err(err).Msgf("Error scheduling job %s", job.ID)
-		} else {
+		logger.Ctx(ctx).Info().Msgf("Processing job: %s", job.ID)
+		err := s.scheduleJob(ctx, job)
+		switch {
+		case errors.Is(err, errInsufficientResources):
+			logger.Ctx(ctx).Info().Msgf("Deferring job %s: requires %+v, not available", job.ID, job.Resources)
+			s.releaseDeferredJob(ctx, job)
+			deferredCount++
+			scheduledJobs.Inc("deferred")
+		case errors.Is(err, errConcurrencyQuotaExceeded):
+			logger.Ctx(ctx).Info().Msgf("Deferring job %s: namespace %s is at its concurrent execution quota", job.ID, job.Namespace)
+			s.releaseDeferredJob(ctx, job)
+			deferredCount++
+			scheduledJobs.Inc("deferred")
+		case errors.Is(err, errRateQuotaExceeded):
+			logger.Ctx(ctx).Warn().Msgf("Skipped run of job %s: namespace %s is at its hourly execution quota", job.ID, job.Namespace)
+			skippedCount++
+			scheduledJobs.Inc("skipped")
+		case err != nil:
+			logger.Ctx(ctx).Error().Err(err).Msgf("Error scheduling job %s", job.ID)
+			scheduledJobs.Inc("failed")
+		default:
 			scheduledCount++
+			scheduledJobs.Inc("scheduled")
 		}
 	}
 
+	tickDuration.Observe(time.Since(tickStart).Seconds())
+	lastTickTimestamp.Set(float64(time.Now().Unix()))
+
 	duration := time.Since(startTime)
-	logger.Info().Msgf("Periodic job check completed. Scheduled %d out of %d jobs. Duration: %v", scheduledCount, len(jobs), duration)
+	logger.Ctx(ctx).Info().Msgf("Periodic job check completed. Scheduled %d out of %d jobs, deferred %d, skipped %d. Duration: %v", scheduledCount, len(jobs), deferredCount, skippedCount, duration)
 	return nil
 }
 
-func (s *Scheduler) scheduleJob(ctx context.Context, job *models.Job) error {
-	// Update the job status to SCHEDULED
-	_, err := s.jobClient.UpdateJob(ctx, job.ID.String(), jobpb.JobStatus_SCHEDULED, time.Time{})
+// CheckTicking returns an error unless the loop has finished a pass over due
+// jobs, successful or not, within the last three check intervals.
+func (s *Scheduler) CheckTicking(ctx context.Context) error {
+	lastTick := s.lastTick.Load()
+	if lastTick == 0 {
+		return fmt.Errorf("scheduler loop has not started")
+	}
+	if age := time.Since(time.Unix(0, lastTick)); age > 3*s.interval() {
+		return fmt.Errorf("scheduler loop last ticked %v ago", age.Truncate(time.Second))
+	}
+	return nil
+}
+
+// scheduleJob dispatches the occurrence of a job that is due at job.NextRun.
+// The dispatch message is written to the outbox first, keyed by the job and
+// its fire time, and next_run is then advanced from that fire time with a
+// compare-and-set. Both steps are idempotent, so a crash in between or a
+// concurrent scheduler leads to exactly one outbox entry per occurrence, which
+// the OutboxRelay publishes to the queue. Jobs that declare resource requirements
+// reserve them from the global pool first and are deferred if they do not fit;
+// the execution service releases the reservation once the run is over.
+// Namespace quotas are checked before anything is taken: a job whose namespace
+// is at its concurrency limit is deferred, one over its hourly limit has the
+// occurrence skipped. The concurrency slot itself is taken atomically with the
+// dispatch record, after the resources.
+//
+// Each occurrence starts a trace of its own, which the outbox entry carries on
+// to the execution service. It links to the scheduler tick and to the API call
+// that created the job.
+func (s *Scheduler) scheduleJob(ctx context.Context, job *models.Job) (err error) {
+	links := []trace.Link{trace.LinkFromContext(ctx)}
+	if link, ok := tracing.Link(job.TraceContext); ok {
+		links = append(links, link)
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "dispatch job",
+		trace.WithNewRoot(),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("dts.namespace", job.Namespace),
+			attribute.String("dts.job_id", job.ID.String()),
+			attribute.String("dts.scheduled_time", job.NextRun.UTC().Format(time.RFC3339)),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	jobID := uuid.FromStringOrNil(job.ID.String())
+	scheduledTime := job.NextRun
+	scheduledJob := &ScheduledJob{
+		IdempotencyKey: uuid.NewV5(jobID, scheduledTime.UTC().Format(time.RFC3339Nano)).String(),
+		JobID:          jobID,
+		StartTime:      time.Now(),
+		ScheduledTime:  scheduledTime,
+		Priority:       job.Priority,
+		Namespace:      job.Namespace,
+	}
+	entry, err := s.queueManager.NewOutboxEntry(scheduledJob)
 	if err != nil {
-		logger.Error().Err(err).Msgf("Error updating job %s to SCHEDULED", job.ID)
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error building outbox entry for job %s", job.ID)
 		return err
 	}
+	entry.TraceContext = tracing.Inject(ctx)
 
-	idempotencyKey, err := uuid.NewV4()
+	// Occurrences missed while the scheduler was behind, e.g. down, are not
+	// caught up one by one: this run stands in for all of them and the job
+	// continues from its next occurrence after now
+	nextRun, err := utils.CalculateNextRun(job.CronExpression, latest(scheduledTime, time.Now()))
 	if err != nil {
-		logger.Error().Err(err).Msgf("Error generating unique ID for job %s", job.ID)
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error calculating next run time for job %s", job.ID)
 		return err
 	}
-
-	// Use QueueManager to enqueue the job
-	scheduledJob := &ScheduledJob{
-		IdempotencyKey: idempotencyKey.String(),
-		JobID:          uuid.FromStringOrNil(job.ID.String()),
-		StartTime:      time.Now(),
+	if missedRun, err := utils.CalculateNextRun(job.CronExpression, scheduledTime); err == nil && missedRun.Before(nextRun) {
+		logger.Ctx(ctx).Warn().Msgf("Job %s missed occurrences between %v and %v, running it once for them", job.ID, scheduledTime, nextRun)
 	}
-	err = s.queueManager.EnqueueJob(ctx, scheduledJob)
+
+	recorded, err := s.executions.IsDispatchRecorded(ctx, job.Namespace, scheduledJob.IdempotencyKey)
 	if err != nil {
-		logger.Error().Err(err).Msgf("Error enqueueing job %s", job.ID)
-		// Revert the job status to PENDING if enqueueing fails
-		revertErr := s.revertJobStatus(ctx, job.ID.String(), jobpb.JobStatus_PENDING)
-		if revertErr != nil {
-			logger.Error().Err(revertErr).Msgf("Failed to revert job %s status to PENDING", job.ID)
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error checking dispatch of job %s", job.ID)
+		return err
+	}
+	var quota models.Quota
+	if !recorded {
+		quota, err = s.checkDispatchQuota(ctx, job)
+		if err != nil {
+			if errors.Is(err, errRateQuotaExceeded) {
+				s.skipOccurrence(ctx, job, scheduledTime, nextRun)
+			}
+			return err
+		}
+	}
+
+	// Reservations are keyed by the idempotency key, so retrying after a crash
+	// reuses the capacity already taken for this occurrence.
+	if !job.Resources.IsZero() {
+		reserved, err := s.executions.ReserveResources(ctx, scheduledJob.IdempotencyKey, job.Resources)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Msgf("Error reserving resources for job %s", job.ID)
+			return err
+		}
+		if !reserved {
+			return errInsufficientResources
+		}
+	}
+
+	// The concurrency quota is only enforced here, where taking a slot and
+	// checking that one is free are a single step
+	if !recorded {
+		acquired, err := s.executions.AcquireDispatch(ctx, job.Namespace, scheduledJob.IdempotencyKey, job.ID, time.Now(), quota.MaxConcurrentExecutions)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Msgf("Error recording dispatch of job %s", job.ID)
+			return err
+		}
+		if !acquired {
+			if err := s.executions.ReleaseResources(ctx, scheduledJob.IdempotencyKey); err != nil {
+				logger.Ctx(ctx).Error().Err(err).Msgf("Error releasing resources of deferred job %s", job.ID)
+			}
+			return errConcurrencyQuotaExceeded
 		}
+	}
+
+	if _, err := s.executions.CreateOutboxEntry(ctx, entry); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error writing outbox entry for job %s", job.ID)
 		return err
 	}
 
+	job.Status = jobpb.JobStatus_SCHEDULED.String()
+	job.UpdatedAt = time.Now()
+	job.NextRun = nextRun
+	advanced, err := s.jobs.AdvanceJobNextRun(ctx, job, scheduledTime)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error updating job %s to SCHEDULED", job.ID)
+		return err
+	}
+	if !advanced {
+		logger.Ctx(ctx).Info().Msgf("Job %s was already advanced past %v", job.ID, scheduledTime)
+	}
+
 	return nil
 }
 
-func (s *Scheduler) revertJobStatus(ctx context.Context, jobID string, status jobpb.JobStatus) error {
-	_, err := s.jobClient.UpdateJob(ctx, jobID, status, time.Time{})
-	return err
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// checkDispatchQuota returns the quota of the namespace of a job after
+// checking its hourly execution limit. It also turns jobs away early when the
+// namespace is visibly at its concurrency limit, which AcquireDispatch
+// enforces.
+func (s *Scheduler) checkDispatchQuota(ctx context.Context, job *models.Job) (models.Quota, error) {
+	quota, err := s.jobs.GetQuota(ctx, job.Namespace, s.defaultQuota)
+	if err != nil {
+		return quota, fmt.Errorf("error retrieving quota: %w", err)
+	}
+
+	if quota.MaxConcurrentExecutions > 0 {
+		running, err := s.executions.CountRunningExecutions(ctx, job.Namespace)
+		if err != nil {
+			return quota, fmt.Errorf("error counting running executions: %w", err)
+		}
+		if running >= quota.MaxConcurrentExecutions {
+			return quota, errConcurrencyQuotaExceeded
+		}
+	}
+
+	if quota.MaxExecutionsPerHour > 0 {
+		executions, err := s.executions.GetHourlyExecutions(ctx, job.Namespace, time.Now())
+		if err != nil {
+			return quota, fmt.Errorf("error counting hourly executions: %w", err)
+		}
+		if executions >= quota.MaxExecutionsPerHour {
+			return quota, errRateQuotaExceeded
+		}
+	}
+	return quota, nil
+}
+
+// releaseDeferredJob lets the next pass of any scheduler retry a deferred job
+// instead of waiting for the claim on it to expire.
+func (s *Scheduler) releaseDeferredJob(ctx context.Context, job *models.Job) {
+	if err := s.jobs.ReleaseDueJob(ctx, job.ID); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error releasing deferred job %s", job.ID)
+	}
+}
+
+// skipOccurrence advances the next run of a job past an occurrence without dispatching it.
+func (s *Scheduler) skipOccurrence(ctx context.Context, job *models.Job, scheduledTime, nextRun time.Time) {
+	job.UpdatedAt = time.Now()
+	job.NextRun = nextRun
+	if _, err := s.jobs.AdvanceJobNextRun(ctx, job, scheduledTime); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msgf("Error skipping run of job %s at %v", job.ID, scheduledTime)
+	}
 }
 
 type ScheduledJob struct {
 	IdempotencyKey string
 	JobID          uuid.UUID
-	StartTime time.Time
+	StartTime      time.Time
+	// ScheduledTime is the logical fire time of the occurrence being dispatched.
+	ScheduledTime time.Time
+	Priority      int
+	Namespace     string
+	// TriggerSource is the name of the TriggerSource value recorded on the
+	// execution; it is left empty for scheduled runs.
+	TriggerSource string `json:",omitempty"`
 }
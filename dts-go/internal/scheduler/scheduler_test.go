@@ -0,0 +1,450 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/config"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/store"
+	jobpb "github.com/nedson202/dts-go/proto/job/v1"
+)
+
+type testScheduler struct {
+	*Scheduler
+	db    store.Store
+	queue *queue.MemoryQueue
+	relay *OutboxRelay
+	cfg   *config.Config
+}
+
+func newTestScheduler(t *testing.T) *testScheduler {
+	t.Helper()
+	return newTestSchedulerWithStore(t, store.NewMemoryStore())
+}
+
+func newTestSchedulerWithStore(t *testing.T, db store.Store) *testScheduler {
+	t.Helper()
+	cfg := config.Default()
+	q := queue.NewMemoryQueue()
+	queueManager := NewQueueManager(q, cfg)
+	return &testScheduler{
+		Scheduler: NewScheduler(db, queueManager, cfg),
+		db:        db,
+		queue:     q,
+		relay:     NewOutboxRelay(db, queueManager, cfg.Scheduler.OutboxRelayInterval),
+		cfg:       cfg,
+	}
+}
+
+// forEachStore runs test against a scheduler on each backend that can run
+// in the test process.
+func forEachStore(t *testing.T, test func(t *testing.T, s *testScheduler)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, newTestScheduler(t))
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := store.OpenSQLite(filepath.Join(t.TempDir(), "dts.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(db.Close)
+		test(t, newTestSchedulerWithStore(t, db))
+	})
+}
+
+// createDueJob stores job with its next run set to dueAgo before now.
+func (s *testScheduler) createDueJob(t *testing.T, job *models.Job, dueAgo time.Duration) *models.Job {
+	t.Helper()
+	ctx := context.Background()
+	job.ID = gocql.TimeUUID()
+	job.Status = jobpb.JobStatus_PENDING.String()
+	if job.CronExpression == "" {
+		job.CronExpression = "0 3 * * *"
+	}
+	if err := s.db.CreateJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.SetJobNextRun(ctx, job.ID, time.Now().Add(-dueAgo).Truncate(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	created, err := s.db.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// pendingEntries returns the outbox entries not relayed yet.
+func (s *testScheduler) pendingEntries(t *testing.T) []*models.OutboxEntry {
+	t.Helper()
+	var entries []*models.OutboxEntry
+	for shard := 0; shard < models.OutboxShards; shard++ {
+		shardEntries, err := s.db.ListPendingOutboxEntries(context.Background(), shard, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, shardEntries...)
+	}
+	return entries
+}
+
+func (s *testScheduler) getJob(t *testing.T, id gocql.UUID) *models.Job {
+	t.Helper()
+	job, err := s.db.GetJob(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func (s *testScheduler) running(t *testing.T) int {
+	t.Helper()
+	running, err := s.db.CountRunningExecutions(context.Background(), models.DefaultNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return running
+}
+
+func TestProcessPendingJobsDispatchesDueJobs(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	normal := s.createDueJob(t, &models.Job{Name: "normal"}, 2*time.Minute)
+	urgent := s.createDueJob(t, &models.Job{Name: "urgent", Priority: 7}, time.Minute)
+	future := &models.Job{ID: gocql.TimeUUID(), Name: "future", CronExpression: "0 3 * * *", Status: jobpb.JobStatus_PENDING.String()}
+	if err := s.db.CreateJob(ctx, future); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := s.pendingEntries(t)
+	if len(entries) != 2 {
+		t.Fatalf("got %d outbox entries, want 2", len(entries))
+	}
+	topics := map[string]string{}
+	for _, entry := range entries {
+		var scheduled ScheduledJob
+		if err := json.Unmarshal(entry.Payload, &scheduled); err != nil {
+			t.Fatal(err)
+		}
+		if entry.ID.String() != scheduled.IdempotencyKey {
+			t.Fatalf("outbox entry %s carries idempotency key %s", entry.ID, scheduled.IdempotencyKey)
+		}
+		topics[scheduled.JobID.String()] = entry.Topic
+	}
+	if topics[normal.ID.String()] != s.cfg.TaskTopic || topics[urgent.ID.String()] != s.cfg.TaskHighPriorityTopic {
+		t.Fatalf("got topics %v, want %s for the normal job and %s for the urgent one", topics, s.cfg.TaskTopic, s.cfg.TaskHighPriorityTopic)
+	}
+
+	for _, due := range []*models.Job{normal, urgent} {
+		job := s.getJob(t, due.ID)
+		if job.Status != jobpb.JobStatus_SCHEDULED.String() || !job.NextRun.After(time.Now()) {
+			t.Fatalf("job %s has status %s and next run %v, want SCHEDULED in the future", job.Name, job.Status, job.NextRun)
+		}
+	}
+	if job := s.getJob(t, future.ID); job.Status != jobpb.JobStatus_PENDING.String() {
+		t.Fatalf("job that is not due has status %s, want PENDING", job.Status)
+	}
+	if running := s.running(t); running != 2 {
+		t.Fatalf("got %d running executions, want 2", running)
+	}
+	if hourly, _ := s.db.GetHourlyExecutions(ctx, models.DefaultNamespace, time.Now()); hourly != 2 {
+		t.Fatalf("got %d executions this hour, want 2", hourly)
+	}
+}
+
+func TestScheduleJobRunsMissedOccurrencesOnce(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	// The hourly job was last due three hours ago
+	missed := s.createDueJob(t, &models.Job{Name: "hourly", CronExpression: "0 * * * *"}, 3*time.Hour)
+
+	if err := s.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	entries := s.pendingEntries(t)
+	if len(entries) != 1 {
+		t.Fatalf("got %d outbox entries, want 1", len(entries))
+	}
+	var scheduled ScheduledJob
+	if err := json.Unmarshal(entries[0].Payload, &scheduled); err != nil {
+		t.Fatal(err)
+	}
+	if !scheduled.ScheduledTime.Equal(missed.NextRun) {
+		t.Fatalf("got a run scheduled at %v, want the oldest missed occurrence %v", scheduled.ScheduledTime, missed.NextRun)
+	}
+
+	want := time.Now().Truncate(time.Hour).Add(time.Hour)
+	if job := s.getJob(t, missed.ID); !job.NextRun.Equal(want) {
+		t.Fatalf("got next run %v, want the next occurrence after now %v", job.NextRun, want)
+	}
+
+	// Nothing else is due until then
+	if err := s.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if entries := s.pendingEntries(t); len(entries) != 1 {
+		t.Fatalf("got %d outbox entries after a second pass, want 1", len(entries))
+	}
+}
+
+func TestProcessPendingJobsFillsBatchByPriority(t *testing.T) {
+	forEachStore(t, testProcessPendingJobsFillsBatchByPriority)
+}
+
+func testProcessPendingJobsFillsBatchByPriority(t *testing.T, s *testScheduler) {
+	ctx := context.Background()
+	s.batchSize.Store(2)
+	low := s.createDueJob(t, &models.Job{Name: "low", Priority: -1}, 10*time.Minute)
+	older := s.createDueJob(t, &models.Job{Name: "older"}, 5*time.Minute)
+	newer := s.createDueJob(t, &models.Job{Name: "newer"}, time.Minute)
+	high := s.createDueJob(t, &models.Job{Name: "high", Priority: 5}, time.Minute)
+
+	if err := s.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch takes the highest priority job and, among equals, the one due
+	// longest, even though the low priority job has waited longer still
+	for _, job := range []*models.Job{high, older} {
+		if got := s.getJob(t, job.ID); got.Status != jobpb.JobStatus_SCHEDULED.String() {
+			t.Fatalf("job %s has status %s, want SCHEDULED", job.Name, got.Status)
+		}
+	}
+	for _, job := range []*models.Job{low, newer} {
+		if got := s.getJob(t, job.ID); got.Status != jobpb.JobStatus_PENDING.String() {
+			t.Fatalf("job %s has status %s, want PENDING", job.Name, got.Status)
+		}
+	}
+}
+
+func TestScheduleJobDispatchesAnOccurrenceOnce(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	due := s.createDueJob(t, &models.Job{Name: "nightly", Resources: models.Resources{CPU: 10}}, time.Minute)
+
+	// Two schedulers that fetched the same occurrence race to dispatch it
+	first, second := s.getJob(t, due.ID), s.getJob(t, due.ID)
+	if err := s.scheduleJob(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.scheduleJob(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries := s.pendingEntries(t); len(entries) != 1 {
+		t.Fatalf("got %d outbox entries, want 1", len(entries))
+	}
+	if running := s.running(t); running != 1 {
+		t.Fatalf("got %d running executions, want 1", running)
+	}
+	pool, err := s.db.GetResourcePool(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.Reservations) != 1 || pool.Available.CPU != 90 {
+		t.Fatalf("got reservations %v leaving %d CPU, want one of 10 CPU", pool.Reservations, pool.Available.CPU)
+	}
+
+	// The losing scheduler did not move the next run again
+	if job := s.getJob(t, due.ID); !job.NextRun.Equal(first.NextRun) {
+		t.Fatalf("got next run %v, want %v", job.NextRun, first.NextRun)
+	}
+	advanced, err := s.db.AdvanceJobNextRun(ctx, second, due.NextRun)
+	if err != nil || advanced {
+		t.Fatalf("advancing from a stale next run: got %v, %v, want false", advanced, err)
+	}
+}
+
+func TestProcessPendingJobsDefersWhenResourcesRunOut(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	big := s.createDueJob(t, &models.Job{Name: "big", Resources: models.Resources{CPU: 80}}, 2*time.Minute)
+	bigger := s.createDueJob(t, &models.Job{Name: "bigger", Resources: models.Resources{CPU: 30}}, time.Minute)
+
+	if err := s.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries := s.pendingEntries(t); len(entries) != 1 {
+		t.Fatalf("got %d outbox entries, want 1", len(entries))
+	}
+	if job := s.getJob(t, big.ID); job.Status != jobpb.JobStatus_SCHEDULED.String() {
+		t.Fatalf("job that fits has status %s, want SCHEDULED", job.Status)
+	}
+	// The deferred job stays due and is not counted against the quota
+	if job := s.getJob(t, bigger.ID); !job.NextRun.Equal(bigger.NextRun) || job.Status != jobpb.JobStatus_PENDING.String() {
+		t.Fatalf("deferred job has status %s and next run %v, want PENDING at %v", job.Status, job.NextRun, bigger.NextRun)
+	}
+	if running := s.running(t); running != 1 {
+		t.Fatalf("got %d running executions, want 1", running)
+	}
+}
+
+func TestDeferredJobIsDueAgainAtOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s *testScheduler) {
+		ctx := context.Background()
+		huge := s.createDueJob(t, &models.Job{Name: "huge", Resources: models.Resources{CPU: 200}}, time.Minute)
+
+		if err := s.ProcessPendingJobs(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		// A claim taken on the job is released rather than left to expire
+		jobs, err := s.db.GetJobsDueForExecution(ctx, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 1 || jobs[0].ID != huge.ID {
+			t.Fatalf("got due jobs %v after deferring, want the deferred job", jobs)
+		}
+	})
+}
+
+func TestProcessPendingJobsDefersAtConcurrencyQuota(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	if err := s.db.SetQuota(ctx, models.DefaultNamespace, models.Quota{MaxConcurrentExecutions: 1}); err != nil {
+		t.Fatal(err)
+	}
+	first := s.createDueJob(t, &models.Job{Name: "first", Resources: models.Resources{CPU: 10}}, 2*time.Minute)
+	second := s.createDueJob(t, &models.Job{Name: "second", Resources: models.Resources{CPU: 10}}, time.Minute)
+
+	if err := s.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if job := s.getJob(t, first.ID); job.Status != jobpb.JobStatus_SCHEDULED.String() {
+		t.Fatalf("first job has status %s, want SCHEDULED", job.Status)
+	}
+	if job := s.getJob(t, second.ID); !job.NextRun.Equal(second.NextRun) {
+		t.Fatalf("deferred job has next run %v, want %v", job.NextRun, second.NextRun)
+	}
+	pool, err := s.db.GetResourcePool(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.Reservations) != 1 {
+		t.Fatalf("got reservations %v, want only that of the dispatched job", pool.Reservations)
+	}
+
+	// The deferred job goes out once the running one is released
+	entries := s.pendingEntries(t)
+	if len(entries) != 1 {
+		t.Fatalf("got %d outbox entries, want 1", len(entries))
+	}
+	if err := s.db.ReleaseDispatch(ctx, models.DefaultNamespace, string(entries[0].Key)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if job := s.getJob(t, second.ID); job.Status != jobpb.JobStatus_SCHEDULED.String() {
+		t.Fatalf("deferred job has status %s after the release, want SCHEDULED", job.Status)
+	}
+}
+
+func TestProcessPendingJobsSkipsAtHourlyQuota(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	if err := s.db.SetQuota(ctx, models.DefaultNamespace, models.Quota{MaxExecutionsPerHour: 1}); err != nil {
+		t.Fatal(err)
+	}
+	s.createDueJob(t, &models.Job{Name: "first"}, 2*time.Minute)
+	second := s.createDueJob(t, &models.Job{Name: "second"}, time.Minute)
+
+	if err := s.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries := s.pendingEntries(t); len(entries) != 1 {
+		t.Fatalf("got %d outbox entries, want 1", len(entries))
+	}
+	// The skipped occurrence is not dispatched later
+	job := s.getJob(t, second.ID)
+	if !job.NextRun.After(time.Now()) || job.Status != jobpb.JobStatus_PENDING.String() {
+		t.Fatalf("skipped job has status %s and next run %v, want PENDING in the future", job.Status, job.NextRun)
+	}
+}
+
+func TestSentOutboxEntryStillDeduplicates(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s *testScheduler) {
+		ctx := context.Background()
+		s.createDueJob(t, &models.Job{Name: "nightly", Namespace: models.DefaultNamespace}, time.Minute)
+		if err := s.ProcessPendingJobs(ctx); err != nil {
+			t.Fatal(err)
+		}
+		entries := s.pendingEntries(t)
+		if len(entries) != 1 {
+			t.Fatalf("got %d outbox entries, want 1", len(entries))
+		}
+
+		// The entry was sent a few hours ago, longer than a run usually takes
+		sent := *entries[0]
+		if err := s.db.MarkOutboxEntrySent(ctx, &sent, time.Now().Add(-3*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		s.pendingEntries(t)
+
+		created, err := s.db.CreateOutboxEntry(ctx, entries[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created || len(s.pendingEntries(t)) != 0 {
+			t.Fatal("the entry of an occurrence sent 3 hours ago was written again")
+		}
+	})
+}
+
+func TestOutboxRelayPublishesEntriesOnce(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(t)
+	due := s.createDueJob(t, &models.Job{Name: "nightly", Namespace: models.DefaultNamespace}, time.Minute)
+
+	consumer, err := s.queue.NewConsumer("test", s.cfg.TaskTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	consumer.Consume()
+
+	if err := s.ProcessPendingJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if sent := s.relay.RelayPending(ctx); sent != 1 {
+		t.Fatalf("relayed %d entries, want 1", sent)
+	}
+	if sent := s.relay.RelayPending(ctx); sent != 0 {
+		t.Fatalf("relayed %d entries again, want 0", sent)
+	}
+	if entries := s.pendingEntries(t); len(entries) != 0 {
+		t.Fatalf("got %d pending entries after relaying, want 0", len(entries))
+	}
+
+	select {
+	case message := <-consumer.Messages():
+		var scheduled ScheduledJob
+		if err := json.Unmarshal(message.Value, &scheduled); err != nil {
+			t.Fatal(err)
+		}
+		if scheduled.JobID.String() != due.ID.String() || !scheduled.ScheduledTime.Equal(due.NextRun) || scheduled.Namespace != models.DefaultNamespace {
+			t.Fatalf("got %+v, want the occurrence of job %s at %v", scheduled, due.ID, due.NextRun)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message was published")
+	}
+	select {
+	case message := <-consumer.Messages():
+		t.Fatalf("got a second message %s", message.Value)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
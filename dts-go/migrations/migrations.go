@@ -0,0 +1,10 @@
+// Package migrations embeds the schema of the SQL storage backends, one
+// directory of .sql files per dialect, applied in name order.
+//
+// The Cassandra .cql files next to it are read by cmd/migrate at run time.
+package migrations
+
+import "embed"
+
+//go:embed postgres/*.sql sqlite/*.sql
+var SQL embed.FS
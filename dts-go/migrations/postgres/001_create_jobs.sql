@@ -0,0 +1,31 @@
+-- Migration: Create the jobs table
+-- Filename: 001_create_jobs.sql
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id uuid PRIMARY KEY,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    cron_expression text NOT NULL,
+    status text NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL,
+    last_run timestamptz,
+    next_run timestamptz NOT NULL,
+    metadata jsonb,
+    cpu integer NOT NULL DEFAULT 0,
+    memory integer NOT NULL DEFAULT 0,
+    storage integer NOT NULL DEFAULT 0,
+    priority integer NOT NULL DEFAULT 0,
+    namespace text NOT NULL,
+    trace_context jsonb,
+    -- Lease a scheduler takes on a due job so that concurrent schedulers
+    -- claim different jobs
+    claimed_until timestamptz NOT NULL DEFAULT 'epoch'
+);
+
+-- The scheduler looks up due jobs by next_run
+CREATE INDEX IF NOT EXISTS jobs_next_run_idx ON jobs (next_run);
+
+-- Jobs are listed per namespace in id order, optionally by status
+CREATE INDEX IF NOT EXISTS jobs_namespace_id_idx ON jobs (namespace, id);
+CREATE INDEX IF NOT EXISTS jobs_namespace_status_idx ON jobs (namespace, status, id);
@@ -0,0 +1,55 @@
+-- Migration: Create the execution tables
+-- Filename: 002_create_executions.sql
+
+-- created_at is the time encoded in the time-based id, which executions are
+-- listed by, newest first
+CREATE TABLE IF NOT EXISTS job_executions (
+    id uuid PRIMARY KEY,
+    job_id uuid NOT NULL,
+    namespace text NOT NULL,
+    created_at timestamptz NOT NULL,
+    status text NOT NULL,
+    start_time timestamptz NOT NULL,
+    end_time timestamptz,
+    result text NOT NULL DEFAULT '',
+    error text NOT NULL DEFAULT '',
+    worker_id text NOT NULL DEFAULT '',
+    attempts integer NOT NULL DEFAULT 0,
+    scheduled_time timestamptz NOT NULL,
+    trigger_source text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS job_executions_namespace_idx ON job_executions (namespace, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS job_executions_namespace_status_idx ON job_executions (namespace, status, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS job_executions_job_idx ON job_executions (job_id, created_at DESC, id DESC);
+
+CREATE TABLE IF NOT EXISTS execution_attempts (
+    execution_id uuid NOT NULL,
+    attempt integer NOT NULL,
+    job_id uuid NOT NULL,
+    status text NOT NULL,
+    start_time timestamptz NOT NULL,
+    end_time timestamptz,
+    worker_id text NOT NULL DEFAULT '',
+    error text NOT NULL DEFAULT '',
+    PRIMARY KEY (execution_id, attempt)
+);
+
+CREATE TABLE IF NOT EXISTS execution_logs (
+    execution_id uuid NOT NULL,
+    seq bigint NOT NULL,
+    stream text NOT NULL,
+    timestamp timestamptz NOT NULL,
+    line text NOT NULL,
+    PRIMARY KEY (execution_id, seq)
+);
+
+CREATE TABLE IF NOT EXISTS execution_heartbeats (
+    execution_id uuid PRIMARY KEY,
+    job_id uuid NOT NULL,
+    worker_id text NOT NULL DEFAULT '',
+    idempotency_key text NOT NULL,
+    retry_count integer NOT NULL DEFAULT 0,
+    start_time timestamptz NOT NULL,
+    heartbeat_at timestamptz NOT NULL
+);
@@ -0,0 +1,46 @@
+-- Migration: Create the namespace, quota, role binding and audit tables
+-- Filename: 004_create_namespace_tables.sql
+
+CREATE TABLE IF NOT EXISTS namespaces (
+    name text PRIMARY KEY,
+    description text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+-- Jobs created without a namespace live in the default namespace
+INSERT INTO namespaces (name, description, created_at, updated_at)
+VALUES ('default', 'Default namespace', now(), now())
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS namespace_quotas (
+    namespace text PRIMARY KEY,
+    max_jobs integer NOT NULL DEFAULT 0,
+    min_schedule_interval_seconds integer NOT NULL DEFAULT 0,
+    max_concurrent_executions integer NOT NULL DEFAULT 0,
+    max_executions_per_hour integer NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS role_bindings (
+    namespace text NOT NULL,
+    subject text NOT NULL,
+    role text NOT NULL,
+    created_at timestamptz NOT NULL,
+    created_by text NOT NULL DEFAULT '',
+    PRIMARY KEY (namespace, subject)
+);
+
+-- created_at is the time encoded in the time-based id
+CREATE TABLE IF NOT EXISTS audit_events (
+    id uuid PRIMARY KEY,
+    namespace text NOT NULL,
+    created_at timestamptz NOT NULL,
+    job_id uuid NOT NULL,
+    action text NOT NULL,
+    actor text NOT NULL DEFAULT '',
+    request_id text NOT NULL DEFAULT '',
+    changes jsonb
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_namespace_idx ON audit_events (namespace, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS audit_events_job_idx ON audit_events (job_id, created_at DESC, id DESC);
@@ -0,0 +1,9 @@
+-- Migration: Create the table dispatches of a namespace are serialized on
+-- Filename: 005_create_dispatch_locks.sql
+
+-- AcquireDispatch locks the row of a namespace while it counts the running
+-- dispatches and records a new one, so that concurrent schedulers cannot
+-- together exceed the concurrency quota
+CREATE TABLE IF NOT EXISTS namespace_dispatch_locks (
+    namespace text PRIMARY KEY
+);
@@ -0,0 +1,34 @@
+-- Migration: Create the jobs table
+-- Filename: 001_create_jobs.sql
+
+-- Times are stored as text in UTC, e.g. '2024-01-02 15:04:05+00:00', so that
+-- they compare in time order
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id text PRIMARY KEY,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    cron_expression text NOT NULL,
+    status text NOT NULL,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    last_run timestamp,
+    next_run timestamp NOT NULL,
+    metadata text,
+    cpu integer NOT NULL DEFAULT 0,
+    memory integer NOT NULL DEFAULT 0,
+    storage integer NOT NULL DEFAULT 0,
+    priority integer NOT NULL DEFAULT 0,
+    namespace text NOT NULL,
+    trace_context text,
+    -- Lease a scheduler takes on a due job so that concurrent schedulers
+    -- claim different jobs
+    claimed_until timestamp NOT NULL DEFAULT '1970-01-01 00:00:00+00:00'
+);
+
+-- The scheduler looks up due jobs by next_run
+CREATE INDEX IF NOT EXISTS jobs_next_run_idx ON jobs (next_run);
+
+-- Jobs are listed per namespace in id order, optionally by status
+CREATE INDEX IF NOT EXISTS jobs_namespace_id_idx ON jobs (namespace, id);
+CREATE INDEX IF NOT EXISTS jobs_namespace_status_idx ON jobs (namespace, status, id);
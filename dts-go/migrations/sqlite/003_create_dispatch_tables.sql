@@ -0,0 +1,54 @@
+-- Migration: Create the tables the scheduler dispatches jobs through
+-- Filename: 003_create_dispatch_tables.sql
+
+-- Dispatches holding a concurrency slot of their namespace. Rows older than
+-- a day are ignored and cleaned up when the namespace dispatches again.
+CREATE TABLE IF NOT EXISTS namespace_running_executions (
+    namespace text NOT NULL,
+    idempotency_key text NOT NULL,
+    job_id text NOT NULL,
+    dispatched_at timestamp NOT NULL,
+    PRIMARY KEY (namespace, idempotency_key)
+);
+
+CREATE TABLE IF NOT EXISTS namespace_hourly_executions (
+    namespace text NOT NULL,
+    hour timestamp NOT NULL,
+    executions integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (namespace, hour)
+);
+
+CREATE TABLE IF NOT EXISTS available_resources (
+    id text PRIMARY KEY,
+    cpu integer NOT NULL,
+    memory integer NOT NULL,
+    storage integer NOT NULL
+);
+
+INSERT INTO available_resources (id, cpu, memory, storage)
+VALUES ('global', 100, 1024000, 1024000)
+ON CONFLICT (id) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS resource_reservations (
+    id text PRIMARY KEY,
+    cpu integer NOT NULL,
+    memory integer NOT NULL,
+    storage integer NOT NULL
+);
+
+-- Messages the scheduler intends to publish to Kafka; see the Cassandra
+-- outbox migration. Sent entries are deleted after an hour by the relay.
+CREATE TABLE IF NOT EXISTS outbox (
+    id text PRIMARY KEY,
+    shard integer NOT NULL,
+    topic text NOT NULL,
+    message_key blob,
+    payload blob,
+    created_at timestamp NOT NULL,
+    claimed_until timestamp NOT NULL DEFAULT '1970-01-01 00:00:00+00:00',
+    sent_at timestamp,
+    trace_context text
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (shard, id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_sent_at_idx ON outbox (sent_at) WHERE sent_at IS NOT NULL;
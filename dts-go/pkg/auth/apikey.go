@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// APIKeyAuthenticator accepts a fixed set of API keys, each mapped to the
+// subject it authenticates as.
+type APIKeyAuthenticator struct {
+	// keys is indexed by the SHA-256 of the key so lookups do not leak the
+	// key through timing.
+	keys map[[sha256.Size]byte]string
+}
+
+// NewAPIKeyAuthenticator parses "subject:key" entries.
+func NewAPIKeyAuthenticator(entries []string) (*APIKeyAuthenticator, error) {
+	keys := make(map[[sha256.Size]byte]string, len(entries))
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		subject, key, ok := strings.Cut(entry, ":")
+		if !ok || subject == "" || key == "" {
+			return nil, fmt.Errorf("invalid API key entry %d, expected subject:key", i+1)
+		}
+		keys[sha256.Sum256([]byte(key))] = subject
+	}
+	return &APIKeyAuthenticator{keys: keys}, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context) (*Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(APIKeyMetadataKey)
+	if len(values) == 0 || values[0] == "" {
+		return nil, ErrNoCredentials
+	}
+
+	digest := sha256.Sum256([]byte(values[0]))
+	for known, subject := range a.keys {
+		if subtle.ConstantTimeCompare(known[:], digest[:]) == 1 {
+			return &Identity{Subject: subject, Method: MethodAPIKey}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nedson202/dts-go/pkg/config"
+)
+
+// Metadata keys callers present their credentials in. grpc-gateway forwards
+// the Authorization header on its own; the API key header is forwarded by
+// middleware.GatewayHeaderMatcher.
+const (
+	AuthorizationMetadataKey = "authorization"
+	APIKeyMetadataKey        = "x-api-key"
+)
+
+var (
+	// ErrNoCredentials is returned when the request carries no credentials
+	// the authenticator understands.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when credentials were presented but
+	// could not be verified.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator resolves the caller of a request from its incoming gRPC
+// metadata.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (*Identity, error)
+}
+
+// Chain tries each authenticator in turn and returns the first identity. A
+// request is only rejected as unauthenticated by the chain when no
+// authenticator found credentials it understands.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context) (*Identity, error) {
+	for _, authenticator := range c {
+		identity, err := authenticator.Authenticate(ctx)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return identity, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// NewAuthenticator builds the authenticator configured for the services. It
+// returns nil when authentication is disabled.
+func NewAuthenticator(cfg *config.Config) (Authenticator, error) {
+	if !cfg.AuthEnabled {
+		return nil, nil
+	}
+
+	var chain Chain
+	if len(cfg.AuthAPIKeys) > 0 {
+		apiKeys, err := NewAPIKeyAuthenticator(cfg.AuthAPIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, apiKeys)
+	}
+	if cfg.AuthJWKSFile != "" || cfg.AuthJWKSURL != "" {
+		jwt, err := NewJWTAuthenticator(JWTConfig{
+			JWKSFile: cfg.AuthJWKSFile,
+			JWKSURL:  cfg.AuthJWKSURL,
+			Issuer:   cfg.AuthJWTIssuer,
+			Audience: cfg.AuthJWTAudience,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, jwt)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("authentication is enabled but neither API keys nor a JWKS are configured")
+	}
+	return chain, nil
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+)
+
+type staticCredentials map[string]string
+
+// NewAPIKeyCredentials attaches an API key to every outgoing RPC.
+func NewAPIKeyCredentials(apiKey string) credentials.PerRPCCredentials {
+	return staticCredentials{APIKeyMetadataKey: apiKey}
+}
+
+// NewBearerCredentials attaches a bearer token to every outgoing RPC.
+func NewBearerCredentials(token string) credentials.PerRPCCredentials {
+	return staticCredentials{AuthorizationMetadataKey: "Bearer " + token}
+}
+
+func (c staticCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return c, nil
+}
+
+// RequireTransportSecurity is false so credentials can still be sent to
+// services that listen without TLS.
+func (c staticCredentials) RequireTransportSecurity() bool {
+	return false
+}
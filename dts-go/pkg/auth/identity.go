@@ -0,0 +1,30 @@
+package auth
+
+import "context"
+
+// Authentication methods recorded on an Identity.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Identity is the authenticated caller of an RPC.
+type Identity struct {
+	Subject string
+	Method  string
+	// Claims holds the verified JWT claims; it is nil for API keys.
+	Claims map[string]interface{}
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying the caller identity.
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the caller identity attached by the auth interceptors.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
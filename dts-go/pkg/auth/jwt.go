@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// jwtLeeway tolerates clock skew between the issuer and the services.
+const jwtLeeway = time.Minute
+
+// JWTConfig configures bearer token validation.
+type JWTConfig struct {
+	// JWKSFile or JWKSURL locate the issuer's signing keys; the file wins
+	// when both are set.
+	JWKSFile string
+	JWKSURL  string
+	// Issuer and Audience are checked against the iss and aud claims when
+	// set.
+	Issuer   string
+	Audience string
+}
+
+// JWTAuthenticator accepts RS* and ES* signed bearer tokens.
+type JWTAuthenticator struct {
+	keys     *keySet
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+func NewJWTAuthenticator(cfg JWTConfig) (*JWTAuthenticator, error) {
+	var keys *keySet
+	switch {
+	case cfg.JWKSFile != "":
+		keys = newFileKeySet(cfg.JWKSFile)
+	case cfg.JWKSURL != "":
+		keys = newURLKeySet(cfg.JWKSURL)
+	default:
+		return nil, fmt.Errorf("a JWKS file or URL is required")
+	}
+
+	// Load eagerly so a misconfigured key set fails at startup rather than on
+	// the first request.
+	if err := keys.refresh(); err != nil {
+		return nil, err
+	}
+
+	return &JWTAuthenticator{
+		keys:     keys,
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		now:      time.Now,
+	}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(ctx context.Context) (*Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AuthorizationMetadataKey)
+	if len(values) == 0 {
+		return nil, ErrNoCredentials
+	}
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &Identity{Subject: subject, Method: MethodJWT, Claims: claims}, nil
+}
+
+func (a *JWTAuthenticator) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("decoding header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+	key, err := a.keys.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("decoding claims: %w", err)
+	}
+	if err := a.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (a *JWTAuthenticator) validateClaims(claims map[string]interface{}) error {
+	now := a.now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token not valid yet")
+	}
+	if subject, _ := claims["sub"].(string); subject == "" {
+		return fmt.Errorf("token has no subject")
+	}
+	if a.issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != a.issuer {
+			return fmt.Errorf("unexpected issuer %q", issuer)
+		}
+	}
+	if a.audience != "" && !hasAudience(claims["aud"], a.audience) {
+		return fmt.Errorf("token is not intended for audience %q", a.audience)
+	}
+	return nil
+}
+
+// hasAudience reports whether the aud claim, a string or a list of strings,
+// contains audience.
+func hasAudience(claim interface{}, audience string) bool {
+	switch aud := claim.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// esCurves maps each ES algorithm to the curve its keys must be on.
+var esCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signingInput, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	hasher := hash.New()
+	hasher.Write(signingInput)
+	digest := hasher.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' {
+			return fmt.Errorf("algorithm %s does not match an RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		if alg[0] != 'E' {
+			return fmt.Errorf("algorithm %s does not match an EC key", alg)
+		}
+		// Each ES algorithm is defined for one curve only
+		if curve := pub.Curve.Params().Name; curve != esCurves[alg] {
+			return fmt.Errorf("algorithm %s does not match a key on curve %s", alg, curve)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,361 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+var testNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+type testKeys struct {
+	rsa  *rsa.PrivateKey
+	p256 *ecdsa.PrivateKey
+	p384 *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKeys{rsa: rsaKey, p256: p256, p384: p384}
+}
+
+// jwks returns the JWKS document publishing the public keys.
+func (k *testKeys) jwks() []byte {
+	encode := func(n *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(n.Bytes())
+	}
+	ec := func(kid, crv string, key *ecdsa.PrivateKey) jsonWebKey {
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return jsonWebKey{
+			Kty: "EC", Kid: kid, Crv: crv,
+			X: base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y: base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}
+	}
+	data, _ := json.Marshal(map[string][]jsonWebKey{"keys": {
+		{Kty: "RSA", Kid: "rsa", Use: "sig", N: encode(k.rsa.N), E: encode(big.NewInt(int64(k.rsa.E)))},
+		ec("p256", "P-256", k.p256),
+		ec("p384", "P-384", k.p384),
+	}})
+	return data
+}
+
+// sign returns a token with the given header and claims, signed for alg
+// with key.
+func sign(t *testing.T, header, claims map[string]interface{}, alg string, key crypto.Signer) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signingInput := segment(header) + "." + segment(claims)
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	default:
+		hash = crypto.SHA512
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signingInput))
+	digest := hasher.Sum(nil)
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "alice",
+		"iss": "https://issuer.example",
+		"aud": []string{"dts", "other"},
+		"exp": testNow.Add(time.Hour).Unix(),
+		"nbf": testNow.Add(-time.Hour).Unix(),
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks := keys.jwks()
+
+	with := func(change func(claims map[string]interface{})) map[string]interface{} {
+		claims := validClaims()
+		change(claims)
+		return claims
+	}
+	header := func(alg, kid string) map[string]interface{} {
+		return map[string]interface{}{"alg": alg, "kid": kid, "typ": "JWT"}
+	}
+	unsigned := func(alg string) string {
+		token := sign(t, header("RS256", "rsa"), validClaims(), "RS256", keys.rsa)
+		data, _ := json.Marshal(header(alg, "rsa"))
+		return base64.RawURLEncoding.EncodeToString(data) + token[strings.Index(token, "."):strings.LastIndex(token, ".")] + "."
+	}
+	tamper := func(token string, part int) string {
+		parts := strings.Split(token, ".")
+		data, _ := base64.RawURLEncoding.DecodeString(parts[part])
+		data[len(data)-1] ^= 1
+		parts[part] = base64.RawURLEncoding.EncodeToString(data)
+		return strings.Join(parts, ".")
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		subject string
+		err     string
+	}{
+		{
+			name:    "RS256",
+			token:   sign(t, header("RS256", "rsa"), validClaims(), "RS256", keys.rsa),
+			subject: "alice",
+		},
+		{
+			name:    "ES256",
+			token:   sign(t, header("ES256", "p256"), validClaims(), "ES256", keys.p256),
+			subject: "alice",
+		},
+		{
+			name:    "ES384",
+			token:   sign(t, header("ES384", "p384"), validClaims(), "ES384", keys.p384),
+			subject: "alice",
+		},
+		{
+			name:    "within leeway of expiry",
+			token:   sign(t, header("RS256", "rsa"), with(func(c map[string]interface{}) { c["exp"] = testNow.Add(-30 * time.Second).Unix() }), "RS256", keys.rsa),
+			subject: "alice",
+		},
+		{
+			name:  "alg none",
+			token: unsigned("none"),
+			err:   `unsupported signing algorithm "none"`,
+		},
+		{
+			name:  "HMAC with a public key",
+			token: unsigned("HS256"),
+			err:   `unsupported signing algorithm "HS256"`,
+		},
+		{
+			name:  "RSA algorithm with an EC key",
+			token: sign(t, header("RS256", "p256"), validClaims(), "ES256", keys.p256),
+			err:   "does not match an EC key",
+		},
+		{
+			name:  "EC algorithm with an RSA key",
+			token: sign(t, header("ES256", "rsa"), validClaims(), "RS256", keys.rsa),
+			err:   "does not match an RSA key",
+		},
+		{
+			name:  "EC algorithm with a key on another curve",
+			token: sign(t, header("ES256", "p384"), validClaims(), "ES384", keys.p384),
+			err:   "does not match a key on curve P-384",
+		},
+		{
+			name:  "unknown kid",
+			token: sign(t, header("RS256", "missing"), validClaims(), "RS256", keys.rsa),
+			err:   `unknown key ID "missing"`,
+		},
+		{
+			name:  "tampered signature",
+			token: tamper(sign(t, header("RS256", "rsa"), validClaims(), "RS256", keys.rsa), 2),
+			err:   "invalid signature",
+		},
+		{
+			name:  "tampered EC signature",
+			token: tamper(sign(t, header("ES256", "p256"), validClaims(), "ES256", keys.p256), 2),
+			err:   "invalid signature",
+		},
+		{
+			name:  "tampered claims",
+			token: tamper(sign(t, header("RS256", "rsa"), validClaims(), "RS256", keys.rsa), 1),
+			err:   "invalid signature",
+		},
+		{
+			name:  "signed by another key",
+			token: sign(t, header("ES256", "p256"), validClaims(), "ES256", keys.p384),
+			err:   "invalid signature",
+		},
+		{
+			name:  "expired",
+			token: sign(t, header("RS256", "rsa"), with(func(c map[string]interface{}) { c["exp"] = testNow.Add(-2 * time.Minute).Unix() }), "RS256", keys.rsa),
+			err:   "token expired",
+		},
+		{
+			name:  "no expiry",
+			token: sign(t, header("RS256", "rsa"), with(func(c map[string]interface{}) { delete(c, "exp") }), "RS256", keys.rsa),
+			err:   "token has no expiry",
+		},
+		{
+			name:  "not valid yet",
+			token: sign(t, header("RS256", "rsa"), with(func(c map[string]interface{}) { c["nbf"] = testNow.Add(2 * time.Minute).Unix() }), "RS256", keys.rsa),
+			err:   "token not valid yet",
+		},
+		{
+			name:  "no subject",
+			token: sign(t, header("RS256", "rsa"), with(func(c map[string]interface{}) { delete(c, "sub") }), "RS256", keys.rsa),
+			err:   "token has no subject",
+		},
+		{
+			name:  "wrong issuer",
+			token: sign(t, header("RS256", "rsa"), with(func(c map[string]interface{}) { c["iss"] = "https://evil.example" }), "RS256", keys.rsa),
+			err:   `unexpected issuer "https://evil.example"`,
+		},
+		{
+			name:  "wrong audience",
+			token: sign(t, header("RS256", "rsa"), with(func(c map[string]interface{}) { c["aud"] = "other" }), "RS256", keys.rsa),
+			err:   `not intended for audience "dts"`,
+		},
+		{
+			name:  "no audience",
+			token: sign(t, header("RS256", "rsa"), with(func(c map[string]interface{}) { delete(c, "aud") }), "RS256", keys.rsa),
+			err:   `not intended for audience "dts"`,
+		},
+		{
+			name:  "malformed",
+			token: "not-a-token",
+			err:   "malformed token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := &JWTAuthenticator{
+				keys:     &keySet{load: func() ([]byte, error) { return jwks, nil }},
+				issuer:   "https://issuer.example",
+				audience: "dts",
+				now:      func() time.Time { return testNow },
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMetadataKey, "Bearer "+tt.token))
+
+			identity, err := authenticator.Authenticate(ctx)
+			if tt.err != "" {
+				if !errors.Is(err, ErrInvalidCredentials) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want invalid credentials containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if identity.Subject != tt.subject || identity.Method != MethodJWT {
+				t.Fatalf("got identity %+v, want subject %q", identity, tt.subject)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorWithoutBearerToken(t *testing.T) {
+	authenticator := &JWTAuthenticator{keys: &keySet{}, now: time.Now}
+	for _, value := range []string{"", "Basic dXNlcjpwYXNz", "Bearer"} {
+		md := metadata.MD{}
+		if value != "" {
+			md.Set(AuthorizationMetadataKey, value)
+		}
+		_, err := authenticator.Authenticate(metadata.NewIncomingContext(context.Background(), md))
+		if !errors.Is(err, ErrNoCredentials) {
+			t.Errorf("Authorization %q: got error %v, want no credentials", value, err)
+		}
+	}
+}
+
+func TestKeySetReloadsUnknownKeysOutsideLock(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks := keys.jwks()
+
+	var mu sync.Mutex
+	loads := 0
+	release := make(chan struct{})
+	set := &keySet{load: func() ([]byte, error) {
+		mu.Lock()
+		loads++
+		first := loads == 1
+		mu.Unlock()
+		if !first {
+			<-release
+		}
+		return jwks, nil
+	}}
+	if err := set.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	set.lastLoaded = time.Time{}
+
+	// Misses reload the set once between them while it is blocked
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			set.key("missing")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Known keys are still served while the reload is in flight
+	done := make(chan error, 1)
+	go func() {
+		_, err := set.key("rsa")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lookup of a known key waited for the reload")
+	}
+
+	close(release)
+	wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if loads != 2 {
+		t.Fatalf("key set loaded %d times, want 2", loads)
+	}
+}
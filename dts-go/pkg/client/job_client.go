@@ -6,9 +6,11 @@ import (
 	"time"
 
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/tracing"
 	pb "github.com/nedson202/dts-go/proto/job/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -17,7 +19,10 @@ type JobClient struct {
 	conn   *grpc.ClientConn
 }
 
-func NewJobClient(jobServiceAddr string) (*JobClient, error) {
+// NewJobClient dials the job service, retrying until it is reachable. opts
+// are added to the dial options, e.g. to attach credentials; transport
+// credentials among them replace the plaintext default.
+func NewJobClient(jobServiceAddr string, opts ...grpc.DialOption) (*JobClient, error) {
 	if jobServiceAddr == "" {
 		return nil, fmt.Errorf("job service address is not set")
 	}
@@ -29,9 +34,10 @@ func NewJobClient(jobServiceAddr string) (*JobClient, error) {
 
 	for {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		conn, err = grpc.DialContext(ctx, jobServiceAddr,
-			grpc.WithInsecure(),
+		conn, err = grpc.DialContext(ctx, jobServiceAddr, append([]grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
 			grpc.WithBlock(),
+			tracing.DialOption(),
 			grpc.WithConnectParams(grpc.ConnectParams{
 				Backoff: backoff.Config{
 					BaseDelay:  100 * time.Millisecond,
@@ -41,7 +47,7 @@ func NewJobClient(jobServiceAddr string) (*JobClient, error) {
 				},
 				MinConnectTimeout: 5 * time.Second,
 			}),
-		)
+		}, opts...)...)
 		cancel()
 
 		if err != nil {
@@ -67,7 +73,7 @@ func (c *JobClient) Close() error {
 	return nil
 }
 
-func (c *JobClient) UpdateJob(ctx context.Context, id string, status pb.JobStatus, lastRun time.Time) (*pb.JobResponse, error) {
+func (c *JobClient) UpdateJob(ctx context.Context, namespace, id string, status pb.JobStatus, lastRun time.Time) (*pb.JobResponse, error) {
 	// handle lastRun as optional
 	var lastRunPb *timestamppb.Timestamp
 	if !lastRun.IsZero() {
@@ -75,8 +81,9 @@ func (c *JobClient) UpdateJob(ctx context.Context, id string, status pb.JobStatu
 	}
 
 	return c.client.UpdateJob(ctx, &pb.UpdateJobRequest{
-		Id:     id,
-		Status:   status,
-		LastRun:  lastRunPb,
+		Id:        id,
+		Namespace: namespace,
+		Status:    status,
+		LastRun:   lastRunPb,
 	})
 }
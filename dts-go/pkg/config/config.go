@@ -1,77 +1,216 @@
+// Package config loads the configuration of the services.
+//
+// Settings are read, in increasing order of precedence, from the built-in
+// defaults, an optional YAML or TOML file, environment variables and
+// command-line flags. The result is validated once at startup and passed to
+// the components that need it.
 package config
 
 import (
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
-	"strings"
+	"time"
 )
 
+// ConfigFileEnv names the environment variable holding the path of the config
+// file when the -config flag is not given.
+const ConfigFileEnv = "DTS_CONFIG_FILE"
+
 type Config struct {
-	KafkaBrokers              []string
-	TaskTopic                 string
-	TaskRetryTopic            string
-	CassandraHosts            []string
-	CassandraKeyspace         string
-	SchedulerServicePort      string
-	ExecutionServiceGRPCPort  string
-	ExecutionServiceHTTPPort  string
-	JobServiceHost            string
-	JobServiceGRPCPort        string
-	JobServiceHTTPPort        string
-	SchedulerServiceGRPCPort  string
-	SchedulerServiceHTTPPort  string
-	CassandraDataRetentionDays int
-	JobServiceAddr            string
+	QueueBackend               string        `yaml:"queue_backend" toml:"queue_backend"`
+	KafkaBrokers               []string      `yaml:"kafka_brokers" toml:"kafka_brokers"`
+	TaskTopic                  string        `yaml:"task_topic" toml:"task_topic"`
+	TaskRetryTopic             string        `yaml:"task_retry_topic" toml:"task_retry_topic"`
+	TaskHighPriorityTopic      string        `yaml:"task_high_priority_topic" toml:"task_high_priority_topic"`
+	TaskLowPriorityTopic       string        `yaml:"task_low_priority_topic" toml:"task_low_priority_topic"`
+	TaskHighPriorityWeight     int           `yaml:"task_high_priority_weight" toml:"task_high_priority_weight"`
+	TaskNormalPriorityWeight   int           `yaml:"task_normal_priority_weight" toml:"task_normal_priority_weight"`
+	TaskLowPriorityWeight      int           `yaml:"task_low_priority_weight" toml:"task_low_priority_weight"`
+	StorageBackend             string        `yaml:"storage_backend" toml:"storage_backend"`
+	CassandraHosts             []string      `yaml:"cassandra_hosts" toml:"cassandra_hosts"`
+	CassandraKeyspace          string        `yaml:"cassandra_keyspace" toml:"cassandra_keyspace"`
+	PostgresDSN                string        `yaml:"postgres_dsn" toml:"postgres_dsn"`
+	SQLitePath                 string        `yaml:"sqlite_path" toml:"sqlite_path"`
+	SchedulerServicePort       string        `yaml:"scheduler_service_port" toml:"scheduler_service_port"`
+	ExecutionServiceGRPCPort   string        `yaml:"execution_service_grpc_port" toml:"execution_service_grpc_port"`
+	ExecutionServiceHTTPPort   string        `yaml:"execution_service_http_port" toml:"execution_service_http_port"`
+	JobServiceHost             string        `yaml:"job_service_host" toml:"job_service_host"`
+	JobServiceGRPCPort         string        `yaml:"job_service_grpc_port" toml:"job_service_grpc_port"`
+	JobServiceHTTPPort         string        `yaml:"job_service_http_port" toml:"job_service_http_port"`
+	SchedulerServiceGRPCPort   string        `yaml:"scheduler_service_grpc_port" toml:"scheduler_service_grpc_port"`
+	SchedulerServiceHTTPPort   string        `yaml:"scheduler_service_http_port" toml:"scheduler_service_http_port"`
+	CassandraDataRetentionDays int           `yaml:"cassandra_data_retention_days" toml:"cassandra_data_retention_days"`
+	JobServiceAddr             string        `yaml:"job_service_addr" toml:"job_service_addr"`
+	QuotaMaxJobs               int           `yaml:"quota_max_jobs" toml:"quota_max_jobs"`
+	QuotaMinScheduleInterval   time.Duration `yaml:"quota_min_schedule_interval" toml:"quota_min_schedule_interval"`
+	QuotaMaxConcurrentRuns     int           `yaml:"quota_max_concurrent_runs" toml:"quota_max_concurrent_runs"`
+	QuotaMaxRunsPerHour        int           `yaml:"quota_max_runs_per_hour" toml:"quota_max_runs_per_hour"`
+	AuthEnabled                bool          `yaml:"auth_enabled" toml:"auth_enabled"`
+	AuthAPIKeys                []string      `yaml:"auth_api_keys" toml:"auth_api_keys"`
+	AuthJWKSFile               string        `yaml:"auth_jwks_file" toml:"auth_jwks_file"`
+	AuthJWKSURL                string        `yaml:"auth_jwks_url" toml:"auth_jwks_url"`
+	AuthJWTIssuer              string        `yaml:"auth_jwt_issuer" toml:"auth_jwt_issuer"`
+	AuthJWTAudience            string        `yaml:"auth_jwt_audience" toml:"auth_jwt_audience"`
+	AuthServiceAPIKey          string        `yaml:"auth_service_api_key" toml:"auth_service_api_key"`
+	AuthAdminSubjects          []string      `yaml:"auth_admin_subjects" toml:"auth_admin_subjects"`
+	TLSCertFile                string        `yaml:"tls_cert_file" toml:"tls_cert_file"`
+	TLSKeyFile                 string        `yaml:"tls_key_file" toml:"tls_key_file"`
+	TLSCAFile                  string        `yaml:"tls_ca_file" toml:"tls_ca_file"`
+	TLSServerName              string        `yaml:"tls_server_name" toml:"tls_server_name"`
+	TLSReloadInterval          time.Duration `yaml:"tls_reload_interval" toml:"tls_reload_interval"`
+	TracingExporter            string        `yaml:"tracing_exporter" toml:"tracing_exporter"`
+	TracingOTLPEndpoint        string        `yaml:"tracing_otlp_endpoint" toml:"tracing_otlp_endpoint"`
+	TracingOTLPInsecure        bool          `yaml:"tracing_otlp_insecure" toml:"tracing_otlp_insecure"`
+	TracingFile                string        `yaml:"tracing_file" toml:"tracing_file"`
+	TracingSampleRatio         float64       `yaml:"tracing_sample_ratio" toml:"tracing_sample_ratio"`
+	LogLevel                   string        `yaml:"log_level" toml:"log_level"`
+	LogFormat                  string        `yaml:"log_format" toml:"log_format"`
+	LogRedactKeys              []string      `yaml:"log_redact_keys" toml:"log_redact_keys"`
+
+	Scheduler SchedulerConfig `yaml:"scheduler" toml:"scheduler"`
+	Execution ExecutionConfig `yaml:"execution" toml:"execution"`
+
+	// path and sets are the config file and -set overrides the configuration
+	// was loaded with, kept so that it can be reloaded the same way.
+	path string
+	sets []string
 }
 
-func LoadConfig() (*Config, error) {
-	config := &Config{
-		KafkaBrokers:              getEnvAsSlice("KAFKA_BROKERS", []string{"localhost:9092"}),
-		TaskTopic:                 getEnv("KAFKA_TASK_TOPIC", "jobs"),
-		TaskRetryTopic:            getEnv("KAFKA_TASK_RETRY_TOPIC", "jobs-retry"),
-		CassandraHosts:            getEnvAsSlice("CASSANDRA_HOSTS", []string{"localhost"}),
-		CassandraKeyspace:         getEnv("CASSANDRA_KEYSPACE", "task_scheduler"),
-		SchedulerServicePort:      getEnv("SCHEDULER_SERVICE_PORT", "50052"),
-		ExecutionServiceGRPCPort:  getEnv("EXECUTION_SERVICE_GRPC_PORT", "50053"),
-		ExecutionServiceHTTPPort:  getEnv("EXECUTION_SERVICE_HTTP_PORT", "8082"),
-		JobServiceHost:            getEnv("JOB_SERVICE_HOST", "localhost"),
-		JobServiceGRPCPort:        getEnv("JOB_SERVICE_GRPC_PORT", "50054"),
-		JobServiceHTTPPort:        getEnv("JOB_SERVICE_HTTP_PORT", "8080"),
-		SchedulerServiceGRPCPort:  getEnv("SCHEDULER_SERVICE_GRPC_PORT", "50052"),
-		SchedulerServiceHTTPPort:  getEnv("SCHEDULER_SERVICE_HTTP_PORT", "8081"),
-		CassandraDataRetentionDays: getEnvAsInt("CASSANDRA_DATA_RETENTION_DAYS", 30),
-		JobServiceAddr:            getEnv("JOB_SERVICE_ADDR", "localhost:50054"),
-	}
+// SchedulerConfig holds the settings of the scheduler service.
+type SchedulerConfig struct {
+	// CheckInterval is how often the scheduler looks for due jobs.
+	CheckInterval time.Duration `yaml:"check_interval" toml:"check_interval"`
+	// BatchSize is the most due jobs dispatched per check.
+	BatchSize int `yaml:"batch_size" toml:"batch_size"`
+	// OutboxRelayInterval is how often dispatched jobs are published to Kafka.
+	OutboxRelayInterval time.Duration `yaml:"outbox_relay_interval" toml:"outbox_relay_interval"`
+}
+
+// ExecutionConfig holds the settings of the execution service.
+type ExecutionConfig struct {
+	// WorkerID identifies this worker in executions and heartbeats.
+	WorkerID string `yaml:"worker_id" toml:"worker_id"`
+	// WorkerPoolSize is the most tasks each consumer runs at once.
+	WorkerPoolSize    int           `yaml:"worker_pool_size" toml:"worker_pool_size"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" toml:"heartbeat_interval"`
+	HeartbeatTimeout  time.Duration `yaml:"heartbeat_timeout" toml:"heartbeat_timeout"`
+	ReaperInterval    time.Duration `yaml:"reaper_interval" toml:"reaper_interval"`
+	Retry             RetryPolicy   `yaml:"retry" toml:"retry"`
+}
 
-	return config, nil
+// RetryPolicy controls how failed or lost executions are retried.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a run is re-enqueued before it is
+	// given up on.
+	MaxRetries int `yaml:"max_retries" toml:"max_retries"`
 }
 
-func getEnv(key, defaultValue string) string {
-	value, exists := os.LookupEnv(key)
-	if !exists {
-		return defaultValue
+// Default returns the configuration used when nothing overrides it.
+func Default() *Config {
+	return &Config{
+		QueueBackend:               "kafka",
+		KafkaBrokers:               []string{"localhost:9092"},
+		TaskTopic:                  "jobs",
+		TaskRetryTopic:             "jobs-retry",
+		TaskHighPriorityTopic:      "jobs-high",
+		TaskLowPriorityTopic:       "jobs-low",
+		TaskHighPriorityWeight:     6,
+		TaskNormalPriorityWeight:   3,
+		TaskLowPriorityWeight:      1,
+		StorageBackend:             "cassandra",
+		CassandraHosts:             []string{"localhost"},
+		CassandraKeyspace:          "task_scheduler",
+		SQLitePath:                 "dts.db",
+		SchedulerServicePort:       "50052",
+		ExecutionServiceGRPCPort:   "50053",
+		ExecutionServiceHTTPPort:   "8082",
+		JobServiceHost:             "localhost",
+		JobServiceGRPCPort:         "50054",
+		JobServiceHTTPPort:         "8080",
+		SchedulerServiceGRPCPort:   "50052",
+		SchedulerServiceHTTPPort:   "8081",
+		CassandraDataRetentionDays: 30,
+		JobServiceAddr:             "localhost:50054",
+		TLSReloadInterval:          30 * time.Second,
+		TracingExporter:            "none",
+		TracingOTLPEndpoint:        "localhost:4317",
+		TracingOTLPInsecure:        true,
+		TracingFile:                "traces.json",
+		TracingSampleRatio:         1,
+		LogLevel:                   "info",
+		LogFormat:                  "console",
+		Scheduler: SchedulerConfig{
+			CheckInterval:       time.Minute,
+			BatchSize:           100,
+			OutboxRelayInterval: time.Second,
+		},
+		Execution: ExecutionConfig{
+			WorkerID:          defaultWorkerID(),
+			WorkerPoolSize:    1,
+			HeartbeatInterval: 10 * time.Second,
+			HeartbeatTimeout:  60 * time.Second,
+			ReaperInterval:    30 * time.Second,
+			Retry: RetryPolicy{
+				MaxRetries: 3,
+			},
+		},
 	}
-	return value
 }
 
-func getEnvAsSlice(key string, defaultValue []string) []string {
-	value, exists := os.LookupEnv(key)
-	if !exists {
-		return defaultValue
+// LoadConfig loads the configuration using the command-line flags of the
+// process.
+func LoadConfig() (*Config, error) {
+	return Load(os.Args[1:])
+}
+
+// Load loads and validates the configuration. args are the command-line
+// flags: -config names the config file, and -set key=value, which may be
+// repeated, overrides a single setting by its key in the file, such as
+// scheduler.batch_size=50.
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	path := flags.String("config", os.Getenv(ConfigFileEnv), "path of a YAML or TOML config file")
+	var sets []string
+	flags.Func("set", "override a setting, as key=value", func(value string) error {
+		sets = append(sets, value)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return nil, err
 	}
-	return strings.Split(value, ",")
+
+	return load(*path, sets)
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	valueStr, exists := os.LookupEnv(key)
-	if !exists {
-		return defaultValue
+func load(path string, sets []string) (*Config, error) {
+	cfg := Default()
+	cfg.path = path
+	cfg.sets = sets
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
 	}
-	value, err := strconv.Atoi(valueStr)
+	for _, set := range sets {
+		if err := cfg.set(set); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// defaultWorkerID identifies this process when no worker ID is configured.
+func defaultWorkerID() string {
+	hostname, err := os.Hostname()
 	if err != nil {
-		fmt.Printf("Warning: Invalid integer value for %s, using default: %d\n", key, defaultValue)
-		return defaultValue
+		hostname = "unknown"
 	}
-	return value
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
 }
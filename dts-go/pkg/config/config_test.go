@@ -0,0 +1,212 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file named name to a temporary directory and
+// returns its path.
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv(ConfigFileEnv, "")
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := Diff(Default(), cfg); len(changes) != 0 {
+		t.Fatalf("got changes %+v from the defaults with nothing set", changes)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, "dts.yaml", `
+log_level: warn
+task_topic: file-topic
+scheduler:
+  batch_size: 10
+  check_interval: 30s
+execution:
+  retry:
+    max_retries: 5
+`)
+	t.Setenv(ConfigFileEnv, "")
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("SCHEDULER_BATCH_SIZE", "20")
+
+	cfg, err := Load([]string{"-config", path, "-set", "scheduler.batch_size=30", "-set", "kafka_brokers=[a:9092, b:9092]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		key       string
+		got, want interface{}
+	}{
+		{"task_topic, set only in the file", cfg.TaskTopic, "file-topic"},
+		{"scheduler.check_interval, set only in the file", cfg.Scheduler.CheckInterval, 30 * time.Second},
+		{"execution.retry.max_retries, set only in the file", cfg.Execution.Retry.MaxRetries, 5},
+		{"log_level, set in the file and the environment", cfg.LogLevel, "error"},
+		{"scheduler.batch_size, set everywhere", cfg.Scheduler.BatchSize, 30},
+		{"kafka_brokers, set only by -set", cfg.KafkaBrokers, []string{"a:9092", "b:9092"}},
+		{"task_retry_topic, set nowhere", cfg.TaskRetryTopic, Default().TaskRetryTopic},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.key, tc.got, tc.want)
+		}
+	}
+}
+
+func TestLoadConfigFlagOverridesEnvironment(t *testing.T) {
+	fromEnv := writeConfig(t, "env.yaml", "task_topic: env-file\n")
+	fromFlag := writeConfig(t, "flag.toml", "task_topic = \"flag-file\"\n")
+	t.Setenv(ConfigFileEnv, fromEnv)
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TaskTopic != "env-file" {
+		t.Errorf("with %s set: got task_topic %q, want env-file", ConfigFileEnv, cfg.TaskTopic)
+	}
+	cfg, err = Load([]string{"-config", fromFlag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TaskTopic != "flag-file" {
+		t.Errorf("with -config given: got task_topic %q, want flag-file", cfg.TaskTopic)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	t.Setenv(ConfigFileEnv, "")
+	for _, tc := range []struct {
+		name, file, data string
+		sets             []string
+		unknown          string
+	}{
+		{name: "yaml", file: "dts.yaml", data: "log_levle: debug\n", unknown: "log_levle"},
+		{name: "nested yaml", file: "dts.yml", data: "scheduler:\n  batch: 5\n", unknown: "batch"},
+		{name: "toml", file: "dts.toml", data: "[execution]\nworkers = 4\n", unknown: "execution.workers"},
+		{name: "set", sets: []string{"scheduler.batchsize=5"}, unknown: "batchsize"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var args []string
+			if tc.file != "" {
+				args = append(args, "-config", writeConfig(t, tc.file, tc.data))
+			}
+			for _, set := range tc.sets {
+				args = append(args, "-set", set)
+			}
+			_, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), tc.unknown) {
+				t.Fatalf("got %v, want an error naming %s", err, tc.unknown)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidInput(t *testing.T) {
+	t.Setenv(ConfigFileEnv, "")
+	for name, args := range map[string][]string{
+		"unsupported extension":  {"-config", writeConfig(t, "dts.json", "{}")},
+		"missing file":           {"-config", filepath.Join(t.TempDir(), "missing.yaml")},
+		"override without =":     {"-set", "log_level"},
+		"override of wrong type": {"-set", "scheduler.batch_size=many"},
+	} {
+		if _, err := Load(args); err == nil {
+			t.Errorf("%s: loaded without an error", name)
+		}
+	}
+}
+
+// TestLoadCollectsErrors checks that every bad setting is reported at once
+// rather than only the first.
+func TestLoadCollectsErrors(t *testing.T) {
+	t.Setenv(ConfigFileEnv, "")
+	t.Setenv("SCHEDULER_BATCH_SIZE", "many")
+	t.Setenv("AUTH_ENABLED", "perhaps")
+	t.Setenv("TRACING_SAMPLE_RATIO", "half")
+	_, err := Load(nil)
+	if err == nil {
+		t.Fatal("loaded an environment with invalid values")
+	}
+	for _, key := range []string{"SCHEDULER_BATCH_SIZE", "AUTH_ENABLED", "TRACING_SAMPLE_RATIO"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not name %s", err, key)
+		}
+	}
+
+	cfg := Default()
+	cfg.QueueBackend = "rabbitmq"
+	cfg.StorageBackend = "postgres"
+	cfg.JobServiceGRPCPort = "70000"
+	cfg.TracingSampleRatio = 2
+	cfg.Scheduler.BatchSize = 0
+	cfg.Execution.HeartbeatTimeout = cfg.Execution.HeartbeatInterval
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("validated a configuration with invalid values")
+	}
+	for _, key := range []string{"queue_backend", "postgres_dsn", "job_service_grpc_port", "tracing_sample_ratio", "scheduler.batch_size", "execution.heartbeat_timeout"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not name %s", err, key)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old, new := Default(), Default()
+	new.LogLevel = "debug"
+	new.KafkaBrokers = []string{"kafka:9092"}
+	new.Execution.Retry.MaxRetries = 7
+	new.path = "ignored.yaml"
+
+	want := []Change{
+		{Key: "kafka_brokers", Old: []string{"localhost:9092"}, New: []string{"kafka:9092"}},
+		{Key: "log_level", Old: "info", New: "debug"},
+		{Key: "execution.retry.max_retries", Old: 3, New: 7},
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReload(t *testing.T) {
+	reloadable := []string{"log_level"}
+	var applied []*Config
+	apply := func(cfg *Config) { applied = append(applied, cfg) }
+	current := Default()
+
+	// Nothing changed: the current configuration stays in effect
+	if got := reload(current, Default(), reloadable, apply); got != current || len(applied) != 0 {
+		t.Fatalf("unchanged configuration: got %p with %d applied, want %p with none", got, len(applied), current)
+	}
+
+	// Only a setting that needs a restart changed: it is not applied, but
+	// the warning is not repeated on the next reload
+	next := Default()
+	next.StorageBackend = "memory"
+	if got := reload(current, next, reloadable, apply); got != next || len(applied) != 0 {
+		t.Fatalf("change needing a restart: got %p with %d applied, want %p with none", got, len(applied), next)
+	}
+
+	// A reloadable setting changed: the whole new configuration is applied
+	current, next = next, Default()
+	next.StorageBackend = "memory"
+	next.LogLevel = "debug"
+	if got := reload(current, next, reloadable, apply); got != next || len(applied) != 1 || applied[0] != next {
+		t.Fatalf("reloadable change: got %p with %v applied, want %p applied", got, applied, next)
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadEnv overrides the settings whose environment variables are set.
+func (c *Config) loadEnv() error {
+	env := &envReader{}
+	env.str("QUEUE_BACKEND", &c.QueueBackend)
+	env.slice("KAFKA_BROKERS", &c.KafkaBrokers)
+	env.str("KAFKA_TASK_TOPIC", &c.TaskTopic)
+	env.str("KAFKA_TASK_RETRY_TOPIC", &c.TaskRetryTopic)
+	env.str("KAFKA_TASK_HIGH_PRIORITY_TOPIC", &c.TaskHighPriorityTopic)
+	env.str("KAFKA_TASK_LOW_PRIORITY_TOPIC", &c.TaskLowPriorityTopic)
+	env.int("KAFKA_TASK_HIGH_PRIORITY_WEIGHT", &c.TaskHighPriorityWeight)
+	env.int("KAFKA_TASK_NORMAL_PRIORITY_WEIGHT", &c.TaskNormalPriorityWeight)
+	env.int("KAFKA_TASK_LOW_PRIORITY_WEIGHT", &c.TaskLowPriorityWeight)
+	env.str("STORAGE_BACKEND", &c.StorageBackend)
+	env.slice("CASSANDRA_HOSTS", &c.CassandraHosts)
+	env.str("CASSANDRA_KEYSPACE", &c.CassandraKeyspace)
+	env.str("POSTGRES_DSN", &c.PostgresDSN)
+	env.str("SQLITE_PATH", &c.SQLitePath)
+	env.str("SCHEDULER_SERVICE_PORT", &c.SchedulerServicePort)
+	env.str("EXECUTION_SERVICE_GRPC_PORT", &c.ExecutionServiceGRPCPort)
+	env.str("EXECUTION_SERVICE_HTTP_PORT", &c.ExecutionServiceHTTPPort)
+	env.str("JOB_SERVICE_HOST", &c.JobServiceHost)
+	env.str("JOB_SERVICE_GRPC_PORT", &c.JobServiceGRPCPort)
+	env.str("JOB_SERVICE_HTTP_PORT", &c.JobServiceHTTPPort)
+	env.str("SCHEDULER_SERVICE_GRPC_PORT", &c.SchedulerServiceGRPCPort)
+	env.str("SCHEDULER_SERVICE_HTTP_PORT", &c.SchedulerServiceHTTPPort)
+	env.int("CASSANDRA_DATA_RETENTION_DAYS", &c.CassandraDataRetentionDays)
+	env.str("JOB_SERVICE_ADDR", &c.JobServiceAddr)
+	env.int("QUOTA_DEFAULT_MAX_JOBS", &c.QuotaMaxJobs)
+	env.seconds("QUOTA_DEFAULT_MIN_SCHEDULE_INTERVAL_SECONDS", &c.QuotaMinScheduleInterval)
+	env.int("QUOTA_DEFAULT_MAX_CONCURRENT_EXECUTIONS", &c.QuotaMaxConcurrentRuns)
+	env.int("QUOTA_DEFAULT_MAX_EXECUTIONS_PER_HOUR", &c.QuotaMaxRunsPerHour)
+	env.bool("AUTH_ENABLED", &c.AuthEnabled)
+	env.slice("AUTH_API_KEYS", &c.AuthAPIKeys)
+	env.str("AUTH_JWKS_FILE", &c.AuthJWKSFile)
+	env.str("AUTH_JWKS_URL", &c.AuthJWKSURL)
+	env.str("AUTH_JWT_ISSUER", &c.AuthJWTIssuer)
+	env.str("AUTH_JWT_AUDIENCE", &c.AuthJWTAudience)
+	env.str("AUTH_SERVICE_API_KEY", &c.AuthServiceAPIKey)
+	env.slice("AUTH_ADMIN_SUBJECTS", &c.AuthAdminSubjects)
+	env.str("TLS_CERT_FILE", &c.TLSCertFile)
+	env.str("TLS_KEY_FILE", &c.TLSKeyFile)
+	env.str("TLS_CA_FILE", &c.TLSCAFile)
+	env.str("TLS_SERVER_NAME", &c.TLSServerName)
+	env.seconds("TLS_RELOAD_INTERVAL_SECONDS", &c.TLSReloadInterval)
+	env.str("TRACING_EXPORTER", &c.TracingExporter)
+	env.str("TRACING_OTLP_ENDPOINT", &c.TracingOTLPEndpoint)
+	env.bool("TRACING_OTLP_INSECURE", &c.TracingOTLPInsecure)
+	env.str("TRACING_FILE", &c.TracingFile)
+	env.float("TRACING_SAMPLE_RATIO", &c.TracingSampleRatio)
+	env.str("LOG_LEVEL", &c.LogLevel)
+	env.str("LOG_FORMAT", &c.LogFormat)
+	env.slice("LOG_REDACT_KEYS", &c.LogRedactKeys)
+
+	env.seconds("SCHEDULER_CHECK_INTERVAL_SECONDS", &c.Scheduler.CheckInterval)
+	env.int("SCHEDULER_BATCH_SIZE", &c.Scheduler.BatchSize)
+	env.seconds("SCHEDULER_OUTBOX_RELAY_INTERVAL_SECONDS", &c.Scheduler.OutboxRelayInterval)
+
+	env.str("EXECUTION_WORKER_ID", &c.Execution.WorkerID)
+	env.int("EXECUTION_WORKER_POOL_SIZE", &c.Execution.WorkerPoolSize)
+	env.seconds("EXECUTION_HEARTBEAT_INTERVAL_SECONDS", &c.Execution.HeartbeatInterval)
+	env.seconds("EXECUTION_HEARTBEAT_TIMEOUT_SECONDS", &c.Execution.HeartbeatTimeout)
+	env.seconds("EXECUTION_REAPER_INTERVAL_SECONDS", &c.Execution.ReaperInterval)
+	env.int("EXECUTION_MAX_RETRIES", &c.Execution.Retry.MaxRetries)
+
+	if len(env.errs) > 0 {
+		return fmt.Errorf("invalid environment: %w", errors.Join(env.errs...))
+	}
+	return nil
+}
+
+// envReader reads settings from environment variables, collecting the values
+// that cannot be parsed so they can all be reported at once.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) str(key string, dst *string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*dst = value
+	}
+}
+
+func (e *envReader) slice(key string, dst *[]string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*dst = strings.Split(value, ",")
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	valueStr, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not an integer", key, valueStr))
+		return
+	}
+	*dst = value
+}
+
+func (e *envReader) bool(key string, dst *bool) {
+	valueStr, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not a boolean", key, valueStr))
+		return
+	}
+	*dst = value
+}
+
+func (e *envReader) float(key string, dst *float64) {
+	valueStr, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not a number", key, valueStr))
+		return
+	}
+	*dst = value
+}
+
+func (e *envReader) seconds(key string, dst *time.Duration) {
+	valueStr, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not a whole number of seconds", key, valueStr))
+		return
+	}
+	*dst = time.Duration(value) * time.Second
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile overrides the settings present in the YAML or TOML file at path,
+// chosen by its extension. Unknown keys are rejected so that typos are not
+// silently ignored.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = c.decodeYAML(data)
+	case ".toml":
+		err = c.decodeTOML(data)
+	default:
+		return fmt.Errorf("config file %s: unsupported extension %q, must be .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) decodeYAML(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func (c *Config) decodeTOML(data []byte) error {
+	metadata, err := toml.Decode(string(data), c)
+	if err != nil {
+		return err
+	}
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// set applies a key=value override, where key is the dotted path of a setting
+// in the config file and value is parsed as YAML, so lists are written as
+// [a, b] and durations as 30s.
+func (c *Config) set(override string) error {
+	key, value, ok := strings.Cut(override, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid override %q: must be key=value", override)
+	}
+
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return fmt.Errorf("invalid override %q: %w", override, err)
+	}
+
+	parts := strings.Split(key, ".")
+	var doc interface{} = parsed
+	for i := len(parts) - 1; i >= 0; i-- {
+		doc = map[string]interface{}{parts[i]: doc}
+	}
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("invalid override %q: %w", override, err)
+	}
+	if err := c.decodeYAML(data); err != nil {
+		return fmt.Errorf("invalid override %q: %w", override, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+)
+
+// fileCheckInterval is how often Watch checks the config file for changes.
+const fileCheckInterval = 5 * time.Second
+
+// Change is a setting whose value differs between two configurations.
+type Change struct {
+	// Key is the dotted key of the setting in the config file.
+	Key      string
+	Old, New interface{}
+}
+
+// Watch reloads the configuration whenever the process receives SIGHUP or
+// the config file cfg was loaded from changes, until ctx is done. When a
+// setting named in reloadable changes, the new configuration is passed to
+// apply, which is expected to take on those settings without a restart.
+// Changes to other settings are logged as needing a restart, and a
+// configuration that fails to load or validate is logged and ignored.
+func Watch(ctx context.Context, cfg *Config, reloadable []string, apply func(*Config)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+
+		var fileChecks <-chan time.Time
+		if cfg.path != "" {
+			ticker := time.NewTicker(fileCheckInterval)
+			defer ticker.Stop()
+			fileChecks = ticker.C
+		}
+		modTime := fileModTime(cfg.path)
+
+		current := cfg
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				logger.Info().Msg("Received SIGHUP, reloading configuration")
+			case <-fileChecks:
+				latest := fileModTime(cfg.path)
+				if latest.Equal(modTime) {
+					continue
+				}
+				modTime = latest
+				logger.Info().Msgf("Config file %s changed, reloading configuration", cfg.path)
+			}
+
+			next, err := load(current.path, current.sets)
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to reload configuration, keeping the current one")
+				continue
+			}
+			current = reload(current, next, reloadable, apply)
+		}
+	}()
+}
+
+// reload logs the differences between current and next and applies next if
+// any reloadable setting changed. It returns the configuration now in effect
+// as far as later reloads are concerned.
+func reload(current, next *Config, reloadable []string, apply func(*Config)) *Config {
+	changes := Diff(current, next)
+	if len(changes) == 0 {
+		logger.Info().Msg("Configuration unchanged")
+		return current
+	}
+
+	applied := false
+	for _, change := range changes {
+		if slices.Contains(reloadable, change.Key) {
+			logger.Info().Msgf("Setting %s changed from %v to %v", change.Key, change.Old, change.New)
+			applied = true
+			continue
+		}
+		// Values are left out as they may be credentials.
+		logger.Warn().Msgf("Setting %s changed; restart the service to apply it", change.Key)
+	}
+	if applied {
+		apply(next)
+	}
+	return next
+}
+
+// Diff lists the settings that differ between old and new.
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffStruct("", reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), &changes)
+	return changes
+}
+
+func diffStruct(prefix string, old, new reflect.Value, changes *[]Change) {
+	for i := 0; i < old.NumField(); i++ {
+		field := old.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key := prefix + strings.Split(field.Tag.Get("yaml"), ",")[0]
+		oldValue, newValue := old.Field(i), new.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			diffStruct(key+".", oldValue, newValue, changes)
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Interface(), newValue.Interface()) {
+			*changes = append(*changes, Change{Key: key, Old: oldValue.Interface(), New: newValue.Interface()})
+		}
+	}
+}
+
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate reports every setting that is out of range, naming each by its key
+// in the config file.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.oneOf("queue_backend", c.QueueBackend, "kafka", "memory")
+	if c.QueueBackend == "kafka" {
+		v.list("kafka_brokers", c.KafkaBrokers)
+	}
+	v.required("task_topic", c.TaskTopic)
+	v.required("task_retry_topic", c.TaskRetryTopic)
+	v.required("task_high_priority_topic", c.TaskHighPriorityTopic)
+	v.required("task_low_priority_topic", c.TaskLowPriorityTopic)
+	v.atLeast("task_high_priority_weight", c.TaskHighPriorityWeight, 1)
+	v.atLeast("task_normal_priority_weight", c.TaskNormalPriorityWeight, 1)
+	v.atLeast("task_low_priority_weight", c.TaskLowPriorityWeight, 1)
+	v.oneOf("storage_backend", c.StorageBackend, "cassandra", "postgres", "sqlite", "memory")
+	switch c.StorageBackend {
+	case "cassandra":
+		v.list("cassandra_hosts", c.CassandraHosts)
+		v.required("cassandra_keyspace", c.CassandraKeyspace)
+	case "postgres":
+		v.required("postgres_dsn", c.PostgresDSN)
+	case "sqlite":
+		v.required("sqlite_path", c.SQLitePath)
+	}
+	v.port("scheduler_service_port", c.SchedulerServicePort)
+	v.port("execution_service_grpc_port", c.ExecutionServiceGRPCPort)
+	v.port("execution_service_http_port", c.ExecutionServiceHTTPPort)
+	v.port("job_service_grpc_port", c.JobServiceGRPCPort)
+	v.port("job_service_http_port", c.JobServiceHTTPPort)
+	v.port("scheduler_service_grpc_port", c.SchedulerServiceGRPCPort)
+	v.port("scheduler_service_http_port", c.SchedulerServiceHTTPPort)
+	v.atLeast("cassandra_data_retention_days", c.CassandraDataRetentionDays, 1)
+	v.required("job_service_addr", c.JobServiceAddr)
+	v.atLeast("quota_max_jobs", c.QuotaMaxJobs, 0)
+	v.notNegative("quota_min_schedule_interval", c.QuotaMinScheduleInterval)
+	v.atLeast("quota_max_concurrent_runs", c.QuotaMaxConcurrentRuns, 0)
+	v.atLeast("quota_max_runs_per_hour", c.QuotaMaxRunsPerHour, 0)
+	v.notNegative("tls_reload_interval", c.TLSReloadInterval)
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		v.fail("tracing_sample_ratio must be between 0 and 1, got %g", c.TracingSampleRatio)
+	}
+
+	v.positive("scheduler.check_interval", c.Scheduler.CheckInterval)
+	v.atLeast("scheduler.batch_size", c.Scheduler.BatchSize, 1)
+	v.positive("scheduler.outbox_relay_interval", c.Scheduler.OutboxRelayInterval)
+
+	v.required("execution.worker_id", c.Execution.WorkerID)
+	v.atLeast("execution.worker_pool_size", c.Execution.WorkerPoolSize, 1)
+	v.positive("execution.heartbeat_interval", c.Execution.HeartbeatInterval)
+	v.positive("execution.reaper_interval", c.Execution.ReaperInterval)
+	if c.Execution.HeartbeatTimeout <= c.Execution.HeartbeatInterval {
+		v.fail("execution.heartbeat_timeout must be longer than execution.heartbeat_interval (%v), got %v", c.Execution.HeartbeatInterval, c.Execution.HeartbeatTimeout)
+	}
+	v.atLeast("execution.retry.max_retries", c.Execution.Retry.MaxRetries, 0)
+
+	if len(v.errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(v.errs...))
+	}
+	return nil
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) required(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail("%s is required", key)
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		v.fail("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value)
+	}
+}
+
+func (v *validator) list(key string, values []string) {
+	if len(values) == 0 {
+		v.fail("%s must not be empty", key)
+		return
+	}
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			v.fail("%s must not contain empty entries", key)
+			return
+		}
+	}
+}
+
+func (v *validator) port(key, value string) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		v.fail("%s must be a port number between 1 and 65535, got %q", key, value)
+	}
+}
+
+func (v *validator) atLeast(key string, value, min int) {
+	if value < min {
+		v.fail("%s must be at least %d, got %d", key, min, value)
+	}
+}
+
+func (v *validator) positive(key string, value time.Duration) {
+	if value <= 0 {
+		v.fail("%s must be a positive duration, got %v", key, value)
+	}
+}
+
+func (v *validator) notNegative(key string, value time.Duration) {
+	if value < 0 {
+		v.fail("%s must not be negative, got %v", key, value)
+	}
+}
@@ -1,12 +1,16 @@
 package database
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/gocql/gocql"
 	"github.com/nedson202/dts-go/pkg/logger"
 )
 
 type CassandraClient struct {
 	Session *gocql.Session
+	ctx     context.Context
 }
 
 func NewCassandraClient(hosts []string, keyspace string) (*CassandraClient, error) {
@@ -14,6 +18,8 @@ func NewCassandraClient(hosts []string, keyspace string) (*CassandraClient, erro
 	cluster := gocql.NewCluster(hosts...)
 	cluster.Keyspace = keyspace
 	cluster.Consistency = gocql.Quorum
+	cluster.QueryObserver = queryObserver{}
+	cluster.BatchObserver = queryObserver{}
 	session, err := cluster.CreateSession()
 	if err != nil {
 		logger.Error().Err(err).Msg("Error creating Cassandra session")
@@ -22,7 +28,39 @@ func NewCassandraClient(hosts []string, keyspace string) (*CassandraClient, erro
 	return &CassandraClient{Session: session}, nil
 }
 
+// WithContext returns a client whose queries run under ctx, so they are
+// cancelled with it and traced as part of the request it belongs to.
+func (c *CassandraClient) WithContext(ctx context.Context) *CassandraClient {
+	return &CassandraClient{Session: c.Session, ctx: ctx}
+}
+
+// Query builds a query bound to the context of the client.
+func (c *CassandraClient) Query(stmt string, values ...interface{}) *gocql.Query {
+	query := c.Session.Query(stmt, values...)
+	if c.ctx != nil {
+		query = query.WithContext(c.ctx)
+	}
+	return query
+}
+
+// NewBatch builds a batch bound to the context of the client.
+func (c *CassandraClient) NewBatch(typ gocql.BatchType) *gocql.Batch {
+	batch := c.Session.NewBatch(typ)
+	if c.ctx != nil {
+		batch = batch.WithContext(c.ctx)
+	}
+	return batch
+}
+
 func (c *CassandraClient) Close() {
 	c.Session.Close()
 	logger.Info().Msg("Cassandra session closed")
 }
+
+// Ping checks that the session can still reach the cluster.
+func (c *CassandraClient) Ping(ctx context.Context) error {
+	if c.Session.Closed() {
+		return fmt.Errorf("cassandra session is closed")
+	}
+	return c.Session.Query("SELECT release_version FROM system.local").WithContext(ctx).Exec()
+}
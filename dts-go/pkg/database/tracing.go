@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryObserver records a span for every query and batch run under a traced
+// context. Queries without a trace, such as background housekeeping, are not
+// recorded so they do not each start a trace of their own.
+type queryObserver struct{}
+
+func (queryObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	recordSpan(ctx, operation(q.Statement), q.Keyspace, q.Statement, q.Attempt, q.Err, q.Start, q.End)
+}
+
+func (queryObserver) ObserveBatch(ctx context.Context, b gocql.ObservedBatch) {
+	recordSpan(ctx, "BATCH", b.Keyspace, strings.Join(b.Statements, "; "), b.Attempt, b.Err, b.Start, b.End)
+}
+
+func recordSpan(ctx context.Context, op, keyspace, statement string, attempt int, err error, start, end time.Time) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	_, span := tracing.Tracer().Start(ctx, "cassandra "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attribute.String("db.system", "cassandra"),
+			attribute.String("db.namespace", keyspace),
+			attribute.String("db.operation.name", op),
+			attribute.String("db.query.text", statement),
+			attribute.Int("db.cassandra.attempt", attempt),
+		),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+// operation returns the CQL verb a statement starts with.
+func operation(statement string) string {
+	fields := strings.Fields(statement)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
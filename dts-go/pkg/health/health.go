@@ -0,0 +1,192 @@
+// Package health reports whether a service is alive and ready to take
+// traffic, over the standard grpc.health.v1 service and the /healthz and
+// /readyz HTTP endpoints.
+//
+// Readiness covers the dependencies a service needs to do useful work, such as
+// Cassandra and Kafka; a failing dependency takes the service out of rotation
+// without restarting it. Liveness covers the service's own loops; a loop that
+// has stopped is only fixed by a restart.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// checkTimeout bounds how long a single probe waits for all checks.
+	checkTimeout = 3 * time.Second
+	// watchInterval is how often the gRPC serving status is refreshed.
+	watchInterval = 10 * time.Second
+)
+
+// Check returns an error when the thing it checks is unhealthy.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name     string
+	check    Check
+	liveness bool
+}
+
+// Checker runs the health checks of a service.
+type Checker struct {
+	mu     sync.RWMutex
+	checks []namedCheck
+	grpc   *grpchealth.Server
+}
+
+func NewChecker() *Checker {
+	return &Checker{grpc: grpchealth.NewServer()}
+}
+
+// AddReadiness adds a check that must pass before the service takes traffic.
+func (c *Checker) AddReadiness(name string, check Check) {
+	c.add(namedCheck{name: name, check: check})
+}
+
+// AddLiveness adds a check that must pass for the service to be considered
+// alive. Liveness checks are part of readiness too.
+func (c *Checker) AddLiveness(name string, check Check) {
+	c.add(namedCheck{name: name, check: check, liveness: true})
+}
+
+func (c *Checker) add(check namedCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check)
+}
+
+// Result is the outcome of a set of checks, keyed by check name; an empty
+// string means the check passed.
+type Result map[string]string
+
+// Healthy reports whether every check in the result passed.
+func (r Result) Healthy() bool {
+	for _, message := range r {
+		if message != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready runs every check.
+func (c *Checker) Ready(ctx context.Context) Result {
+	return c.run(ctx, false)
+}
+
+// Live runs the liveness checks.
+func (c *Checker) Live(ctx context.Context) Result {
+	return c.run(ctx, true)
+}
+
+func (c *Checker) run(ctx context.Context, livenessOnly bool) Result {
+	result := Result{}
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		if livenessOnly && !check.liveness {
+			continue
+		}
+		wg.Add(1)
+		go func(check namedCheck) {
+			defer wg.Done()
+			message := ""
+			if err := check.check(ctx); err != nil {
+				message = err.Error()
+			}
+			mu.Lock()
+			result[check.name] = message
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+	return result
+}
+
+// RegisterGRPC serves grpc.health.v1 on server. The overall status and that of
+// each named service follow readiness, refreshed until ctx is done, after
+// which they report NOT_SERVING.
+func (c *Checker) RegisterGRPC(ctx context.Context, server *grpc.Server, services ...string) {
+	healthpb.RegisterHealthServer(server, c.grpc)
+	services = append([]string{""}, services...)
+	c.setStatus(services, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	go func() {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			c.refresh(ctx, services)
+			select {
+			case <-ctx.Done():
+				c.grpc.Shutdown()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (c *Checker) refresh(ctx context.Context, services []string) {
+	result := c.Ready(ctx)
+	status := healthpb.HealthCheckResponse_SERVING
+	if !result.Healthy() {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+		logger.Warn().Msgf("Service is not ready: %v", result)
+	}
+	c.setStatus(services, status)
+}
+
+func (c *Checker) setStatus(services []string, status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range services {
+		c.grpc.SetServingStatus(service, status)
+	}
+}
+
+// Mount serves /healthz and /readyz on mux.
+func (c *Checker) Mount(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, c.Live(r.Context()))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, c.Ready(r.Context()))
+	})
+}
+
+func writeResult(w http.ResponseWriter, result Result) {
+	body := struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+	}{Status: "ok", Checks: map[string]string{}}
+
+	code := http.StatusOK
+	for name, message := range result {
+		if message == "" {
+			body.Checks[name] = "ok"
+			continue
+		}
+		body.Checks[name] = message
+		body.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
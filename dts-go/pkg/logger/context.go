@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the ID of the request being served.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or an empty string.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Ctx returns the logger with the request ID and the trace and span IDs
+// carried by ctx added to every line, so the lines of one request or run can
+// be found together.
+func Ctx(ctx context.Context) *zerolog.Logger {
+	fields := Log.With()
+	if requestID := RequestID(ctx); requestID != "" {
+		fields = fields.Str("request_id", requestID)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		fields = fields.Str("trace_id", spanContext.TraceID().String()).Str("span_id", spanContext.SpanID().String())
+	}
+	l := fields.Logger()
+	return &l
+}
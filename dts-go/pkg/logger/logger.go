@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,21 +11,111 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Formats accepted by Setup.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Redacted replaces the values of sensitive keys in logs.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys are the key fragments treated as sensitive unless
+// configured otherwise.
+var DefaultRedactKeys = []string{"authorization", "api-key", "apikey", "token", "secret", "password", "cookie"}
+
 var (
-	once sync.Once
-	Log  zerolog.Logger
+	once       sync.Once
+	Log        zerolog.Logger
+	redactKeys = DefaultRedactKeys
 )
 
+// Options configure the logger.
+type Options struct {
+	// Level is the minimum level written: trace, debug, info, warn or error.
+	Level string
+	// Format is console for colored human-readable output or json for one JSON
+	// object per line.
+	Format string
+	// RedactKeys are matched, case-insensitively, against metadata keys; the
+	// values of keys containing any of them are not logged.
+	RedactKeys []string
+}
+
+// Init installs a console logger at info level so that early startup can log
+// before the configuration has been read.
 func Init() {
 	once.Do(func() {
 		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+		setLogger(newLogger(FormatConsole))
+	})
+}
 
-		output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
-		Log = zerolog.New(output).With().Timestamp().Caller().Logger()
+// Setup applies the configured level, format and redacted keys.
+func Setup(opts Options) error {
+	Init()
 
-		// Set global logger
-		log.Logger = Log
-	})
+	level := zerolog.InfoLevel
+	if opts.Level != "" {
+		parsed, err := zerolog.ParseLevel(strings.ToLower(opts.Level))
+		if err != nil || parsed == zerolog.NoLevel {
+			return fmt.Errorf("invalid log level %q", opts.Level)
+		}
+		level = parsed
+	}
+
+	format := strings.ToLower(opts.Format)
+	switch format {
+	case "":
+		format = FormatConsole
+	case FormatConsole, FormatJSON:
+	default:
+		return fmt.Errorf("invalid log format %q: must be %s or %s", opts.Format, FormatConsole, FormatJSON)
+	}
+
+	zerolog.SetGlobalLevel(level)
+	if format == FormatJSON {
+		zerolog.TimeFieldFormat = time.RFC3339Nano
+	}
+	if opts.RedactKeys != nil {
+		redactKeys = opts.RedactKeys
+	}
+	setLogger(newLogger(format))
+	return nil
+}
+
+func newLogger(format string) zerolog.Logger {
+	if format == FormatJSON {
+		return zerolog.New(os.Stdout).With().Timestamp().Caller().Logger()
+	}
+	output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	return zerolog.New(output).With().Timestamp().Caller().Logger()
+}
+
+func setLogger(l zerolog.Logger) {
+	Log = l
+
+	// Set global logger
+	log.Logger = Log
+}
+
+// IsSensitive reports whether the value of key must not be logged.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range redactKeys {
+		if fragment = strings.ToLower(strings.TrimSpace(fragment)); fragment != "" && strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sampled returns the logger thinned out by sampler, for messages logged too
+// often to keep every one.
+func Sampled(sampler zerolog.Sampler) *zerolog.Logger {
+	l := Log.Sample(sampler)
+	return &l
 }
 
 func Debug() *zerolog.Event {
@@ -0,0 +1,282 @@
+// Package metrics keeps the services' counters, gauges and histograms and
+// exposes them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit latencies from a millisecond to a few minutes.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+var registry = struct {
+	mu         sync.Mutex
+	collectors []collector
+}{}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+func register(c collector) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.collectors = append(registry.collectors, c)
+}
+
+// Handler serves every registered metric.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		registry.mu.Lock()
+		collectors := append([]collector(nil), registry.collectors...)
+		registry.mu.Unlock()
+		for _, c := range collectors {
+			c.write(w)
+		}
+	})
+}
+
+// vec holds one series per combination of label values.
+type vec[T any] struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	newSeries  func() T
+
+	mu     sync.Mutex
+	series map[string]T
+	labels map[string][]string
+}
+
+func newVec[T any](name, help, kind string, labelNames []string, newSeries func() T) *vec[T] {
+	return &vec[T]{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		newSeries:  newSeries,
+		series:     make(map[string]T),
+		labels:     make(map[string][]string),
+	}
+}
+
+func (v *vec[T]) with(labelValues []string) T {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = v.newSeries()
+		v.series[key] = s
+		v.labels[key] = append([]string(nil), labelValues...)
+	}
+	return s
+}
+
+// each calls fn for every series in a stable order.
+func (v *vec[T]) each(fn func(labels string, s T)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]T, len(keys))
+	labels := make([][]string, len(keys))
+	for i, key := range keys {
+		series[i], labels[i] = v.series[key], v.labels[key]
+	}
+	v.mu.Unlock()
+
+	for i := range keys {
+		fn(formatLabels(v.labelNames, labels[i]), series[i])
+	}
+}
+
+func (v *vec[T]) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, strings.ReplaceAll(v.help, "\n", " "), v.name, v.kind)
+}
+
+// value is a float64 updated atomically under its own lock.
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *value) add(delta float64) {
+	v.mu.Lock()
+	v.v += delta
+	v.mu.Unlock()
+}
+
+func (v *value) set(x float64) {
+	v.mu.Lock()
+	v.v = x
+	v.mu.Unlock()
+}
+
+func (v *value) get() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+// CounterVec is a family of monotonically increasing counters.
+type CounterVec struct {
+	vec *vec[*value]
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{vec: newVec(name, help, "counter", labelNames, func() *value { return &value{} })}
+	register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.vec.with(labelValues).add(1)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.vec.with(labelValues).add(delta)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.vec.writeHeader(w)
+	c.vec.each(func(labels string, s *value) {
+		fmt.Fprintf(w, "%s%s %s\n", c.vec.name, labels, formatFloat(s.get()))
+	})
+}
+
+// GaugeVec is a family of values that can go up and down.
+type GaugeVec struct {
+	vec *vec[*value]
+}
+
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{vec: newVec(name, help, "gauge", labelNames, func() *value { return &value{} })}
+	register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(x float64, labelValues ...string) {
+	g.vec.with(labelValues).set(x)
+}
+
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.vec.with(labelValues).add(delta)
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.vec.writeHeader(w)
+	g.vec.each(func(labels string, s *value) {
+		fmt.Fprintf(w, "%s%s %s\n", g.vec.name, labels, formatFloat(s.get()))
+	})
+}
+
+// HistogramVec is a family of histograms with shared buckets.
+type HistogramVec struct {
+	vec     *vec[*histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates a histogram family. buckets are upper bounds in
+// increasing order; nil uses DefaultBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &HistogramVec{buckets: buckets}
+	h.vec = newVec(name, help, "histogram", labelNames, func() *histogram {
+		return &histogram{counts: make([]uint64, len(buckets))}
+	})
+	register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(x float64, labelValues ...string) {
+	s := h.vec.with(labelValues)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, bound := range h.buckets {
+		if x <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += x
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.vec.writeHeader(w)
+	h.vec.each(func(labels string, s *histogram) {
+		s.mu.Lock()
+		counts := append([]uint64(nil), s.counts...)
+		count, sum := s.count, s.sum
+		s.mu.Unlock()
+
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.vec.name, withLabel(labels, "le", formatFloat(bound)), counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.vec.name, withLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.vec.name, labels, formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.vec.name, labels, count)
+	})
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabelValue(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + escapeLabelValue(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/nedson202/dts-go/pkg/auth"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AuthUnaryServerInterceptor rejects unauthenticated calls and attaches the
+// caller identity to the context. A nil authenticator disables
+// authentication.
+func AuthUnaryServerInterceptor(authenticator auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, err := authenticate(ctx, authenticator, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamServerInterceptor is the streaming counterpart of
+// AuthUnaryServerInterceptor.
+func AuthStreamServerInterceptor(authenticator auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := authenticate(ss.Context(), authenticator, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// GatewayHeaderMatcher forwards the API key and request ID headers to the
+// gRPC server in addition to the headers grpc-gateway forwards by default.
+func GatewayHeaderMatcher(key string) (string, bool) {
+	switch textproto.CanonicalMIMEHeaderKey(key) {
+	case textproto.CanonicalMIMEHeaderKey(auth.APIKeyMetadataKey):
+		return auth.APIKeyMetadataKey, true
+	case textproto.CanonicalMIMEHeaderKey(RequestIDMetadataKey):
+		return RequestIDMetadataKey, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+func authenticate(ctx context.Context, authenticator auth.Authenticator, method string) (context.Context, error) {
+	if authenticator == nil || isPublicMethod(method) {
+		return ctx, nil
+	}
+
+	identity, err := authenticator.Authenticate(ctx)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoCredentials) {
+			return nil, status.Errorf(codes.Unauthenticated, "Missing credentials")
+		}
+		logger.Warn().Err(err).Str("method", method).Msg("Rejected request with invalid credentials")
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid credentials")
+	}
+	return auth.NewContext(ctx, identity), nil
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// isPublicMethod reports whether method is served to every caller: server
+// reflection and health checks, which probes call without credentials.
+func isPublicMethod(method string) bool {
+	return strings.HasPrefix(method, "/grpc.reflection.") || strings.HasPrefix(method, "/grpc.health.v1.")
+}
@@ -5,8 +5,18 @@ import (
 	"time"
 
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/metrics"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	grpcRequests = metrics.NewCounterVec("dts_grpc_server_handled_total",
+		"gRPC requests completed by the server, by method and status code.", "method", "code")
+	grpcRequestDuration = metrics.NewHistogramVec("dts_grpc_server_handling_seconds",
+		"Time taken to handle gRPC requests, by method.", nil, "method")
 )
 
 func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
@@ -21,13 +31,58 @@ func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 		duration := time.Since(start)
 
 		st, _ := status.FromError(err)
-		logger.Info().
+		grpcRequests.Inc(info.FullMethod, st.Code().String())
+		grpcRequestDuration.Observe(duration.Seconds(), info.FullMethod)
+
+		logger.Ctx(ctx).Info().
 			Str("method", info.FullMethod).
 			Dur("duration", duration).
-			Interface("request", req).
-			Int("code", int(st.Code())).
+			Str("code", st.Code().String()).
 			Msg("gRPC request")
 
+		// Request bodies and metadata are only logged at debug level, with
+		// sensitive values redacted.
+		if debug := logger.Ctx(ctx).Debug(); debug.Enabled() {
+			md, _ := metadata.FromIncomingContext(ctx)
+			debug.
+				Str("method", info.FullMethod).
+				Interface("metadata", redactMetadata(md)).
+				Interface("request", redactRequest(req)).
+				Msg("gRPC request details")
+		}
+
 		return resp, err
 	}
 }
+
+// redactMetadata returns a copy of md with the values of sensitive keys
+// replaced.
+func redactMetadata(md metadata.MD) map[string][]string {
+	redacted := make(map[string][]string, len(md))
+	for key, values := range md {
+		if logger.IsSensitive(key) {
+			values = []string{logger.Redacted}
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+// redactRequest returns req with the values of sensitive job metadata keys
+// replaced. req itself is left untouched.
+func redactRequest(req interface{}) interface{} {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return req
+	}
+	clone := proto.Clone(message)
+	if withMetadata, ok := clone.(interface{ GetMetadata() map[string]string }); ok {
+		jobMetadata := withMetadata.GetMetadata()
+		for key := range jobMetadata {
+			if logger.IsSensitive(key) {
+				jobMetadata[key] = logger.Redacted
+			}
+		}
+	}
+	return clone
+}
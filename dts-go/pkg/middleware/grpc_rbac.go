@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/rbac"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RBACUnaryServerInterceptor checks that the authenticated caller holds the
+// role rules require for the method in the namespace of the request. It must
+// run after the authentication interceptor. A nil authorizer disables the
+// check.
+func RBACUnaryServerInterceptor(authorizer *rbac.Authorizer, rules rbac.Rules) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := authorize(ctx, authorizer, rules, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RBACStreamServerInterceptor is the streaming counterpart of
+// RBACUnaryServerInterceptor. The namespace is only known once the request
+// has been received, so the check runs on the first received message.
+func RBACStreamServerInterceptor(authorizer *rbac.Authorizer, rules rbac.Rules) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if authorizer == nil || isPublicMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		return handler(srv, &authorizedStream{
+			ServerStream: ss,
+			authorize: func(req interface{}) error {
+				return authorize(ss.Context(), authorizer, rules, info.FullMethod, req)
+			},
+		})
+	}
+}
+
+func authorize(ctx context.Context, authorizer *rbac.Authorizer, rules rbac.Rules, method string, req interface{}) error {
+	if authorizer == nil || isPublicMethod(method) {
+		return nil
+	}
+
+	rule, ok := rules[method]
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "Permission denied")
+	}
+	namespace := models.AllNamespaces
+	if !rule.AllNamespaces {
+		namespace = requestNamespace(req)
+	}
+
+	allowed, err := authorizer.Authorize(ctx, namespace, rule.Role)
+	if err != nil {
+		logger.Error().Err(err).Str("method", method).Msg("Error resolving role bindings")
+		return status.Errorf(codes.Internal, "Failed to authorize request")
+	}
+	if !allowed {
+		return status.Errorf(codes.PermissionDenied, "Permission denied: %s role required in namespace %q", rule.Role, namespace)
+	}
+	return nil
+}
+
+// requestNamespace returns the namespace a request operates on. Namespace
+// requests carry it as their name.
+func requestNamespace(req interface{}) string {
+	var namespace string
+	switch r := req.(type) {
+	case interface{ GetNamespace() string }:
+		namespace = r.GetNamespace()
+	case interface{ GetName() string }:
+		namespace = r.GetName()
+	}
+	if namespace == "" {
+		return models.DefaultNamespace
+	}
+	return namespace
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	authorize  func(req interface{}) error
+	authorized bool
+}
+
+func (s *authorizedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if !s.authorized {
+		if err := s.authorize(m); err != nil {
+			return err
+		}
+		s.authorized = true
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/auth"
+	"github.com/nedson202/dts-go/pkg/middleware"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/rbac"
+	executionservice "github.com/nedson202/dts-go/pkg/services/execution"
+	jobservice "github.com/nedson202/dts-go/pkg/services/job"
+	"github.com/nedson202/dts-go/pkg/store"
+	executionpb "github.com/nedson202/dts-go/proto/execution/v1"
+	jobpb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// namespacedRequest stands in for a request message of any method.
+type namespacedRequest struct {
+	namespace string
+}
+
+func (r *namespacedRequest) GetNamespace() string { return r.namespace }
+
+// recvStream delivers one namespacedRequest.
+type recvStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	namespace string
+}
+
+func (s *recvStream) Context() context.Context { return s.ctx }
+
+func (s *recvStream) RecvMsg(m interface{}) error {
+	m.(*namespacedRequest).namespace = s.namespace
+	return nil
+}
+
+// roleBelow is the next lower role, or None for viewers.
+var roleBelow = map[rbac.Role]rbac.Role{
+	rbac.Viewer:   rbac.None,
+	rbac.Operator: rbac.Viewer,
+	rbac.Editor:   rbac.Operator,
+	rbac.Admin:    rbac.Editor,
+}
+
+// callAs runs the RBAC interceptor of the method's kind for subject with a
+// request in namespace and returns its error.
+func callAs(authorizer *rbac.Authorizer, rules rbac.Rules, method string, stream bool, subject, namespace string) error {
+	ctx := context.Background()
+	if subject != "" {
+		ctx = auth.NewContext(ctx, &auth.Identity{Subject: subject})
+	}
+
+	if stream {
+		interceptor := middleware.RBACStreamServerInterceptor(authorizer, rules)
+		return interceptor(nil, &recvStream{ctx: ctx, namespace: namespace}, &grpc.StreamServerInfo{FullMethod: method, IsServerStream: true},
+			func(srv interface{}, ss grpc.ServerStream) error {
+				return ss.RecvMsg(&namespacedRequest{})
+			})
+	}
+	interceptor := middleware.RBACUnaryServerInterceptor(authorizer, rules)
+	_, err := interceptor(ctx, &namespacedRequest{namespace: namespace}, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	return err
+}
+
+// TestRBACCoversEveryMethod runs every method of the services through the
+// interceptor with the rules the service is served with: a caller holding
+// the required role is let through and one holding the role below, or the
+// role in another namespace, is denied.
+func TestRBACCoversEveryMethod(t *testing.T) {
+	services := []struct {
+		desc  grpc.ServiceDesc
+		rules rbac.Rules
+	}{
+		{jobpb.JobService_ServiceDesc, jobservice.MethodRoles},
+		{executionpb.ExecutionService_ServiceDesc, executionservice.MethodRoles},
+	}
+
+	for _, service := range services {
+		type method struct {
+			name   string
+			stream bool
+		}
+		var methods []method
+		for _, m := range service.desc.Methods {
+			methods = append(methods, method{m.MethodName, false})
+		}
+		for _, s := range service.desc.Streams {
+			methods = append(methods, method{s.StreamName, true})
+		}
+		if len(service.rules) != len(methods) {
+			t.Errorf("%s has %d rules for %d methods", service.desc.ServiceName, len(service.rules), len(methods))
+		}
+
+		for _, m := range methods {
+			fullMethod := "/" + service.desc.ServiceName + "/" + m.name
+			t.Run(fullMethod, func(t *testing.T) {
+				rule, ok := service.rules[fullMethod]
+				if !ok {
+					t.Fatal("method has no rule, so every call to it is denied")
+				}
+
+				ctx := context.Background()
+				db := store.NewMemoryStore()
+				bindingNamespace := "payments"
+				if rule.AllNamespaces {
+					bindingNamespace = models.AllNamespaces
+				}
+				bind := func(subject string, role rbac.Role, namespace string) {
+					if role == rbac.None {
+						return
+					}
+					if err := db.SetRoleBinding(ctx, &models.RoleBinding{Namespace: namespace, Subject: subject, Role: string(role), CreatedAt: time.Now()}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				bind("holder", rule.Role, bindingNamespace)
+				bind("below", roleBelow[rule.Role], bindingNamespace)
+				bind("elsewhere", rbac.Admin, "search")
+				authorizer := rbac.NewAuthorizer(db, nil)
+
+				if err := callAs(authorizer, service.rules, fullMethod, m.stream, "holder", "payments"); err != nil {
+					t.Fatalf("caller with the %s role was denied: %v", rule.Role, err)
+				}
+				if err := callAs(authorizer, service.rules, fullMethod, m.stream, "below", "payments"); status.Code(err) != codes.PermissionDenied {
+					t.Fatalf("caller with the role below %s: got %v, want PermissionDenied", rule.Role, err)
+				}
+				if err := callAs(authorizer, service.rules, fullMethod, m.stream, "elsewhere", "payments"); status.Code(err) != codes.PermissionDenied {
+					t.Fatalf("admin of another namespace: got %v, want PermissionDenied", err)
+				}
+				if err := callAs(authorizer, service.rules, fullMethod, m.stream, "", "payments"); status.Code(err) != codes.PermissionDenied {
+					t.Fatalf("unauthenticated caller: got %v, want PermissionDenied", err)
+				}
+			})
+		}
+	}
+}
+
+func TestRBACDeniesUnknownMethods(t *testing.T) {
+	authorizer := rbac.NewAuthorizer(store.NewMemoryStore(), []string{"root"})
+	for _, stream := range []bool{false, true} {
+		err := callAs(authorizer, jobservice.MethodRoles, "/"+jobpb.JobService_ServiceDesc.ServiceName+"/DropEverything", stream, "root", models.DefaultNamespace)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("unknown method (stream %v) for an admin subject: got %v, want PermissionDenied", stream, err)
+		}
+	}
+}
+
+func TestRBACSkipsPublicMethodsAndNilAuthorizer(t *testing.T) {
+	authorizer := rbac.NewAuthorizer(store.NewMemoryStore(), nil)
+	if err := callAs(authorizer, jobservice.MethodRoles, "/grpc.health.v1.Health/Check", false, "", ""); err != nil {
+		t.Errorf("health check without credentials: %v", err)
+	}
+	if err := callAs(nil, jobservice.MethodRoles, jobpb.JobService_DeleteNamespace_FullMethodName, false, "", "payments"); err != nil {
+		t.Errorf("call with RBAC disabled: %v", err)
+	}
+}
+
+// TestRBACNamespaceOfRequest checks that a request without a namespace is
+// authorized in the default namespace and a namespace request by its name.
+func TestRBACNamespaceOfRequest(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemoryStore()
+	if err := db.SetRoleBinding(ctx, &models.RoleBinding{Namespace: models.DefaultNamespace, Subject: "alice", Role: string(rbac.Admin), CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	interceptor := middleware.RBACUnaryServerInterceptor(rbac.NewAuthorizer(db, nil), jobservice.MethodRoles)
+	alice := auth.NewContext(ctx, &auth.Identity{Subject: "alice"})
+	call := func(method string, req interface{}) error {
+		_, err := interceptor(alice, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+
+	if err := call(jobpb.JobService_DeleteJob_FullMethodName, &jobpb.DeleteJobRequest{Id: "x"}); err != nil {
+		t.Errorf("deleting a job without a namespace as admin of the default one: %v", err)
+	}
+	if err := call(jobpb.JobService_DeleteJob_FullMethodName, &jobpb.DeleteJobRequest{Id: "x", Namespace: "payments"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("deleting a job in another namespace: got %v, want PermissionDenied", err)
+	}
+	if err := call(jobpb.JobService_UpdateNamespace_FullMethodName, &jobpb.UpdateNamespaceRequest{Name: models.DefaultNamespace}); err != nil {
+		t.Errorf("updating the default namespace as its admin: %v", err)
+	}
+	if err := call(jobpb.JobService_UpdateNamespace_FullMethodName, &jobpb.UpdateNamespaceRequest{Name: "payments"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("updating another namespace: got %v, want PermissionDenied", err)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDMetadataKey carries the ID correlating a request across logs and
+// audit events. Callers may set it; otherwise one is generated.
+const RequestIDMetadataKey = "x-request-id"
+
+// RequestIDUnaryServerInterceptor attaches the request ID to the context, where
+// logger.Ctx picks it up, and echoes it in the response headers.
+func RequestIDUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		requestID := incomingRequestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, requestID))
+		return handler(logger.WithRequestID(ctx, requestID), req)
+	}
+}
+
+// RequestIDFromContext returns the ID of the request being served, or an
+// empty string outside of RequestIDUnaryServerInterceptor.
+func RequestIDFromContext(ctx context.Context) string {
+	return logger.RequestID(ctx)
+}
+
+func incomingRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(RequestIDMetadataKey); len(values) > 0 && values[0] != "" && len(values[0]) <= 128 {
+		return values[0]
+	}
+	return gocql.TimeUUID().String()
+}
@@ -14,7 +14,11 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(ww, r)
 		duration := time.Since(start)
 
-		logger.Info().
+		ctx := r.Context()
+		if requestID := r.Header.Get(RequestIDMetadataKey); requestID != "" {
+			ctx = logger.WithRequestID(ctx, requestID)
+		}
+		logger.Ctx(ctx).Info().
 			Str("method", r.Method).
 			Str("url", r.RequestURI).
 			Int("status", ww.statusCode).
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AuditEvent records one mutation of a job. Events are only ever inserted.
+type AuditEvent struct {
+	ID        gocql.UUID
+	Namespace string
+	JobID     gocql.UUID
+	Action    string
+	Actor     string
+	RequestID string
+	Changes   []FieldChange
+}
+
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+func (e *AuditEvent) ToProto() *pb.AuditEvent {
+	event := &pb.AuditEvent{
+		Id:        e.ID.String(),
+		Namespace: e.Namespace,
+		JobId:     e.JobID.String(),
+		Action:    pb.AuditAction(pb.AuditAction_value[e.Action]),
+		Actor:     e.Actor,
+		Timestamp: timestamppb.New(e.ID.Time()),
+		RequestId: e.RequestID,
+	}
+	for _, change := range e.Changes {
+		event.Changes = append(event.Changes, &pb.FieldChange{
+			Field:  change.Field,
+			Before: change.Before,
+			After:  change.After,
+		})
+	}
+	return event
+}
+
+// AuditEventFilter selects the events of a namespace between Start and End,
+// optionally only those of one job or actor.
+type AuditEventFilter struct {
+	Namespace string
+	JobID     gocql.UUID
+	Actor     string
+	Start     time.Time
+	End       time.Time
+}
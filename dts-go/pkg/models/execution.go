@@ -4,8 +4,8 @@ import (
 	"time"
 
 	"github.com/gocql/gocql"
-	"github.com/nedson202/dts-go/pkg/database"
 	pb "github.com/nedson202/dts-go/proto/execution/v1"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -17,76 +17,40 @@ type Execution struct {
 	EndTime   *time.Time `json:"end_time"` // Change this to a pointer
 	Result    string     `json:"result"`
 	Error     string     `json:"error"`
+	WorkerID  string     `json:"worker_id"`
+	// ScheduledTime is when the run was due; StartTime is when it was picked up.
+	ScheduledTime time.Time `json:"scheduled_time"`
+	TriggerSource string    `json:"trigger_source"`
+	// AttemptCount is the number of the latest attempt of this run.
+	AttemptCount int                 `json:"attempt_count"`
+	Attempts     []*ExecutionAttempt `json:"attempts,omitempty"`
+	Namespace    string              `json:"namespace"`
 }
 
 func (e *Execution) ToProto() *pb.ExecutionResponse {
 	resp := &pb.ExecutionResponse{
-		Id:        e.ID.String(),
-		JobId:     e.JobID.String(),
-		Status:    e.Status,
-		StartTime: timestamppb.New(e.StartTime),
-		Result:    e.Result,
-		Error:     e.Error,
+		Id:            e.ID.String(),
+		JobId:         e.JobID.String(),
+		Status:        pb.ExecutionStatus(pb.ExecutionStatus_value[e.Status]),
+		StartTime:     timestamppb.New(e.StartTime),
+		Result:        e.Result,
+		Error:         e.Error,
+		Attempt:       int32(e.AttemptCount),
+		WorkerId:      e.WorkerID,
+		TriggerSource: pb.TriggerSource(pb.TriggerSource_value[e.TriggerSource]),
+		Namespace:     e.Namespace,
+	}
+	if !e.ScheduledTime.IsZero() {
+		resp.ScheduledTime = timestamppb.New(e.ScheduledTime)
 	}
 	if e.EndTime != nil {
 		resp.EndTime = timestamppb.New(*e.EndTime)
+		resp.Duration = durationpb.New(e.EndTime.Sub(e.StartTime))
+	} else if e.Status == pb.ExecutionStatus_RUNNING.String() {
+		resp.Duration = durationpb.New(time.Since(e.StartTime))
 	}
-	return resp
-}
-
-func CreateExecution(client *database.CassandraClient, execution *Execution) error {
-	query := `INSERT INTO job_executions (id, job_id, status, start_time, end_time, result, error) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	return client.Session.Query(query, execution.ID, execution.JobID, execution.Status, execution.StartTime, execution.EndTime, execution.Result, execution.Error).Exec()
-}
-
-func GetExecution(client *database.CassandraClient, id gocql.UUID) (*Execution, error) {
-	var execution Execution
-	var endTime time.Time
-	query := `SELECT id, job_id, status, start_time, end_time, result, error FROM job_executions WHERE id = ?`
-	err := client.Session.Query(query, id).Scan(&execution.ID, &execution.JobID, &execution.Status, &execution.StartTime, &endTime, &execution.Result, &execution.Error)
-	if err != nil {
-		return nil, err
-	}
-	if !endTime.IsZero() {
-		execution.EndTime = &endTime
+	for _, attempt := range e.Attempts {
+		resp.Attempts = append(resp.Attempts, attempt.ToProto())
 	}
-	return &execution, nil
-}
-
-func ListExecutions(client *database.CassandraClient, pageSize int, lastID gocql.UUID, jobID, status string) ([]*Execution, error) {
-	var executions []*Execution
-	var query string
-	var args []interface{}
-
-	if jobID != "" && status != "" {
-		query = `SELECT id, job_id, status, start_time, end_time, result, error FROM job_executions WHERE job_id = ? AND status = ? AND id > ? ORDER BY id DESC LIMIT ?`
-		args = []interface{}{jobID, status, lastID, pageSize}
-	} else if jobID != "" {
-		query = `SELECT id, job_id, status, start_time, end_time, result, error FROM job_executions WHERE job_id = ? AND id > ? ORDER BY id DESC LIMIT ?`
-		args = []interface{}{jobID, lastID, pageSize}
-	} else if status != "" {
-		query = `SELECT id, job_id, status, start_time, end_time, result, error FROM job_executions WHERE status = ? AND id > ? ORDER BY id DESC LIMIT ?`
-		args = []interface{}{status, lastID, pageSize}
-	} else {
-		query = `SELECT id, job_id, status, start_time, end_time, result, error FROM job_executions WHERE id > ? ORDER BY id DESC LIMIT ?`
-		args = []interface{}{lastID, pageSize}
-	}
-
-	iter := client.Session.Query(query, args...).Iter()
-	for {
-		var execution Execution
-		if !iter.Scan(&execution.ID, &execution.JobID, &execution.Status, &execution.StartTime, &execution.EndTime, &execution.Result, &execution.Error) {
-			break
-		}
-		executions = append(executions, &execution)
-	}
-	if err := iter.Close(); err != nil {
-		return nil, err
-	}
-	return executions, nil
-}
-
-func UpdateExecution(client *database.CassandraClient, execution *Execution) error {
-	query := `UPDATE job_executions SET status = ?, end_time = ?, result = ?, error = ? WHERE id = ? AND job_id = ?`
-	return client.Session.Query(query, execution.Status, execution.EndTime, execution.Result, execution.Error, execution.ID, execution.JobID).Exec()
+	return resp
 }
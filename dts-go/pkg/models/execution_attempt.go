@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+	pb "github.com/nedson202/dts-go/proto/execution/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ExecutionAttempt is a single try at running an execution. Attempts are
+// numbered from 1.
+type ExecutionAttempt struct {
+	ExecutionID gocql.UUID `json:"execution_id"`
+	Attempt     int        `json:"attempt"`
+	JobID       gocql.UUID `json:"job_id"`
+	Status      string     `json:"status"`
+	StartTime   time.Time  `json:"start_time"`
+	EndTime     *time.Time `json:"end_time"`
+	WorkerID    string     `json:"worker_id"`
+	Error       string     `json:"error"`
+}
+
+func (a *ExecutionAttempt) ToProto() *pb.ExecutionAttempt {
+	resp := &pb.ExecutionAttempt{
+		Attempt:   int32(a.Attempt),
+		Status:    pb.ExecutionStatus(pb.ExecutionStatus_value[a.Status]),
+		StartTime: timestamppb.New(a.StartTime),
+		WorkerId:  a.WorkerID,
+		Error:     a.Error,
+	}
+	if a.EndTime != nil {
+		resp.EndTime = timestamppb.New(*a.EndTime)
+	}
+	return resp
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+	pb "github.com/nedson202/dts-go/proto/execution/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+	LogStreamSystem = "system"
+)
+
+type ExecutionLogLine struct {
+	ExecutionID gocql.UUID `json:"execution_id"`
+	Seq         int64      `json:"seq"`
+	Stream      string     `json:"stream"`
+	Timestamp   time.Time  `json:"timestamp"`
+	Line        string     `json:"line"`
+}
+
+func (l *ExecutionLogLine) ToProto() *pb.ExecutionLogLine {
+	return &pb.ExecutionLogLine{
+		ExecutionId: l.ExecutionID.String(),
+		Seq:         l.Seq,
+		Stream:      l.Stream,
+		Timestamp:   timestamppb.New(l.Timestamp),
+		Line:        l.Line,
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// ExecutionHeartbeat tracks a running execution so that executions abandoned
+// by a dead worker can be detected and re-enqueued.
+type ExecutionHeartbeat struct {
+	ExecutionID    gocql.UUID
+	JobID          gocql.UUID
+	WorkerID       string
+	IdempotencyKey string
+	RetryCount     int
+	StartTime      time.Time
+	HeartbeatAt    time.Time
+}
@@ -1,12 +1,10 @@
 package models
 
 import (
+	"hash/fnv"
 	"time"
 
 	"github.com/gocql/gocql"
-	"github.com/nedson202/dts-go/pkg/database"
-	"github.com/nedson202/dts-go/pkg/logger"
-	"github.com/nedson202/dts-go/pkg/utils"
 	pb "github.com/nedson202/dts-go/proto/job/v1"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -22,6 +20,27 @@ type Job struct {
 	LastRun        *time.Time
 	Metadata       map[string]string
 	NextRun        time.Time
+	Resources      Resources
+	Priority       int
+	Namespace      string
+	// TraceContext is the trace context of the API call that created the job;
+	// the runs of the job link back to it.
+	TraceContext map[string]string
+}
+
+// DefaultNamespace holds jobs created without a namespace.
+const DefaultNamespace = "default"
+
+// DueJobShards is the number of partitions the jobs of one priority are
+// spread over in the index of jobs by next run.
+const DueJobShards = 16
+
+// DueJobShard returns the partition of the index of jobs by next run the job
+// is kept in.
+func (j *Job) DueJobShard() int {
+	h := fnv.New32a()
+	h.Write(j.ID[:])
+	return int(h.Sum32() % DueJobShards)
 }
 
 func (j *Job) ToProto() *pb.JobResponse {
@@ -35,6 +54,9 @@ func (j *Job) ToProto() *pb.JobResponse {
 		UpdatedAt:      timestamppb.New(j.UpdatedAt),
 		NextRun:        timestamppb.New(j.NextRun),
 		Metadata:       j.Metadata,
+		Resources:      j.Resources.ToProto(),
+		Priority:       int32(j.Priority),
+		Namespace:      j.Namespace,
 	}
 
 	if j.LastRun != nil {
@@ -60,6 +82,9 @@ func JobFromProto(pbJob *pb.JobResponse) (*Job, error) {
 		UpdatedAt:      pbJob.UpdatedAt.AsTime(),
 		NextRun:        pbJob.NextRun.AsTime(),
 		Metadata:       pbJob.Metadata,
+		Resources:      ResourcesFromProto(pbJob.Resources),
+		Priority:       int(pbJob.Priority),
+		Namespace:      pbJob.Namespace,
 	}
 
 	if pbJob.LastRun != nil {
@@ -69,114 +94,3 @@ func JobFromProto(pbJob *pb.JobResponse) (*Job, error) {
 
 	return job, nil
 }
-
-func CreateJob(cassandraClient *database.CassandraClient, job *Job) error {
-	if job.Status == pb.JobStatus_UNSPECIFIED.String() {
-		job.Status = pb.JobStatus_PENDING.String()
-	}
-	nextRun, err := utils.CalculateNextRun(job.CronExpression, time.Now())
-	if err != nil {
-		logger.Error().Err(err).Msgf("Error calculating next run time for job %s", job.ID)
-		return err
-	}
-	job.NextRun = nextRun
-	return cassandraClient.Session.Query(
-		"INSERT INTO jobs (id, name, description, cron_expression, status_text, created_at, updated_at, last_run, next_run, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		job.ID, job.Name, job.Description, job.CronExpression, job.Status, job.CreatedAt, job.UpdatedAt, job.LastRun, job.NextRun, job.Metadata,
-	).Exec()
-}
-
-func GetJob(cassandraClient *database.CassandraClient, id gocql.UUID) (*Job, error) {
-	var job Job
-	var lastRun time.Time
-	err := cassandraClient.Session.Query(
-		"SELECT id, name, description, cron_expression, status_text, created_at, updated_at, last_run, next_run, metadata FROM jobs WHERE id = ?",
-		id,
-	).Scan(&job.ID, &job.Name, &job.Description, &job.CronExpression, &job.Status, &job.CreatedAt, &job.UpdatedAt, &lastRun, &job.NextRun, &job.Metadata)
-	if err != nil {
-		return nil, err
-	}
-	if !lastRun.IsZero() {
-		job.LastRun = &lastRun
-	}
-	return &job, nil
-}
-
-func ListJobs(cassandraClient *database.CassandraClient, pageSize int, lastID gocql.UUID, status string) ([]*Job, error) {
-	var jobs []*Job
-	var query string
-	var args []interface{}
-
-	nilUUID := gocql.UUID{}
-	if status != "" {
-		if lastID != nilUUID {
-			query = "SELECT id, name, description, cron_expression, status_text, created_at, updated_at, last_run, next_run, metadata FROM jobs WHERE status_text = ? AND token(id) > token(?) LIMIT ? ALLOW FILTERING"
-			args = []interface{}{status, lastID, pageSize}
-		} else {
-			query = "SELECT id, name, description, cron_expression, status_text, created_at, updated_at, last_run, next_run, metadata FROM jobs WHERE status_text = ? LIMIT ? ALLOW FILTERING"
-			args = []interface{}{status, pageSize}
-		}
-	} else {
-		if lastID != nilUUID {
-			query = "SELECT id, name, description, cron_expression, status_text, created_at, updated_at, last_run, next_run, metadata FROM jobs WHERE token(id) > token(?) LIMIT ?"
-			args = []interface{}{lastID, pageSize}
-		} else {
-			query = "SELECT id, name, description, cron_expression, status_text, created_at, updated_at, last_run, next_run, metadata FROM jobs LIMIT ?"
-			args = []interface{}{pageSize}
-		}
-	}
-
-	iter := cassandraClient.Session.Query(query, args...).Iter()
-	for {
-		var job Job
-		var lastRun time.Time
-		if !iter.Scan(&job.ID, &job.Name, &job.Description, &job.CronExpression, &job.Status, &job.CreatedAt, &job.UpdatedAt, &lastRun, &job.NextRun, &job.Metadata) {
-			break
-		}
-		if !lastRun.IsZero() {
-			job.LastRun = &lastRun
-		}
-		jobs = append(jobs, &job)
-	}
-
-	return jobs, nil
-}
-
-func UpdateJob(cassandraClient *database.CassandraClient, job *Job) error {
-	nextRun, err := utils.CalculateNextRun(job.CronExpression, time.Now())
-	if err != nil {
-		return err
-	}
-	job.NextRun = nextRun
-	return cassandraClient.Session.Query(
-		"UPDATE jobs SET name = ?, description = ?, cron_expression = ?, status_text = ?, updated_at = ?, last_run = ?, next_run = ?, metadata = ? WHERE id = ?",
-		job.Name, job.Description, job.CronExpression, job.Status, job.UpdatedAt, job.LastRun, job.NextRun, job.Metadata, job.ID,
-	).Exec()
-}
-
-func DeleteJob(cassandraClient *database.CassandraClient, id gocql.UUID) error {
-	return cassandraClient.Session.Query("DELETE FROM jobs WHERE id = ?", id).Exec()
-}
-
-func GetJobsDueForExecution(client *database.CassandraClient, limit int) ([]*Job, error) {
-	now := time.Now().Truncate(time.Minute)
-	query := "SELECT id, name, description, cron_expression, status_text, created_at, updated_at, last_run, next_run, metadata FROM jobs WHERE next_run <= ? LIMIT ? ALLOW FILTERING"
-	iter := client.Session.Query(query, now, limit).Iter()
-	var jobs []*Job
-	for {
-		var job Job
-		if !iter.Scan(&job.ID, &job.Name, &job.Description, &job.CronExpression, &job.Status, &job.CreatedAt, &job.UpdatedAt, &job.LastRun, &job.NextRun, &job.Metadata) {
-			break
-		}
-		jobs = append(jobs, &job)
-	}
-	if err := iter.Close(); err != nil {
-		return nil, err
-	}
-	return jobs, nil
-}
-
-func UpdateJobLastRun(client *database.CassandraClient, jobID gocql.UUID, lastRun time.Time) error {
-	query := "UPDATE jobs SET last_run = ? WHERE id = ?"
-	return client.Session.Query(query, lastRun, jobID).Exec()
-}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var namespaceNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type Namespace struct {
+	Name        string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (n *Namespace) ToProto() *pb.Namespace {
+	return &pb.Namespace{
+		Name:        n.Name,
+		Description: n.Description,
+		CreatedAt:   timestamppb.New(n.CreatedAt),
+		UpdatedAt:   timestamppb.New(n.UpdatedAt),
+	}
+}
+
+// ValidateNamespaceName checks that a name is a lowercase DNS label, so it can
+// be used in URLs and topic names.
+func ValidateNamespaceName(name string) error {
+	if !namespaceNamePattern.MatchString(name) {
+		return fmt.Errorf("namespace name must be 1-63 lowercase letters, digits or dashes, starting and ending with a letter or digit")
+	}
+	return nil
+}
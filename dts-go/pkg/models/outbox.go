@@ -0,0 +1,38 @@
+package models
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// OutboxShards is the number of partitions the outbox is spread over.
+const OutboxShards = 16
+
+// OutboxEntry is a message waiting to be published to Kafka.
+type OutboxEntry struct {
+	Shard        int
+	ID           gocql.UUID
+	Topic        string
+	Key          []byte
+	Payload      []byte
+	CreatedAt    time.Time
+	ClaimedUntil time.Time
+	SentAt       *time.Time
+	// TraceContext is the trace context the entry is published under.
+	TraceContext map[string]string
+}
+
+func NewOutboxEntry(id gocql.UUID, topic string, key, payload []byte) *OutboxEntry {
+	h := fnv.New32a()
+	h.Write(id[:])
+	return &OutboxEntry{
+		Shard:     int(h.Sum32() % OutboxShards),
+		ID:        id,
+		Topic:     topic,
+		Key:       key,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/config"
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+)
+
+// Quota limits what the jobs of a namespace may use. Zero values are
+// unlimited.
+type Quota struct {
+	MaxJobs                 int
+	MinScheduleInterval     time.Duration
+	MaxConcurrentExecutions int
+	MaxExecutionsPerHour    int
+}
+
+// DefaultQuota is the quota of namespaces that have none of their own.
+func DefaultQuota(cfg *config.Config) Quota {
+	return Quota{
+		MaxJobs:                 cfg.QuotaMaxJobs,
+		MinScheduleInterval:     cfg.QuotaMinScheduleInterval,
+		MaxConcurrentExecutions: cfg.QuotaMaxConcurrentRuns,
+		MaxExecutionsPerHour:    cfg.QuotaMaxRunsPerHour,
+	}
+}
+
+func QuotaFromProto(q *pb.Quota) Quota {
+	if q == nil {
+		return Quota{}
+	}
+	return Quota{
+		MaxJobs:                 int(q.MaxJobs),
+		MinScheduleInterval:     time.Duration(q.MinScheduleIntervalSeconds) * time.Second,
+		MaxConcurrentExecutions: int(q.MaxConcurrentExecutions),
+		MaxExecutionsPerHour:    int(q.MaxExecutionsPerHour),
+	}
+}
+
+func (q Quota) ToProto() *pb.Quota {
+	return &pb.Quota{
+		MaxJobs:                    int32(q.MaxJobs),
+		MinScheduleIntervalSeconds: int32(q.MinScheduleInterval / time.Second),
+		MaxConcurrentExecutions:    int32(q.MaxConcurrentExecutions),
+		MaxExecutionsPerHour:       int32(q.MaxExecutionsPerHour),
+	}
+}
+
+// WithDefaults fills the limits that are not set with those of defaults.
+func (q Quota) WithDefaults(defaults Quota) Quota {
+	if q.MaxJobs == 0 {
+		q.MaxJobs = defaults.MaxJobs
+	}
+	if q.MinScheduleInterval == 0 {
+		q.MinScheduleInterval = defaults.MinScheduleInterval
+	}
+	if q.MaxConcurrentExecutions == 0 {
+		q.MaxConcurrentExecutions = defaults.MaxConcurrentExecutions
+	}
+	if q.MaxExecutionsPerHour == 0 {
+		q.MaxExecutionsPerHour = defaults.MaxExecutionsPerHour
+	}
+	return q
+}
+
+type QuotaUsage struct {
+	Jobs                 int
+	ConcurrentExecutions int
+	ExecutionsThisHour   int
+}
+
+func (u QuotaUsage) ToProto() *pb.QuotaUsage {
+	return &pb.QuotaUsage{
+		Jobs:                 int32(u.Jobs),
+		ConcurrentExecutions: int32(u.ConcurrentExecutions),
+		ExecutionsThisHour:   int32(u.ExecutionsThisHour),
+	}
+}
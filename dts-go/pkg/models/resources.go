@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+)
+
+type Resources struct {
+	CPU     int
+	Memory  int
+	Storage int
+}
+
+func ResourcesFromProto(r *pb.ResourceRequirements) Resources {
+	if r == nil {
+		return Resources{}
+	}
+	return Resources{CPU: int(r.Cpu), Memory: int(r.Memory), Storage: int(r.Storage)}
+}
+
+func (r Resources) ToProto() *pb.ResourceRequirements {
+	return &pb.ResourceRequirements{Cpu: int32(r.CPU), Memory: int32(r.Memory), Storage: int32(r.Storage)}
+}
+
+func (r Resources) IsZero() bool {
+	return r == Resources{}
+}
+
+// Fits reports whether r can be carved out of available.
+func (r Resources) Fits(available Resources) bool {
+	return r.CPU <= available.CPU && r.Memory <= available.Memory && r.Storage <= available.Storage
+}
+
+func (r Resources) Validate() error {
+	if r.CPU < 0 || r.Memory < 0 || r.Storage < 0 {
+		return fmt.Errorf("resource requirements must not be negative")
+	}
+	return nil
+}
+
+// Sub returns what is left of r after taking o out of it.
+func (r Resources) Sub(o Resources) Resources {
+	return Resources{CPU: r.CPU - o.CPU, Memory: r.Memory - o.Memory, Storage: r.Storage - o.Storage}
+}
+
+// Add returns r with o given back to it.
+func (r Resources) Add(o Resources) Resources {
+	return Resources{CPU: r.CPU + o.CPU, Memory: r.Memory + o.Memory, Storage: r.Storage + o.Storage}
+}
+
+// ResourcePool is the remaining capacity of the fleet together with the
+// reservations taken from it.
+type ResourcePool struct {
+	Available    Resources
+	Reservations map[string]Resources
+}
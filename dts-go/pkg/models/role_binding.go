@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AllNamespaces is the namespace of role bindings that apply in every
+// namespace.
+const AllNamespaces = "*"
+
+type RoleBinding struct {
+	Namespace string
+	Subject   string
+	Role      string
+	CreatedAt time.Time
+	CreatedBy string
+}
+
+func (b *RoleBinding) ToProto() *pb.RoleBinding {
+	return &pb.RoleBinding{
+		Namespace: b.Namespace,
+		Subject:   b.Subject,
+		Role:      b.Role,
+		CreatedAt: timestamppb.New(b.CreatedAt),
+		CreatedBy: b.CreatedBy,
+	}
+}
@@ -0,0 +1,241 @@
+// Package mtls builds the TLS credentials the services use for gRPC. The
+// certificate, key and CA bundle are re-read whenever the files change, so
+// short-lived certificates can be rotated without restarts.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/config"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Options locate the PEM files. CertFile and KeyFile are the certificate
+// presented to peers; CAFile verifies them, and on servers makes client
+// certificates mandatory.
+type Options struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// ServerName overrides the name servers' certificates are verified
+	// against; it defaults to the host being dialed.
+	ServerName     string
+	ReloadInterval time.Duration
+	// Server marks credentials that a gRPC server is served with, which
+	// need a certificate of their own.
+	Server bool
+}
+
+// Credentials holds the current certificate and CA pool. A nil *Credentials
+// stands for plaintext connections.
+type Credentials struct {
+	opts Options
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+// FromConfig returns the credentials configured for the services, which all
+// serve gRPC, or nil when TLS is not configured.
+func FromConfig(cfg *config.Config) (*Credentials, error) {
+	return New(Options{
+		CertFile:       cfg.TLSCertFile,
+		KeyFile:        cfg.TLSKeyFile,
+		CAFile:         cfg.TLSCAFile,
+		ServerName:     cfg.TLSServerName,
+		ReloadInterval: cfg.TLSReloadInterval,
+		Server:         true,
+	})
+}
+
+// New loads the files and starts watching them for changes. It returns nil
+// when no file is configured.
+func New(opts Options) (*Credentials, error) {
+	if opts.CertFile == "" && opts.KeyFile == "" && opts.CAFile == "" {
+		return nil, nil
+	}
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, fmt.Errorf("TLS certificate and key must be configured together")
+	}
+	if opts.Server && opts.CertFile == "" {
+		return nil, fmt.Errorf("TLS CA bundle is configured without a certificate and key, which a server needs to serve TLS")
+	}
+
+	c := &Credentials{opts: opts}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	if opts.ReloadInterval > 0 {
+		go c.watch()
+	}
+	return c, nil
+}
+
+// ServerOptions returns the options that make a gRPC server serve TLS.
+func (c *Credentials) ServerOptions() []grpc.ServerOption {
+	if c == nil {
+		return nil
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := c.current()
+			if cert == nil {
+				return nil, fmt.Errorf("no TLS certificate configured")
+			}
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2"},
+			}
+			if pool != nil {
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}))}
+}
+
+// DialOption returns the transport credentials for dialing another service.
+func (c *Credentials) DialOption() grpc.DialOption {
+	if c == nil {
+		return grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+	return c.dialOption(c.opts.ServerName)
+}
+
+// LoopbackDialOption returns the transport credentials for a service
+// dialing its own gRPC server, as the grpc-gateway does. The server's
+// certificate is verified against the first DNS name it carries, since the
+// loopback address is not among them.
+func (c *Credentials) LoopbackDialOption() grpc.DialOption {
+	if c == nil {
+		return grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+	serverName := "localhost"
+	if cert, _ := c.current(); cert != nil && cert.Leaf != nil && len(cert.Leaf.DNSNames) > 0 {
+		serverName = cert.Leaf.DNSNames[0]
+	}
+	return c.dialOption(serverName)
+}
+
+func (c *Credentials) dialOption(serverName string) grpc.DialOption {
+	return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert, _ := c.current(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+		// The CA pool can change after the connection is configured, so the
+		// server certificate is verified by hand against the current pool.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			_, pool := c.current()
+			return verifyPeer(state, pool)
+		},
+	}))
+}
+
+func verifyPeer(state tls.ConnectionState, pool *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("server presented no certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       state.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+func (c *Credentials) current() (*tls.Certificate, *x509.CertPool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, c.pool
+}
+
+func (c *Credentials) watch() {
+	ticker := time.NewTicker(c.opts.ReloadInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		reloaded, err := c.reload()
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to reload TLS files, keeping the previous ones")
+			continue
+		}
+		if reloaded {
+			logger.Info().Msg("Reloaded TLS certificate and CA bundle")
+		}
+	}
+}
+
+// reload re-reads the files if any of them changed since the last load.
+func (c *Credentials) reload() (bool, error) {
+	modTimes := make(map[string]time.Time, 3)
+	changed := c.modTimes == nil
+	for _, path := range []string{c.opts.CertFile, c.opts.KeyFile, c.opts.CAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		modTimes[path] = info.ModTime()
+		if !info.ModTime().Equal(c.modTimes[path]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	var cert *tls.Certificate
+	if c.opts.CertFile != "" {
+		loaded, err := tls.LoadX509KeyPair(c.opts.CertFile, c.opts.KeyFile)
+		if err != nil {
+			return false, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		if loaded.Leaf == nil {
+			if loaded.Leaf, err = x509.ParseCertificate(loaded.Certificate[0]); err != nil {
+				return false, fmt.Errorf("parsing TLS certificate: %w", err)
+			}
+		}
+		cert = &loaded
+	}
+
+	var pool *x509.CertPool
+	if c.opts.CAFile != "" {
+		pem, err := os.ReadFile(c.opts.CAFile)
+		if err != nil {
+			return false, fmt.Errorf("reading TLS CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("no certificates found in TLS CA bundle %s", c.opts.CAFile)
+		}
+	}
+
+	c.mu.Lock()
+	c.cert, c.pool, c.modTimes = cert, pool, modTimes
+	c.mu.Unlock()
+	return true, nil
+}
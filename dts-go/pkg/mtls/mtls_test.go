@@ -0,0 +1,207 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+// testCA issues certificates for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for localhost with the given common name and
+// its key to dir, and returns their paths.
+func (ca *testCA) issue(t *testing.T, dir, commonName string, serial int64) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+// writeFile writes data and moves the modification time forward, so that a
+// rewrite within the resolution of the file system is still noticed.
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil && !modTime.After(info.ModTime()) {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewRejectsServerWithoutCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+
+	if _, err := New(Options{CAFile: caFile, Server: true}); err == nil {
+		t.Fatal("server credentials with only a CA bundle were accepted")
+	}
+	// A client may verify servers without presenting a certificate itself
+	if c, err := New(Options{CAFile: caFile}); err != nil || c == nil {
+		t.Fatalf("client credentials with only a CA bundle: got %v, %v", c, err)
+	}
+	certFile, _ := ca.issue(t, dir, "server", 2)
+	if _, err := New(Options{CertFile: certFile, CAFile: caFile, Server: true}); err == nil {
+		t.Fatal("a certificate without its key was accepted")
+	}
+}
+
+// serverCommonName dials addr and returns the common name of the certificate
+// the server presented.
+func serverCommonName(t *testing.T, client *Credentials, addr string) string {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, client.DialOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var p peer.Peer
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Peer(&p)); err != nil {
+		t.Fatal(err)
+	}
+	state := p.AuthInfo.(credentials.TLSInfo).State
+	return state.PeerCertificates[0].Subject.CommonName
+}
+
+func TestCredentialsReloadCertificate(t *testing.T) {
+	serverDir, clientDir := t.TempDir(), t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(serverDir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+
+	certFile, keyFile := ca.issue(t, serverDir, "first", 2)
+	server, err := New(Options{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, ReloadInterval: 10 * time.Millisecond, Server: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, clientKey := ca.issue(t, clientDir, "client", 3)
+	client, err := New(Options{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile, ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(server.ServerOptions()...)
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+	addr := listener.Addr().String()
+
+	if name := serverCommonName(t, client, addr); name != "first" {
+		t.Fatalf("server presented %q, want the first certificate", name)
+	}
+
+	// Rotating the files is picked up by new connections without a restart
+	ca.issue(t, serverDir, "second", 4)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if cert, _ := server.current(); cert.Leaf.Subject.CommonName == "second" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the rotated certificate was not loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if name := serverCommonName(t, client, addr); name != "second" {
+		t.Fatalf("server presented %q after the rotation, want the second certificate", name)
+	}
+
+	// A client without a certificate is turned away
+	anonymous, err := New(Options{CAFile: caFile, ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.NewClient(addr, anonymous.DialOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err == nil {
+		t.Fatal("a client without a certificate was served")
+	}
+}
@@ -0,0 +1,36 @@
+package queue
+
+import "github.com/twmb/franz-go/pkg/kgo"
+
+// headerCarrier lets the OpenTelemetry propagator read and write trace
+// context in the headers of a Kafka record.
+type headerCarrier struct {
+	record *kgo.Record
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, header := range c.record.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, header := range c.record.Headers {
+		if header.Key == key {
+			c.record.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.record.Headers = append(c.record.Headers, kgo.RecordHeader{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(c.record.Headers))
+	for i, header := range c.record.Headers {
+		keys[i] = header.Key
+	}
+	return keys
+}
@@ -2,15 +2,62 @@ package queue
 
 import (
 	"context"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/metrics"
+	"github.com/nedson202/dts-go/pkg/tracing"
+	"github.com/rs/zerolog"
 	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	producedMessages = metrics.NewCounterVec("dts_kafka_produced_messages_total",
+		"Messages produced to Kafka, by topic and result.", "topic", "result")
+	consumedMessages = metrics.NewCounterVec("dts_kafka_consumed_messages_total",
+		"Messages consumed from Kafka, by topic.", "topic")
+	consumerLag = metrics.NewGaugeVec("dts_kafka_consumer_lag",
+		"Messages between the last consumed offset and the high watermark, by topic and partition.", "topic", "partition")
+)
+
+// The consume loop logs every poll and every record; these samplers keep the
+// first few of each period so a busy or idle consumer does not flood the logs.
+var (
+	pollLogSampler    = &zerolog.BurstSampler{Burst: 1, Period: time.Minute}
+	receiveLogSampler = &zerolog.BurstSampler{Burst: 10, Period: time.Second}
+)
+
+var (
+	_ Producer = (*KafkaClient)(nil)
+	_ Consumer = (*KafkaClient)(nil)
+	_ Queue    = (*Kafka)(nil)
+)
+
+// Kafka creates clients of a Kafka cluster.
+type Kafka struct {
+	brokers []string
+}
+
+func NewKafka(brokers []string) *Kafka {
+	return &Kafka{brokers: brokers}
+}
+
+func (k *Kafka) NewProducer() (Producer, error) {
+	return NewKafkaProducer(k.brokers)
+}
+
+func (k *Kafka) NewConsumer(groupID, topic string) (Consumer, error) {
+	return NewKafkaClient(k.brokers, groupID, topic)
+}
+
 type KafkaClient struct {
 	client   *kgo.Client
-	messages chan []byte
+	messages chan *Message
 	ctx      context.Context
 	cancel   context.CancelFunc
 	wg       sync.WaitGroup
@@ -18,12 +65,21 @@ type KafkaClient struct {
 
 func NewKafkaClient(brokers []string, groupID string, topic string) (*KafkaClient, error) {
 	logger.Info().Msgf("Starting Kafka client for topic: %v", topic)
-	opts := []kgo.Opt{
+	return newKafkaClient(
 		kgo.SeedBrokers(brokers...),
 		kgo.ConsumerGroup(groupID),
 		kgo.ConsumeTopics(topic),
-	}
+	)
+}
+
+// NewKafkaProducer creates a client that only produces. Unlike NewKafkaClient
+// it joins no consumer group.
+func NewKafkaProducer(brokers []string) (*KafkaClient, error) {
+	logger.Info().Msg("Starting Kafka producer")
+	return newKafkaClient(kgo.SeedBrokers(brokers...))
+}
 
+func newKafkaClient(opts ...kgo.Opt) (*KafkaClient, error) {
 	client, err := kgo.NewClient(opts...)
 	if err != nil {
 		logger.Error().Err(err).Msgf("Error creating Kafka client: %v", err)
@@ -33,7 +89,7 @@ func NewKafkaClient(brokers []string, groupID string, topic string) (*KafkaClien
 	ctx, cancel := context.WithCancel(context.Background())
 	return &KafkaClient{
 		client:   client,
-		messages: make(chan []byte),
+		messages: make(chan *Message),
 		ctx:      ctx,
 		cancel:   cancel,
 	}, nil
@@ -44,16 +100,25 @@ func (kc *KafkaClient) Consume() error {
 	go func() {
 		defer kc.wg.Done()
 		for {
-			logger.Info().Msgf("Polling for messages...")
+			logger.Sampled(pollLogSampler).Info().Msgf("Polling for messages...")
 			fetches := kc.client.PollFetches(kc.ctx)
 			if fetches.IsClientClosed() {
 				logger.Info().Msgf("Kafka client is closed")
 				close(kc.messages)
 				return
 			}
+			fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+				if len(p.Records) == 0 {
+					return
+				}
+				last := p.Records[len(p.Records)-1]
+				consumerLag.Set(float64(p.HighWatermark-last.Offset-1), p.Topic, strconv.Itoa(int(p.Partition)))
+			})
 			fetches.EachRecord(func(record *kgo.Record) {
-				logger.Info().Msgf("Received message: %s", string(record.Value))
-				kc.messages <- record.Value
+				logger.Sampled(receiveLogSampler).Info().Msgf("Received message: %s", string(record.Value))
+				consumedMessages.Inc(record.Topic)
+				ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier{record: record})
+				kc.messages <- &Message{Topic: record.Topic, Value: record.Value, ctx: ctx}
 			})
 		}
 	}()
@@ -61,17 +126,39 @@ func (kc *KafkaClient) Consume() error {
 	return nil
 }
 
-func (kc *KafkaClient) Messages() <-chan []byte {
+func (kc *KafkaClient) Messages() <-chan *Message {
 	return kc.messages
 }
 
+// Produce sends a record to topic and waits for it to be acknowledged. The
+// trace context of ctx is sent along in the record headers.
 func (kc *KafkaClient) Produce(ctx context.Context, topic string, key, value []byte) error {
+	ctx, span := tracing.Tracer().Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", topic),
+		),
+	)
 	record := &kgo.Record{
 		Topic: topic,
 		Key:   key,
 		Value: value,
 	}
-	return kc.client.ProduceSync(ctx, record).FirstErr()
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{record: record})
+	err := kc.client.ProduceSync(ctx, record).FirstErr()
+	tracing.End(span, err)
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	producedMessages.Inc(topic, result)
+	return err
+}
+
+// Ping checks that at least one broker is reachable.
+func (kc *KafkaClient) Ping(ctx context.Context) error {
+	return kc.client.Ping(ctx)
 }
 
 func (kc *KafkaClient) Close() error {
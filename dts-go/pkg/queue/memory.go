@@ -0,0 +1,219 @@
+package queue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	_ Queue    = (*MemoryQueue)(nil)
+	_ Producer = (*MemoryQueue)(nil)
+	_ Consumer = (*memoryConsumer)(nil)
+)
+
+// memoryTopicRetention is the number of messages a topic keeps at most. Like
+// Kafka's retention, it drops the oldest messages of a topic no group reads,
+// or of one a group has stopped reading, rather than let it grow unbounded.
+const memoryTopicRetention = 10000
+
+// MemoryQueue passes messages between the producers and consumers created
+// from it. Like a Kafka topic, a topic keeps its messages until every
+// consumer group reading it has received them, up to memoryTopicRetention,
+// so a group that is created before the first message is produced misses
+// none. Nothing is persisted.
+type MemoryQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	topics map[string]*memoryTopic
+}
+
+type memoryTopic struct {
+	messages []*Message
+	// first is the offset of messages[0], and groups holds the offset of the
+	// next message of each consumer group.
+	first  int
+	groups map[string]int
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	q := &MemoryQueue{topics: make(map[string]*memoryTopic)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// topic returns the named topic, creating it if needed. q.mu must be held.
+func (q *MemoryQueue) topic(name string) *memoryTopic {
+	t, ok := q.topics[name]
+	if !ok {
+		t = &memoryTopic{groups: make(map[string]int)}
+		q.topics[name] = t
+	}
+	return t
+}
+
+// NewProducer returns q itself; producing needs no connection of its own.
+func (q *MemoryQueue) NewProducer() (Producer, error) {
+	return q, nil
+}
+
+func (q *MemoryQueue) NewConsumer(groupID, topic string) (Consumer, error) {
+	q.mu.Lock()
+	t := q.topic(topic)
+	if _, ok := t.groups[groupID]; !ok {
+		t.groups[groupID] = t.first
+	}
+	q.mu.Unlock()
+
+	return &memoryConsumer{
+		queue:    q,
+		groupID:  groupID,
+		topic:    topic,
+		messages: make(chan *Message),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func (q *MemoryQueue) Produce(ctx context.Context, topic string, key, value []byte) error {
+	ctx, span := tracing.Tracer().Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "memory"),
+			attribute.String("messaging.destination.name", topic),
+		),
+	)
+	defer span.End()
+
+	// Carry only the propagated trace context, as Kafka headers would, not
+	// the deadline or values of ctx
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	message := &Message{
+		Topic: topic,
+		Value: value,
+		ctx:   otel.GetTextMapPropagator().Extract(context.Background(), carrier),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.topic(topic)
+	t.messages = append(t.messages, message)
+	if len(t.messages) > memoryTopicRetention {
+		t.drop(len(t.messages) - memoryTopicRetention)
+	}
+	q.cond.Broadcast()
+	return nil
+}
+
+func (q *MemoryQueue) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close does nothing: the queue lives as long as the process, and so do the
+// messages its producers have sent.
+func (q *MemoryQueue) Close() error {
+	return nil
+}
+
+// next waits for the next message of c's group. It reports false once c is
+// closed.
+func (q *MemoryQueue) next(c *memoryConsumer) (*Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t := q.topics[c.topic]
+	for !c.closed && t.groups[c.groupID] >= t.first+len(t.messages) {
+		q.cond.Wait()
+	}
+	if c.closed {
+		return nil, false
+	}
+
+	// Skip the messages dropped before the group received them
+	offset := t.groups[c.groupID]
+	if offset < t.first {
+		offset = t.first
+	}
+	message := t.messages[offset-t.first]
+	t.groups[c.groupID] = offset + 1
+	t.trim()
+	return message, true
+}
+
+// trim drops the messages every group has received.
+func (t *memoryTopic) trim() {
+	received := -1
+	for _, offset := range t.groups {
+		if received == -1 || offset < received {
+			received = offset
+		}
+	}
+	if n := received - t.first; n > 0 {
+		t.drop(n)
+	}
+}
+
+// drop removes the oldest n messages.
+func (t *memoryTopic) drop(n int) {
+	for i := 0; i < n; i++ {
+		t.messages[i] = nil
+	}
+	t.messages = t.messages[n:]
+	t.first += n
+}
+
+type memoryConsumer struct {
+	queue    *MemoryQueue
+	groupID  string
+	topic    string
+	messages chan *Message
+	done     chan struct{}
+	// closed is guarded by queue.mu.
+	closed    bool
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func (c *memoryConsumer) Consume() error {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			message, ok := c.queue.next(c)
+			if !ok {
+				return
+			}
+			select {
+			case c.messages <- message:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *memoryConsumer) Messages() <-chan *Message {
+	return c.messages
+}
+
+func (c *memoryConsumer) Close() error {
+	c.closeOnce.Do(func() {
+		logger.Info().Msgf("Closing in-memory consumer of %s", c.topic)
+		c.queue.mu.Lock()
+		c.closed = true
+		c.queue.cond.Broadcast()
+		c.queue.mu.Unlock()
+
+		close(c.done)
+		c.wg.Wait()
+		close(c.messages)
+	})
+	return nil
+}
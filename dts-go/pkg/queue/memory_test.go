@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, consumer Consumer) string {
+	t.Helper()
+	select {
+	case message := <-consumer.Messages():
+		return string(message.Value)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+		return ""
+	}
+}
+
+func TestMemoryQueueDeliversToEveryGroup(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue()
+
+	var consumers []Consumer
+	for _, group := range []string{"a", "a", "b"} {
+		consumer, err := q.NewConsumer(group, "jobs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer consumer.Close()
+		consumers = append(consumers, consumer)
+	}
+	if err := q.Produce(ctx, "jobs", nil, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Group b receives the message, and one consumer of group a does
+	consumers[2].Consume()
+	if got := receive(t, consumers[2]); got != "first" {
+		t.Fatalf("group b received %q, want first", got)
+	}
+	consumers[0].Consume()
+	if got := receive(t, consumers[0]); got != "first" {
+		t.Fatalf("group a received %q, want first", got)
+	}
+	consumers[1].Consume()
+	select {
+	case message := <-consumers[1].Messages():
+		t.Fatalf("group a received %q twice", message.Value)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n := len(q.topics["jobs"].messages); n != 0 {
+		t.Fatalf("topic kept %d messages every group received, want 0", n)
+	}
+}
+
+func TestMemoryQueueBoundsTopics(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue()
+
+	// A topic nobody consumes keeps only the newest messages
+	for i := 0; i < memoryTopicRetention+10; i++ {
+		if err := q.Produce(ctx, "unread", nil, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.mu.Lock()
+	if n := len(q.topics["unread"].messages); n != memoryTopicRetention {
+		t.Fatalf("unread topic kept %d messages, want %d", n, memoryTopicRetention)
+	}
+	q.mu.Unlock()
+
+	// A group created later starts at the oldest message kept
+	consumer, err := q.NewConsumer("late", "unread")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	consumer.Consume()
+	if got := receive(t, consumer); got != "10" {
+		t.Fatalf("late group received %q first, want 10", got)
+	}
+
+	// A group that falls behind skips the messages dropped meanwhile
+	lagging, err := q.NewConsumer("lagging", "lagging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lagging.Close()
+	for i := 0; i < memoryTopicRetention+5; i++ {
+		if err := q.Produce(ctx, "lagging", nil, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lagging.Consume()
+	if got := receive(t, lagging); got != "5" {
+		t.Fatalf("lagging group received %q first, want 5", got)
+	}
+}
@@ -0,0 +1,76 @@
+// Package queue carries dispatched jobs from the scheduler to the executors.
+//
+// The services depend only on the Producer and Consumer interfaces, created
+// by a Queue. Kafka is the production implementation; MemoryQueue passes
+// messages over channels inside the process, so that the whole pipeline can
+// run and be tested in one process. The service binaries each run in a
+// process of their own and refuse it.
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nedson202/dts-go/pkg/config"
+	"github.com/nedson202/dts-go/pkg/logger"
+)
+
+const (
+	BackendKafka  = "kafka"
+	BackendMemory = "memory"
+)
+
+// Message is a consumed record. Its context carries the trace context the
+// producer sent along with it.
+type Message struct {
+	Topic string
+	Value []byte
+	ctx   context.Context
+}
+
+// Context returns the context the message was produced under.
+func (m *Message) Context() context.Context {
+	return m.ctx
+}
+
+// Producer publishes messages to any topic.
+type Producer interface {
+	// Produce sends a message to topic and waits for it to be accepted. The
+	// trace context of ctx is sent along with it.
+	Produce(ctx context.Context, topic string, key, value []byte) error
+	// Ping checks that the queue can be reached.
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Consumer receives the messages of one topic.
+type Consumer interface {
+	// Consume starts receiving messages in the background.
+	Consume() error
+	// Messages returns the received messages. It is closed when the consumer
+	// is closed.
+	Messages() <-chan *Message
+	Close() error
+}
+
+// Queue creates the producers and consumers of a queue backend.
+type Queue interface {
+	NewProducer() (Producer, error)
+	// NewConsumer returns a consumer of topic. The consumers of a group
+	// share the messages of the topic between them, and every group receives
+	// all of them.
+	NewConsumer(groupID, topic string) (Consumer, error)
+}
+
+// Open returns the queue backend selected by cfg.QueueBackend.
+func Open(cfg *config.Config) (Queue, error) {
+	switch cfg.QueueBackend {
+	case BackendKafka:
+		return NewKafka(cfg.KafkaBrokers), nil
+	case BackendMemory:
+		logger.Warn().Msg("Using the in-memory queue; messages only reach consumers in the same process")
+		return NewMemoryQueue(), nil
+	default:
+		return nil, fmt.Errorf("unknown queue backend %q", cfg.QueueBackend)
+	}
+}
@@ -0,0 +1,124 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nedson202/dts-go/pkg/auth"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/store"
+)
+
+// Role is a set of permissions in a namespace. Each role includes the
+// permissions of the roles before it: viewers read, operators also cancel
+// and trigger runs, editors also change jobs, and admins also manage the
+// namespace, its quota and its role bindings.
+type Role string
+
+const (
+	None     Role = ""
+	Viewer   Role = "viewer"
+	Operator Role = "operator"
+	Editor   Role = "editor"
+	Admin    Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	None:     0,
+	Viewer:   1,
+	Operator: 2,
+	Editor:   3,
+	Admin:    4,
+}
+
+// ParseRole validates a role name.
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if role == None {
+		return None, fmt.Errorf("role is required")
+	}
+	if _, ok := roleRanks[role]; !ok {
+		return None, fmt.Errorf("unknown role %q, expected viewer, operator, editor or admin", name)
+	}
+	return role, nil
+}
+
+// Includes reports whether r grants every permission of required.
+func (r Role) Includes(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// Authorizer resolves the role of a caller from the stored role bindings.
+type Authorizer struct {
+	jobs store.JobStore
+	// adminSubjects are admins in every namespace regardless of bindings, so
+	// the first bindings can be created and services can call each other.
+	adminSubjects map[string]bool
+}
+
+func NewAuthorizer(jobs store.JobStore, adminSubjects []string) *Authorizer {
+	admins := make(map[string]bool, len(adminSubjects))
+	for _, subject := range adminSubjects {
+		if subject != "" {
+			admins[subject] = true
+		}
+	}
+	return &Authorizer{
+		jobs:          jobs,
+		adminSubjects: admins,
+	}
+}
+
+// Role returns the highest role subject holds in namespace, counting the
+// bindings that apply to all namespaces.
+func (a *Authorizer) Role(ctx context.Context, subject, namespace string) (Role, error) {
+	if a.adminSubjects[subject] {
+		return Admin, nil
+	}
+
+	role := None
+	namespaces := []string{models.AllNamespaces}
+	if namespace != models.AllNamespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	for _, ns := range namespaces {
+		binding, err := a.jobs.GetRoleBinding(ctx, ns, subject)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return None, err
+		}
+		if bound := Role(binding.Role); bound.Includes(role) {
+			role = bound
+		}
+	}
+	return role, nil
+}
+
+// Authorize reports whether the caller in ctx holds at least the required
+// role in namespace.
+func (a *Authorizer) Authorize(ctx context.Context, namespace string, required Role) (bool, error) {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return false, nil
+	}
+	role, err := a.Role(ctx, identity.Subject, namespace)
+	if err != nil {
+		return false, err
+	}
+	return role.Includes(required), nil
+}
+
+// Rule is the role a gRPC method requires.
+type Rule struct {
+	Role Role
+	// AllNamespaces requires the role through a binding that applies to every
+	// namespace, for methods that are not scoped to one.
+	AllNamespaces bool
+}
+
+// Rules maps full gRPC method names to the role they require. Methods
+// without a rule are denied.
+type Rules map[string]Rule
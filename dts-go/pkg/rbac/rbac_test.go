@@ -0,0 +1,102 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/auth"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/store"
+)
+
+func TestRoleIncludes(t *testing.T) {
+	ranked := []Role{None, Viewer, Operator, Editor, Admin}
+	for i, role := range ranked {
+		for j, required := range ranked {
+			if got, want := role.Includes(required), i >= j; got != want {
+				t.Errorf("%q.Includes(%q) = %v, want %v", role, required, got, want)
+			}
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, name := range []string{"viewer", "operator", "editor", "admin"} {
+		if role, err := ParseRole(name); err != nil || string(role) != name {
+			t.Errorf("ParseRole(%q) = %q, %v", name, role, err)
+		}
+	}
+	for _, name := range []string{"", "owner", "Admin"} {
+		if _, err := ParseRole(name); err == nil {
+			t.Errorf("ParseRole(%q) succeeded", name)
+		}
+	}
+}
+
+func TestAuthorizerRole(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemoryStore()
+	for _, binding := range []*models.RoleBinding{
+		{Namespace: "payments", Subject: "alice", Role: string(Viewer)},
+		{Namespace: models.AllNamespaces, Subject: "alice", Role: string(Operator)},
+		{Namespace: "payments", Subject: "bob", Role: string(Admin)},
+		{Namespace: models.AllNamespaces, Subject: "carol", Role: string(Editor)},
+		{Namespace: "payments", Subject: "carol", Role: string(Viewer)},
+	} {
+		binding.CreatedAt = time.Now()
+		if err := db.SetRoleBinding(ctx, binding); err != nil {
+			t.Fatal(err)
+		}
+	}
+	authorizer := NewAuthorizer(db, []string{"scheduler-service", ""})
+
+	tests := []struct {
+		subject, namespace string
+		want               Role
+	}{
+		// A binding to all namespaces applies in each of them
+		{"alice", "payments", Operator},
+		{"alice", "search", Operator},
+		{"alice", models.AllNamespaces, Operator},
+		// A namespace binding applies only there
+		{"bob", "payments", Admin},
+		{"bob", models.DefaultNamespace, None},
+		{"bob", models.AllNamespaces, None},
+		// The higher of the two bindings wins
+		{"carol", "payments", Editor},
+		{"scheduler-service", "payments", Admin},
+		{"scheduler-service", models.AllNamespaces, Admin},
+		{"mallory", "payments", None},
+		// An empty admin subject does not make anonymous callers admins
+		{"", "payments", None},
+	}
+	for _, tt := range tests {
+		role, err := authorizer.Role(ctx, tt.subject, tt.namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if role != tt.want {
+			t.Errorf("role of %q in %q = %q, want %q", tt.subject, tt.namespace, role, tt.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemoryStore()
+	if err := db.SetRoleBinding(ctx, &models.RoleBinding{Namespace: "payments", Subject: "alice", Role: string(Editor), CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	authorizer := NewAuthorizer(db, nil)
+
+	if allowed, err := authorizer.Authorize(ctx, "payments", Viewer); err != nil || allowed {
+		t.Fatalf("a request without an identity: got %v, %v, want denied", allowed, err)
+	}
+	alice := auth.NewContext(ctx, &auth.Identity{Subject: "alice"})
+	for required, want := range map[Role]bool{Viewer: true, Operator: true, Editor: true, Admin: false} {
+		if allowed, err := authorizer.Authorize(alice, "payments", required); err != nil || allowed != want {
+			t.Errorf("authorizing an editor for %q: got %v, %v, want %v", required, allowed, err, want)
+		}
+	}
+}
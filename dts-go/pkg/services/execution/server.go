@@ -8,8 +8,14 @@ import (
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/nedson202/dts-go/internal/execution"
+	"github.com/nedson202/dts-go/pkg/auth"
+	"github.com/nedson202/dts-go/pkg/health"
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/metrics"
 	"github.com/nedson202/dts-go/pkg/middleware"
+	"github.com/nedson202/dts-go/pkg/mtls"
+	"github.com/nedson202/dts-go/pkg/rbac"
+	"github.com/nedson202/dts-go/pkg/tracing"
 	pb "github.com/nedson202/dts-go/proto/execution/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -17,16 +23,35 @@ import (
 
 type Server struct {
 	pb.UnimplementedExecutionServiceServer
-	grpcPort string
-	httpPort string
-	service  *execution.Service
+	grpcPort      string
+	httpPort      string
+	service       *execution.Service
+	authenticator auth.Authenticator
+	authorizer    *rbac.Authorizer
+	credentials   *mtls.Credentials
+	checker       *health.Checker
 }
 
-func NewServer(service *execution.Service, grpcPort, httpPort string) *Server {
+// MethodRoles is the role each RPC requires in the namespace it operates on.
+// Every method of the service must have one; methods without are denied.
+var MethodRoles = rbac.Rules{
+	pb.ExecutionService_GetExecution_FullMethodName:        {Role: rbac.Viewer},
+	pb.ExecutionService_ListExecutions_FullMethodName:      {Role: rbac.Viewer},
+	pb.ExecutionService_StreamExecutionLogs_FullMethodName: {Role: rbac.Viewer},
+}
+
+// NewServer creates the server. A nil authenticator leaves the API open, a
+// nil authorizer lets every authenticated caller use every RPC and nil
+// credentials serve gRPC in plaintext.
+func NewServer(service *execution.Service, grpcPort, httpPort string, authenticator auth.Authenticator, authorizer *rbac.Authorizer, credentials *mtls.Credentials, checker *health.Checker) *Server {
 	return &Server{
-		service:  service,
-		grpcPort: grpcPort,
-		httpPort: httpPort,
+		service:       service,
+		grpcPort:      grpcPort,
+		httpPort:      httpPort,
+		authenticator: authenticator,
+		authorizer:    authorizer,
+		credentials:   credentials,
+		checker:       checker,
 	}
 }
 
@@ -39,6 +64,10 @@ func (s *Server) ListExecutions(ctx context.Context, req *pb.ListExecutionsReque
 	return s.service.ListExecutions(ctx, req)
 }
 
+func (s *Server) StreamExecutionLogs(req *pb.StreamExecutionLogsRequest, stream pb.ExecutionService_StreamExecutionLogsServer) error {
+	return s.service.StreamExecutionLogs(req, stream)
+}
+
 func (s *Server) Run() error {
 	// Create a listener for gRPC
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", s.grpcPort))
@@ -46,12 +75,24 @@ func (s *Server) Run() error {
 		return fmt.Errorf("failed to listen: %v", err)
 	}
 
-	// Create a gRPC server
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(middleware.UnaryServerInterceptor()),
-	)
+	// Create a gRPC server with logging, authentication and authorization interceptors,
+	// serving TLS when credentials are configured
+	grpcServer := grpc.NewServer(append([]grpc.ServerOption{
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			middleware.RequestIDUnaryServerInterceptor(),
+			middleware.UnaryServerInterceptor(),
+			middleware.AuthUnaryServerInterceptor(s.authenticator),
+			middleware.RBACUnaryServerInterceptor(s.authorizer, MethodRoles),
+		),
+		grpc.ChainStreamInterceptor(
+			middleware.AuthStreamServerInterceptor(s.authenticator),
+			middleware.RBACStreamServerInterceptor(s.authorizer, MethodRoles),
+		),
+	}, s.credentials.ServerOptions()...)...)
 	pb.RegisterExecutionServiceServer(grpcServer, s)
 	reflection.Register(grpcServer)
+	s.checker.RegisterGRPC(context.Background(), grpcServer, pb.ExecutionService_ServiceDesc.ServiceName)
 
 	// Start gRPC server
 	go func() {
@@ -65,24 +106,30 @@ func (s *Server) Run() error {
 	conn, err := grpc.DialContext(
 		context.Background(),
 		fmt.Sprintf("0.0.0.0:%s", s.grpcPort),
-		grpc.WithInsecure(),
+		s.credentials.LoopbackDialOption(),
+		tracing.DialOption(),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to dial server: %v", err)
 	}
 
-	gwmux := runtime.NewServeMux()
+	gwmux := runtime.NewServeMux(runtime.WithIncomingHeaderMatcher(middleware.GatewayHeaderMatcher))
 	err = pb.RegisterExecutionServiceHandlerClient(context.Background(), gwmux, pb.NewExecutionServiceClient(conn))
 	if err != nil {
 		return fmt.Errorf("failed to register gateway: %v", err)
 	}
 
 	corsHandler := middleware.AllowCORS(gwmux)
-	loggedHandler := middleware.LoggingMiddleware(corsHandler)
+	loggedHandler := tracing.HTTPHandler(middleware.LoggingMiddleware(corsHandler), "gateway")
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	s.checker.Mount(mux)
+	mux.Handle("/", loggedHandler)
 
 	gwServer := &http.Server{
 		Addr:    fmt.Sprintf(":%s", s.httpPort),
-		Handler: loggedHandler,
+		Handler: mux,
 	}
 
 	logger.Info().Msgf("Starting Execution Service HTTP server on port %s", s.httpPort)
@@ -8,8 +8,14 @@ import (
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/nedson202/dts-go/internal/job"
+	"github.com/nedson202/dts-go/pkg/auth"
+	"github.com/nedson202/dts-go/pkg/health"
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/metrics"
 	"github.com/nedson202/dts-go/pkg/middleware"
+	"github.com/nedson202/dts-go/pkg/mtls"
+	"github.com/nedson202/dts-go/pkg/rbac"
+	"github.com/nedson202/dts-go/pkg/tracing"
 	pb "github.com/nedson202/dts-go/proto/job/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -17,16 +23,50 @@ import (
 
 type Server struct {
 	pb.UnimplementedJobServiceServer
-	grpcPort string
-	httpPort string
-	service  *job.Service
+	grpcPort      string
+	httpPort      string
+	service       *job.Service
+	authenticator auth.Authenticator
+	authorizer    *rbac.Authorizer
+	credentials   *mtls.Credentials
+	checker       *health.Checker
 }
 
-func NewServer(service *job.Service, grpcPort, httpPort string) *Server {
+// MethodRoles is the role each RPC requires in the namespace it operates on.
+// Every method of the service must have one; methods without are denied.
+var MethodRoles = rbac.Rules{
+	pb.JobService_CreateJob_FullMethodName:         {Role: rbac.Editor},
+	pb.JobService_GetJob_FullMethodName:            {Role: rbac.Viewer},
+	pb.JobService_ListJobs_FullMethodName:          {Role: rbac.Viewer},
+	pb.JobService_UpdateJob_FullMethodName:         {Role: rbac.Editor},
+	pb.JobService_DeleteJob_FullMethodName:         {Role: rbac.Editor},
+	pb.JobService_CancelJob_FullMethodName:         {Role: rbac.Operator},
+	pb.JobService_TriggerJob_FullMethodName:        {Role: rbac.Operator},
+	pb.JobService_CreateNamespace_FullMethodName:   {Role: rbac.Admin, AllNamespaces: true},
+	pb.JobService_GetNamespace_FullMethodName:      {Role: rbac.Viewer},
+	pb.JobService_ListNamespaces_FullMethodName:    {Role: rbac.Viewer, AllNamespaces: true},
+	pb.JobService_UpdateNamespace_FullMethodName:   {Role: rbac.Admin},
+	pb.JobService_DeleteNamespace_FullMethodName:   {Role: rbac.Admin},
+	pb.JobService_GetQuota_FullMethodName:          {Role: rbac.Viewer},
+	pb.JobService_UpdateQuota_FullMethodName:       {Role: rbac.Admin},
+	pb.JobService_CreateRoleBinding_FullMethodName: {Role: rbac.Admin},
+	pb.JobService_ListRoleBindings_FullMethodName:  {Role: rbac.Admin},
+	pb.JobService_DeleteRoleBinding_FullMethodName: {Role: rbac.Admin},
+	pb.JobService_ListAuditEvents_FullMethodName:   {Role: rbac.Viewer},
+}
+
+// NewServer creates the server. A nil authenticator leaves the API open, a
+// nil authorizer lets every authenticated caller use every RPC and nil
+// credentials serve gRPC in plaintext.
+func NewServer(service *job.Service, grpcPort, httpPort string, authenticator auth.Authenticator, authorizer *rbac.Authorizer, credentials *mtls.Credentials, checker *health.Checker) *Server {
 	return &Server{
-		service:  service,
-		grpcPort: grpcPort,
-		httpPort: httpPort,
+		service:       service,
+		grpcPort:      grpcPort,
+		httpPort:      httpPort,
+		authenticator: authenticator,
+		authorizer:    authorizer,
+		credentials:   credentials,
+		checker:       checker,
 	}
 }
 
@@ -55,6 +95,54 @@ func (s *Server) CancelJob(ctx context.Context, req *pb.CancelJobRequest) (*pb.C
 	return s.service.CancelJob(ctx, req)
 }
 
+func (s *Server) TriggerJob(ctx context.Context, req *pb.TriggerJobRequest) (*pb.TriggerJobResponse, error) {
+	return s.service.TriggerJob(ctx, req)
+}
+
+func (s *Server) CreateNamespace(ctx context.Context, req *pb.CreateNamespaceRequest) (*pb.Namespace, error) {
+	return s.service.CreateNamespace(ctx, req)
+}
+
+func (s *Server) GetNamespace(ctx context.Context, req *pb.GetNamespaceRequest) (*pb.Namespace, error) {
+	return s.service.GetNamespace(ctx, req)
+}
+
+func (s *Server) ListNamespaces(ctx context.Context, req *pb.ListNamespacesRequest) (*pb.ListNamespacesResponse, error) {
+	return s.service.ListNamespaces(ctx, req)
+}
+
+func (s *Server) UpdateNamespace(ctx context.Context, req *pb.UpdateNamespaceRequest) (*pb.Namespace, error) {
+	return s.service.UpdateNamespace(ctx, req)
+}
+
+func (s *Server) DeleteNamespace(ctx context.Context, req *pb.DeleteNamespaceRequest) (*pb.DeleteNamespaceResponse, error) {
+	return s.service.DeleteNamespace(ctx, req)
+}
+
+func (s *Server) GetQuota(ctx context.Context, req *pb.GetQuotaRequest) (*pb.GetQuotaResponse, error) {
+	return s.service.GetQuota(ctx, req)
+}
+
+func (s *Server) UpdateQuota(ctx context.Context, req *pb.UpdateQuotaRequest) (*pb.GetQuotaResponse, error) {
+	return s.service.UpdateQuota(ctx, req)
+}
+
+func (s *Server) CreateRoleBinding(ctx context.Context, req *pb.CreateRoleBindingRequest) (*pb.RoleBinding, error) {
+	return s.service.CreateRoleBinding(ctx, req)
+}
+
+func (s *Server) ListRoleBindings(ctx context.Context, req *pb.ListRoleBindingsRequest) (*pb.ListRoleBindingsResponse, error) {
+	return s.service.ListRoleBindings(ctx, req)
+}
+
+func (s *Server) DeleteRoleBinding(ctx context.Context, req *pb.DeleteRoleBindingRequest) (*pb.DeleteRoleBindingResponse, error) {
+	return s.service.DeleteRoleBinding(ctx, req)
+}
+
+func (s *Server) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	return s.service.ListAuditEvents(ctx, req)
+}
+
 // Implement the HTTP service methods
 func (s *Server) Run() error {
 	// Create a listener for gRPC
@@ -63,12 +151,24 @@ func (s *Server) Run() error {
 		return fmt.Errorf("failed to listen: %v", err)
 	}
 
-	// Create a gRPC server with logging interceptor
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(middleware.UnaryServerInterceptor()),
-	)
+	// Create a gRPC server with logging, authentication and authorization interceptors,
+	// serving TLS when credentials are configured
+	grpcServer := grpc.NewServer(append([]grpc.ServerOption{
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			middleware.RequestIDUnaryServerInterceptor(),
+			middleware.UnaryServerInterceptor(),
+			middleware.AuthUnaryServerInterceptor(s.authenticator),
+			middleware.RBACUnaryServerInterceptor(s.authorizer, MethodRoles),
+		),
+		grpc.ChainStreamInterceptor(
+			middleware.AuthStreamServerInterceptor(s.authenticator),
+			middleware.RBACStreamServerInterceptor(s.authorizer, MethodRoles),
+		),
+	}, s.credentials.ServerOptions()...)...)
 	pb.RegisterJobServiceServer(grpcServer, s)
 	reflection.Register(grpcServer)
+	s.checker.RegisterGRPC(context.Background(), grpcServer, pb.JobService_ServiceDesc.ServiceName)
 
 	// Start gRPC server
 	go func() {
@@ -82,24 +182,30 @@ func (s *Server) Run() error {
 	conn, err := grpc.DialContext(
 		context.Background(),
 		fmt.Sprintf("0.0.0.0:%s", s.grpcPort),
-		grpc.WithInsecure(),
+		s.credentials.LoopbackDialOption(),
+		tracing.DialOption(),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to dial server: %v", err)
 	}
 
-	gwmux := runtime.NewServeMux()
+	gwmux := runtime.NewServeMux(runtime.WithIncomingHeaderMatcher(middleware.GatewayHeaderMatcher))
 	err = pb.RegisterJobServiceHandlerClient(context.Background(), gwmux, pb.NewJobServiceClient(conn))
 	if err != nil {
 		return fmt.Errorf("failed to register gateway: %v", err)
 	}
 
 	corsHandler := middleware.AllowCORS(gwmux)
-	loggedHandler := middleware.LoggingMiddleware(corsHandler)
+	loggedHandler := tracing.HTTPHandler(middleware.LoggingMiddleware(corsHandler), "gateway")
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	s.checker.Mount(mux)
+	mux.Handle("/", loggedHandler)
 
 	gwServer := &http.Server{
 		Addr:    fmt.Sprintf(":%s", s.httpPort),
-		Handler: loggedHandler,
+		Handler: mux,
 	}
 
 	logger.Info().Msgf("Starting HTTP server on port %s...", s.httpPort)
@@ -2,32 +2,59 @@ package scheduler
 
 import (
 	"context"
-	"time"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
 
 	"github.com/nedson202/dts-go/internal/scheduler"
-	"github.com/nedson202/dts-go/pkg/database"
+	"github.com/nedson202/dts-go/pkg/config"
+	"github.com/nedson202/dts-go/pkg/health"
 	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/metrics"
+	"github.com/nedson202/dts-go/pkg/mtls"
 	"github.com/nedson202/dts-go/pkg/queue"
+	"github.com/nedson202/dts-go/pkg/store"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
-	cassandraClient *database.CassandraClient
-	kafkaClient     *queue.KafkaClient
-	scheduler       *scheduler.Scheduler
+	db          store.Store
+	producer    queue.Producer
+	scheduler   *scheduler.Scheduler
+	outboxRelay *scheduler.OutboxRelay
+	checker     *health.Checker
+	credentials *mtls.Credentials
+	grpcPort    string
+	httpPort    string
 }
 
-func NewServer(cassandraClient *database.CassandraClient, kafkaClient *queue.KafkaClient, checkInterval time.Duration) (*Server, error) {
-	queueManager := scheduler.NewQueueManager(kafkaClient)
-	scheduler, err := scheduler.NewScheduler(cassandraClient, checkInterval, queueManager)
-	if err != nil {
-		return nil, err
-	}
+// NewServer creates the scheduler service. The gRPC port serves
+// grpc.health.v1 and the HTTP port serves /metrics, /healthz and /readyz. The
+// scheduler loop is added to checker as a liveness check. Nil credentials
+// serve gRPC in plaintext.
+func NewServer(db store.Store, producer queue.Producer, cfg *config.Config, credentials *mtls.Credentials, checker *health.Checker) *Server {
+	queueManager := scheduler.NewQueueManager(producer, cfg)
+	outboxRelay := scheduler.NewOutboxRelay(db, queueManager, cfg.Scheduler.OutboxRelayInterval)
+	scheduler := scheduler.NewScheduler(db, queueManager, cfg)
+	checker.AddLiveness("scheduler", scheduler.CheckTicking)
 
 	return &Server{
-		cassandraClient: cassandraClient,
-		kafkaClient:     kafkaClient,
-		scheduler:       scheduler,
-	}, nil
+		db:          db,
+		producer:    producer,
+		scheduler:   scheduler,
+		outboxRelay: outboxRelay,
+		checker:     checker,
+		credentials: credentials,
+		grpcPort:    cfg.SchedulerServiceGRPCPort,
+		httpPort:    cfg.SchedulerServiceHTTPPort,
+	}
+}
+
+// WatchConfig applies changes to the reloadable scheduler settings of cfg
+// until ctx is done.
+func (s *Server) WatchConfig(ctx context.Context, cfg *config.Config) {
+	config.Watch(ctx, cfg, scheduler.ReloadableSettings, s.scheduler.Reconfigure)
 }
 
 func (s *Server) Run(ctx context.Context) error {
@@ -36,5 +63,40 @@ func (s *Server) Run(ctx context.Context) error {
 	// Start the scheduler
 	go s.scheduler.Start(ctx)
 
+	// Publish the jobs it schedules
+	go s.outboxRelay.Start(ctx)
+
+	// The scheduler has no API of its own; its gRPC server only answers
+	// health checks, over TLS when credentials are configured
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", s.grpcPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer(s.credentials.ServerOptions()...)
+	s.checker.RegisterGRPC(ctx, grpcServer)
+	go func() {
+		logger.Info().Msgf("Starting scheduler gRPC server on port %s", s.grpcPort)
+		if err := grpcServer.Serve(lis); err != nil {
+			logger.Error().Err(err).Msg("Failed to serve gRPC")
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	s.checker.Mount(mux)
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%s", s.httpPort),
+		Handler: mux,
+	}
+	go func() {
+		<-ctx.Done()
+		grpcServer.Stop()
+		httpServer.Close()
+	}()
+
+	logger.Info().Msgf("Starting scheduler HTTP server on port %s", s.httpPort)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,344 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/database"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+// CassandraStore keeps everything in the Cassandra keyspace laid out by the
+// migrations in migrations/.
+type CassandraStore struct {
+	client *database.CassandraClient
+}
+
+// OpenCassandra connects to a Cassandra keyspace.
+func OpenCassandra(hosts []string, keyspace string) (*CassandraStore, error) {
+	client, err := database.NewCassandraClient(hosts, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	return NewCassandraStore(client), nil
+}
+
+func NewCassandraStore(client *database.CassandraClient) *CassandraStore {
+	return &CassandraStore{client: client}
+}
+
+// db returns a client whose queries run under ctx.
+func (s *CassandraStore) db(ctx context.Context) *database.CassandraClient {
+	return s.client.WithContext(ctx)
+}
+
+func (s *CassandraStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx)
+}
+
+func (s *CassandraStore) Close() {
+	s.client.Close()
+}
+
+// notFound translates the not found error of gocql into ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gocql.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+const jobColumns = "id, name, description, cron_expression, status_text, created_at, updated_at, last_run, next_run, metadata, cpu, memory, storage, priority, namespace, trace_context"
+
+// normalizeJob applies the scanned last_run and fills in defaults for columns
+// that may be null on older rows.
+func normalizeJob(j *models.Job, lastRun time.Time) {
+	if !lastRun.IsZero() {
+		j.LastRun = &lastRun
+	}
+	if j.Namespace == "" {
+		j.Namespace = models.DefaultNamespace
+	}
+}
+
+func jobScanDest(j *models.Job, lastRun *time.Time) []interface{} {
+	return []interface{}{&j.ID, &j.Name, &j.Description, &j.CronExpression, &j.Status, &j.CreatedAt, &j.UpdatedAt, lastRun, &j.NextRun, &j.Metadata, &j.Resources.CPU, &j.Resources.Memory, &j.Resources.Storage, &j.Priority, &j.Namespace, &j.TraceContext}
+}
+
+func (s *CassandraStore) CreateJob(ctx context.Context, job *models.Job) error {
+	if err := prepareJob(job); err != nil {
+		return err
+	}
+
+	db := s.db(ctx)
+	batch := db.NewBatch(gocql.LoggedBatch)
+	batch.Query(
+		"INSERT INTO jobs ("+jobColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		job.ID, job.Name, job.Description, job.CronExpression, job.Status, job.CreatedAt, job.UpdatedAt, job.LastRun, job.NextRun, job.Metadata, job.Resources.CPU, job.Resources.Memory, job.Resources.Storage, job.Priority, job.Namespace, job.TraceContext,
+	)
+	batch.Query("INSERT INTO jobs_by_namespace (namespace, job_id) VALUES (?, ?)", job.Namespace, job.ID)
+	indexDueJob(batch, job)
+	return db.Session.ExecuteBatch(batch)
+}
+
+// jobPrioritiesID is the id of the single job_priorities partition.
+const jobPrioritiesID = "global"
+
+// indexDueJob adds the queries that record the job under its priority and
+// next run in jobs_by_next_run to batch.
+func indexDueJob(batch *gocql.Batch, job *models.Job) {
+	batch.Query("INSERT INTO jobs_by_next_run (priority, shard, next_run, job_id) VALUES (?, ?, ?, ?)", job.Priority, job.DueJobShard(), job.NextRun, job.ID)
+	batch.Query("INSERT INTO job_priorities (id, priority) VALUES (?, ?)", jobPrioritiesID, job.Priority)
+}
+
+// unindexDueJob adds the query that removes the row of the job with the given
+// priority and next run from jobs_by_next_run to batch.
+func unindexDueJob(batch *gocql.Batch, job *models.Job, priority int, nextRun time.Time) {
+	batch.Query("DELETE FROM jobs_by_next_run WHERE priority = ? AND shard = ? AND next_run = ? AND job_id = ?", priority, job.DueJobShard(), nextRun, job.ID)
+}
+
+// dueJobIndexOf reads the priority and next run the job is indexed under.
+func (s *CassandraStore) dueJobIndexOf(ctx context.Context, id gocql.UUID) (int, time.Time, error) {
+	var priority int
+	var nextRun time.Time
+	err := s.db(ctx).Query("SELECT priority, next_run FROM jobs WHERE id = ?", id).Scan(&priority, &nextRun)
+	return priority, nextRun, notFound(err)
+}
+
+func (s *CassandraStore) GetJob(ctx context.Context, id gocql.UUID) (*models.Job, error) {
+	var job models.Job
+	var lastRun time.Time
+	err := s.db(ctx).Query(
+		"SELECT "+jobColumns+" FROM jobs WHERE id = ?",
+		id,
+	).Scan(jobScanDest(&job, &lastRun)...)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	normalizeJob(&job, lastRun)
+	return &job, nil
+}
+
+// ListJobs pages through jobs in jobs_by_namespace order.
+func (s *CassandraStore) ListJobs(ctx context.Context, namespace string, pageSize int, lastID gocql.UUID, status string) ([]*models.Job, error) {
+	db := s.db(ctx)
+	var jobs []*models.Job
+	for len(jobs) < pageSize {
+		var ids []gocql.UUID
+		var iter *gocql.Iter
+		if lastID != (gocql.UUID{}) {
+			iter = db.Query("SELECT job_id FROM jobs_by_namespace WHERE namespace = ? AND job_id > ? LIMIT ?", namespace, lastID, pageSize).Iter()
+		} else {
+			iter = db.Query("SELECT job_id FROM jobs_by_namespace WHERE namespace = ? LIMIT ?", namespace, pageSize).Iter()
+		}
+		var id gocql.UUID
+		for iter.Scan(&id) {
+			ids = append(ids, id)
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		for _, id := range ids {
+			lastID = id
+			job, err := s.GetJob(ctx, id)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if status != "" && job.Status != status {
+				continue
+			}
+			jobs = append(jobs, job)
+			if len(jobs) == pageSize {
+				break
+			}
+		}
+		if len(ids) < pageSize {
+			break
+		}
+	}
+
+	return jobs, nil
+}
+
+// UpdateJob moves the job in jobs_by_next_run when its priority changes.
+func (s *CassandraStore) UpdateJob(ctx context.Context, job *models.Job) error {
+	priority, nextRun, err := s.dueJobIndexOf(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+
+	db := s.db(ctx)
+	batch := db.NewBatch(gocql.LoggedBatch)
+	batch.Query(
+		"UPDATE jobs SET name = ?, description = ?, cron_expression = ?, status_text = ?, updated_at = ?, last_run = ?, metadata = ?, cpu = ?, memory = ?, storage = ?, priority = ? WHERE id = ?",
+		job.Name, job.Description, job.CronExpression, job.Status, job.UpdatedAt, job.LastRun, job.Metadata, job.Resources.CPU, job.Resources.Memory, job.Resources.Storage, job.Priority, job.ID,
+	)
+	if priority != job.Priority {
+		unindexDueJob(batch, job, priority, nextRun)
+		indexDueJob(batch, &models.Job{ID: job.ID, Priority: job.Priority, NextRun: nextRun})
+	}
+	return db.Session.ExecuteBatch(batch)
+}
+
+func (s *CassandraStore) SetJobNextRun(ctx context.Context, jobID gocql.UUID, nextRun time.Time) error {
+	priority, previousNextRun, err := s.dueJobIndexOf(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	db := s.db(ctx)
+	job := &models.Job{ID: jobID, Priority: priority, NextRun: nextRun}
+	batch := db.NewBatch(gocql.LoggedBatch)
+	batch.Query("UPDATE jobs SET next_run = ? WHERE id = ?", nextRun, jobID)
+	unindexDueJob(batch, job, priority, previousNextRun)
+	indexDueJob(batch, job)
+	return db.Session.ExecuteBatch(batch)
+}
+
+// AdvanceJobNextRun indexes the new next run before the conditional update,
+// which cannot be batched with other tables, so that a crash in between
+// leaves a stale row rather than a job that is never due again.
+func (s *CassandraStore) AdvanceJobNextRun(ctx context.Context, job *models.Job, previousNextRun time.Time) (bool, error) {
+	db := s.db(ctx)
+	batch := db.NewBatch(gocql.LoggedBatch)
+	indexDueJob(batch, job)
+	if err := db.Session.ExecuteBatch(batch); err != nil {
+		return false, err
+	}
+
+	query := "UPDATE jobs SET status_text = ?, updated_at = ?, next_run = ? WHERE id = ? IF next_run = ?"
+	applied, err := db.Query(query, job.Status, job.UpdatedAt, job.NextRun, job.ID, previousNextRun).MapScanCAS(map[string]interface{}{})
+	if err != nil || !applied {
+		return applied, err
+	}
+
+	batch = db.NewBatch(gocql.LoggedBatch)
+	unindexDueJob(batch, job, job.Priority, previousNextRun)
+	if err := db.Session.ExecuteBatch(batch); err != nil {
+		logger.Ctx(ctx).Warn().Err(err).Msgf("Error removing the previous next run of job %s from jobs_by_next_run", job.ID)
+	}
+	return true, nil
+}
+
+func (s *CassandraStore) DeleteJob(ctx context.Context, job *models.Job) error {
+	db := s.db(ctx)
+	batch := db.NewBatch(gocql.LoggedBatch)
+	batch.Query("DELETE FROM jobs WHERE id = ?", job.ID)
+	batch.Query("DELETE FROM jobs_by_namespace WHERE namespace = ? AND job_id = ?", job.Namespace, job.ID)
+	unindexDueJob(batch, job, job.Priority, job.NextRun)
+	return db.Session.ExecuteBatch(batch)
+}
+
+// dueJobEntry is a row of jobs_by_next_run.
+type dueJobEntry struct {
+	priority int
+	nextRun  time.Time
+	jobID    gocql.UUID
+}
+
+// GetJobsDueForExecution walks jobs_by_next_run from the highest priority
+// down and reads at most limit rows from each shard of a priority, so a tick
+// costs the same however many jobs are overdue. Rows that no longer match
+// their job are replaced by one that does and skipped; the job is picked up
+// on a later tick if it is due.
+func (s *CassandraStore) GetJobsDueForExecution(ctx context.Context, limit int) ([]*models.Job, error) {
+	now := time.Now().Truncate(time.Minute)
+	priorities, err := s.jobPriorities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*models.Job
+	for _, priority := range priorities {
+		if len(jobs) == limit {
+			break
+		}
+		entries, err := s.dueJobEntries(ctx, priority, now, limit-len(jobs))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			job, err := s.GetJob(ctx, entry.jobID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			if err != nil || job.Priority != entry.priority || !job.NextRun.Equal(entry.nextRun) {
+				s.repairDueJobEntry(ctx, entry, job)
+				continue
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	return firstDueJobs(jobs, limit), nil
+}
+
+func (s *CassandraStore) jobPriorities(ctx context.Context) ([]int, error) {
+	var priorities []int
+	iter := s.db(ctx).Query("SELECT priority FROM job_priorities WHERE id = ?", jobPrioritiesID).Iter()
+	var priority int
+	for iter.Scan(&priority) {
+		priorities = append(priorities, priority)
+	}
+	return priorities, iter.Close()
+}
+
+// dueJobEntries returns the limit rows of a priority with the earliest next
+// runs up to now.
+func (s *CassandraStore) dueJobEntries(ctx context.Context, priority int, now time.Time, limit int) ([]dueJobEntry, error) {
+	db := s.db(ctx)
+	var entries []dueJobEntry
+	query := "SELECT next_run, job_id FROM jobs_by_next_run WHERE priority = ? AND shard = ? AND next_run <= ? LIMIT ?"
+	for shard := 0; shard < models.DueJobShards; shard++ {
+		iter := db.Query(query, priority, shard, now, limit).Iter()
+		entry := dueJobEntry{priority: priority}
+		for iter.Scan(&entry.nextRun, &entry.jobID) {
+			entries = append(entries, entry)
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].nextRun.Before(entries[j].nextRun)
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// repairDueJobEntry removes a row of jobs_by_next_run that does not match job,
+// which is nil if it was deleted, and makes sure the job has a row that does.
+func (s *CassandraStore) repairDueJobEntry(ctx context.Context, entry dueJobEntry, job *models.Job) {
+	db := s.db(ctx)
+	batch := db.NewBatch(gocql.LoggedBatch)
+	unindexDueJob(batch, &models.Job{ID: entry.jobID}, entry.priority, entry.nextRun)
+	if job != nil {
+		indexDueJob(batch, job)
+	}
+	if err := db.Session.ExecuteBatch(batch); err != nil {
+		logger.Ctx(ctx).Warn().Err(err).Msgf("Error repairing the jobs_by_next_run row of job %s", entry.jobID)
+	}
+}
+
+// ReleaseDueJob does nothing: GetJobsDueForExecution takes no claims.
+func (s *CassandraStore) ReleaseDueJob(ctx context.Context, jobID gocql.UUID) error {
+	return nil
+}
+
+func (s *CassandraStore) CountNamespaceJobs(ctx context.Context, namespace string) (int, error) {
+	var count int
+	err := s.db(ctx).Query(`SELECT COUNT(*) FROM jobs_by_namespace WHERE namespace = ?`, namespace).Scan(&count)
+	return count, err
+}
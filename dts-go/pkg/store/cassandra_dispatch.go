@@ -0,0 +1,258 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+// runningExecutionTTL bounds how long a dispatch counts against the
+// concurrency quota if its release is never recorded.
+const runningExecutionTTL = 24 * time.Hour
+
+// globalResourcePool is the id of the available_resources row shared by the
+// whole worker fleet.
+const globalResourcePool = "global"
+
+// maxResourceCASAttempts bounds how often a reservation is retried when the
+// pool is modified concurrently.
+const maxResourceCASAttempts = 5
+
+// outboxSentTTL is how long sent entries are kept before they are removed.
+// A sent entry keeps the idempotency key of its run, so an entry for the
+// same occurrence written within this window is turned away rather than
+// published again. It is never shorter than runningExecutionTTL, so the
+// entry outlives the dispatch record of the run.
+const outboxSentTTL = runningExecutionTTL
+
+// AcquireDispatch takes one of the maxConcurrent numbered slots of the
+// namespace with a lightweight transaction before recording the dispatch.
+func (s *CassandraStore) AcquireDispatch(ctx context.Context, namespace, idempotencyKey string, jobID gocql.UUID, dispatchedAt time.Time, maxConcurrent int) (bool, error) {
+	var slot *int
+	if maxConcurrent > 0 {
+		acquired, err := s.acquireConcurrencySlot(ctx, namespace, idempotencyKey, maxConcurrent)
+		if err != nil || acquired < 0 {
+			return false, err
+		}
+		slot = &acquired
+	}
+
+	db := s.db(ctx)
+	query := `INSERT INTO namespace_running_executions (namespace, idempotency_key, job_id, dispatched_at, slot) VALUES (?, ?, ?, ?, ?) USING TTL ?`
+	if err := db.Query(query, namespace, idempotencyKey, jobID, dispatchedAt, slot, int(runningExecutionTTL.Seconds())).Exec(); err != nil {
+		return false, err
+	}
+	query = `UPDATE namespace_hourly_executions SET executions = executions + 1 WHERE namespace = ? AND hour = ?`
+	if err := db.Query(query, namespace, dispatchedAt.UTC().Truncate(time.Hour)).Exec(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// acquireConcurrencySlot returns the slot below maxConcurrent it took for
+// idempotencyKey, or -1 if they are all held. A slot the key already holds,
+// e.g. after a crash before the dispatch was recorded, is returned as is.
+func (s *CassandraStore) acquireConcurrencySlot(ctx context.Context, namespace, idempotencyKey string, maxConcurrent int) (int, error) {
+	db := s.db(ctx)
+	held := make(map[int]bool)
+	iter := db.Query(`SELECT slot, idempotency_key FROM namespace_concurrency_slots WHERE namespace = ?`, namespace).Iter()
+	var slot int
+	var holder string
+	for iter.Scan(&slot, &holder) {
+		if holder == idempotencyKey {
+			iter.Close()
+			return slot, nil
+		}
+		held[slot] = true
+	}
+	if err := iter.Close(); err != nil {
+		return -1, err
+	}
+
+	query := `INSERT INTO namespace_concurrency_slots (namespace, slot, idempotency_key) VALUES (?, ?, ?) IF NOT EXISTS USING TTL ?`
+	for slot := 0; slot < maxConcurrent; slot++ {
+		if held[slot] {
+			continue
+		}
+		applied, err := db.Query(query, namespace, slot, idempotencyKey, int(runningExecutionTTL.Seconds())).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return -1, err
+		}
+		if applied {
+			return slot, nil
+		}
+	}
+	return -1, nil
+}
+
+func (s *CassandraStore) IsDispatchRecorded(ctx context.Context, namespace, idempotencyKey string) (bool, error) {
+	var key string
+	err := s.db(ctx).Query(`SELECT idempotency_key FROM namespace_running_executions WHERE namespace = ? AND idempotency_key = ?`, namespace, idempotencyKey).Scan(&key)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *CassandraStore) ReleaseDispatch(ctx context.Context, namespace, idempotencyKey string) error {
+	db := s.db(ctx)
+	var slot *int
+	err := db.Query(`SELECT slot FROM namespace_running_executions WHERE namespace = ? AND idempotency_key = ?`, namespace, idempotencyKey).Scan(&slot)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if slot != nil {
+		// Only free the slot if it has not expired and been taken by another dispatch
+		query := `DELETE FROM namespace_concurrency_slots WHERE namespace = ? AND slot = ? IF idempotency_key = ?`
+		if _, err := db.Query(query, namespace, *slot, idempotencyKey).MapScanCAS(map[string]interface{}{}); err != nil {
+			return err
+		}
+	}
+	return db.Query(`DELETE FROM namespace_running_executions WHERE namespace = ? AND idempotency_key = ?`, namespace, idempotencyKey).Exec()
+}
+
+func (s *CassandraStore) CountRunningExecutions(ctx context.Context, namespace string) (int, error) {
+	var count int
+	err := s.db(ctx).Query(`SELECT COUNT(*) FROM namespace_running_executions WHERE namespace = ?`, namespace).Scan(&count)
+	return count, err
+}
+
+func (s *CassandraStore) GetHourlyExecutions(ctx context.Context, namespace string, at time.Time) (int, error) {
+	var count int64
+	err := s.db(ctx).Query(`SELECT executions FROM namespace_hourly_executions WHERE namespace = ? AND hour = ?`, namespace, at.UTC().Truncate(time.Hour)).Scan(&count)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return 0, nil
+	}
+	return int(count), err
+}
+
+func (s *CassandraStore) GetResourcePool(ctx context.Context) (*models.ResourcePool, error) {
+	var pool models.ResourcePool
+	var reservations map[string][]int
+	query := `SELECT cpu, memory, storage, reservations FROM available_resources WHERE id = ?`
+	err := s.db(ctx).Query(query, globalResourcePool).Scan(&pool.Available.CPU, &pool.Available.Memory, &pool.Available.Storage, &reservations)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	pool.Reservations = make(map[string]models.Resources, len(reservations))
+	for id, r := range reservations {
+		if len(r) == 3 {
+			pool.Reservations[id] = models.Resources{CPU: r[0], Memory: r[1], Storage: r[2]}
+		}
+	}
+	return &pool, nil
+}
+
+// ReserveResources updates the pool row with a compare-and-set on its
+// remaining capacity, retrying a few times under contention.
+func (s *CassandraStore) ReserveResources(ctx context.Context, reservationID string, req models.Resources) (bool, error) {
+	for attempt := 0; attempt < maxResourceCASAttempts; attempt++ {
+		pool, err := s.GetResourcePool(ctx)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := pool.Reservations[reservationID]; ok {
+			return true, nil
+		}
+		if !req.Fits(pool.Available) {
+			return false, nil
+		}
+
+		remaining := pool.Available.Sub(req)
+		query := `UPDATE available_resources SET cpu = ?, memory = ?, storage = ?, reservations[?] = ? WHERE id = ? IF cpu = ? AND memory = ? AND storage = ?`
+		applied, err := s.db(ctx).Query(query,
+			remaining.CPU, remaining.Memory, remaining.Storage, reservationID, []int{req.CPU, req.Memory, req.Storage}, globalResourcePool,
+			pool.Available.CPU, pool.Available.Memory, pool.Available.Storage,
+		).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return false, err
+		}
+		if applied {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("resource pool is under contention, gave up after %d attempts", maxResourceCASAttempts)
+}
+
+func (s *CassandraStore) ReleaseResources(ctx context.Context, reservationID string) error {
+	for attempt := 0; attempt < maxResourceCASAttempts; attempt++ {
+		pool, err := s.GetResourcePool(ctx)
+		if err != nil {
+			return err
+		}
+		reserved, ok := pool.Reservations[reservationID]
+		if !ok {
+			return nil
+		}
+
+		restored := pool.Available.Add(reserved)
+		query := `UPDATE available_resources SET cpu = ?, memory = ?, storage = ?, reservations = reservations - ? WHERE id = ? IF cpu = ? AND memory = ? AND storage = ?`
+		applied, err := s.db(ctx).Query(query,
+			restored.CPU, restored.Memory, restored.Storage, []string{reservationID}, globalResourcePool,
+			pool.Available.CPU, pool.Available.Memory, pool.Available.Storage,
+		).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+		if applied {
+			return nil
+		}
+	}
+	return fmt.Errorf("resource pool is under contention, gave up after %d attempts", maxResourceCASAttempts)
+}
+
+// CreateOutboxEntry inserts the entry with IF NOT EXISTS.
+func (s *CassandraStore) CreateOutboxEntry(ctx context.Context, entry *models.OutboxEntry) (bool, error) {
+	query := `INSERT INTO outbox (shard, id, topic, message_key, payload, created_at, claimed_until, trace_context) VALUES (?, ?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS`
+	return s.db(ctx).Query(query, entry.Shard, entry.ID, entry.Topic, entry.Key, entry.Payload, entry.CreatedAt, time.Unix(0, 0), entry.TraceContext).MapScanCAS(map[string]interface{}{})
+}
+
+func (s *CassandraStore) ListPendingOutboxEntries(ctx context.Context, shard int, limit int) ([]*models.OutboxEntry, error) {
+	var entries []*models.OutboxEntry
+	query := `SELECT shard, id, topic, message_key, payload, created_at, claimed_until, sent_at, trace_context FROM outbox WHERE shard = ?`
+	iter := s.db(ctx).Query(query, shard).Iter()
+	for len(entries) < limit {
+		var entry models.OutboxEntry
+		var sentAt time.Time
+		if !iter.Scan(&entry.Shard, &entry.ID, &entry.Topic, &entry.Key, &entry.Payload, &entry.CreatedAt, &entry.ClaimedUntil, &sentAt, &entry.TraceContext) {
+			break
+		}
+		if !sentAt.IsZero() {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *CassandraStore) ClaimOutboxEntry(ctx context.Context, entry *models.OutboxEntry, now, leaseUntil time.Time) (bool, error) {
+	query := `UPDATE outbox SET claimed_until = ? WHERE shard = ? AND id = ? IF claimed_until < ?`
+	applied, err := s.db(ctx).Query(query, leaseUntil, entry.Shard, entry.ID, now).MapScanCAS(map[string]interface{}{})
+	if err != nil || !applied {
+		return applied, err
+	}
+	entry.ClaimedUntil = leaseUntil
+	return true, nil
+}
+
+// MarkOutboxEntrySent rewrites the whole row with a TTL so sent entries age
+// out of the outbox.
+func (s *CassandraStore) MarkOutboxEntrySent(ctx context.Context, entry *models.OutboxEntry, sentAt time.Time) error {
+	query := `INSERT INTO outbox (shard, id, topic, message_key, payload, created_at, claimed_until, sent_at, trace_context) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) USING TTL ?`
+	err := s.db(ctx).Query(query, entry.Shard, entry.ID, entry.Topic, entry.Key, entry.Payload, entry.CreatedAt, entry.ClaimedUntil, sentAt, entry.TraceContext, int(outboxSentTTL.Seconds())).Exec()
+	if err != nil {
+		return err
+	}
+	entry.SentAt = &sentAt
+	return nil
+}
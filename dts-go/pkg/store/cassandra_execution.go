@@ -0,0 +1,370 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+// executionDayLayout formats the UTC day bucket of the query tables.
+const executionDayLayout = "2006-01-02"
+
+// executionLogChunkSize is the number of log lines stored per execution_logs
+// partition.
+const executionLogChunkSize = 1000
+
+const executionColumns = `id, job_id, status, start_time, end_time, result, error, worker_id, attempts, scheduled_time, trigger_source, namespace`
+
+// executionDay is the query table bucket of an execution, derived from its time-based ID.
+func executionDay(id gocql.UUID) string {
+	return id.Time().UTC().Format(executionDayLayout)
+}
+
+func executionScanDest(e *models.Execution, endTime *time.Time) []interface{} {
+	return []interface{}{&e.ID, &e.JobID, &e.Status, &e.StartTime, endTime, &e.Result, &e.Error, &e.WorkerID, &e.AttemptCount, &e.ScheduledTime, &e.TriggerSource, &e.Namespace}
+}
+
+// normalizeExecution applies the scanned end_time and fills in the namespace
+// of rows written before executions had one.
+func normalizeExecution(e *models.Execution, endTime time.Time) {
+	if !endTime.IsZero() {
+		e.EndTime = &endTime
+	}
+	if e.Namespace == "" {
+		e.Namespace = models.DefaultNamespace
+	}
+}
+
+func scanExecution(query *gocql.Query) (*models.Execution, error) {
+	var execution models.Execution
+	var endTime time.Time
+	if err := query.Scan(executionScanDest(&execution, &endTime)...); err != nil {
+		return nil, notFound(err)
+	}
+	normalizeExecution(&execution, endTime)
+	return &execution, nil
+}
+
+func (s *CassandraStore) CreateExecution(ctx context.Context, execution *models.Execution) error {
+	if execution.Namespace == "" {
+		execution.Namespace = models.DefaultNamespace
+	}
+	day := executionDay(execution.ID)
+	db := s.db(ctx)
+	batch := db.NewBatch(gocql.LoggedBatch)
+	batch.Query(`INSERT INTO job_executions (`+executionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		execution.ID, execution.JobID, execution.Status, execution.StartTime, execution.EndTime, execution.Result, execution.Error, execution.WorkerID, execution.AttemptCount, execution.ScheduledTime, execution.TriggerSource, execution.Namespace)
+	batch.Query(`INSERT INTO executions_by_namespace_day (namespace, day, id, job_id) VALUES (?, ?, ?, ?)`, execution.Namespace, day, execution.ID, execution.JobID)
+	batch.Query(`INSERT INTO executions_by_namespace_status (namespace, status, day, id, job_id) VALUES (?, ?, ?, ?, ?)`, execution.Namespace, execution.Status, day, execution.ID, execution.JobID)
+	return db.Session.ExecuteBatch(batch)
+}
+
+func (s *CassandraStore) GetJobExecution(ctx context.Context, jobID, id gocql.UUID) (*models.Execution, error) {
+	query := `SELECT ` + executionColumns + ` FROM job_executions WHERE job_id = ? AND id = ?`
+	return scanExecution(s.db(ctx).Query(query, jobID, id))
+}
+
+// GetExecution uses the day bucket encoded in the ID to find the job the
+// execution belongs to.
+func (s *CassandraStore) GetExecution(ctx context.Context, namespace string, id gocql.UUID) (*models.Execution, error) {
+	var jobID gocql.UUID
+	query := `SELECT job_id FROM executions_by_namespace_day WHERE namespace = ? AND day = ? AND id = ?`
+	if err := s.db(ctx).Query(query, namespace, executionDay(id), id).Scan(&jobID); err != nil {
+		return nil, notFound(err)
+	}
+	return s.GetJobExecution(ctx, jobID, id)
+}
+
+type executionPageToken struct {
+	Day   string `json:"d,omitempty"`
+	State []byte `json:"s,omitempty"`
+}
+
+func encodePageToken(token executionPageToken) string {
+	if token.Day == "" && len(token.State) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(pageToken string) (executionPageToken, error) {
+	var token executionPageToken
+	if pageToken == "" {
+		return token, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return token, ErrInvalidPageToken
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return token, ErrInvalidPageToken
+	}
+	if token.Day != "" {
+		if _, err := time.Parse(executionDayLayout, token.Day); err != nil {
+			return token, ErrInvalidPageToken
+		}
+	}
+	return token, nil
+}
+
+// ListExecutions reads the executions of a single job from that job's
+// partition; otherwise the namespace's per-day query tables are walked
+// backwards from today.
+func (s *CassandraStore) ListExecutions(ctx context.Context, namespace string, pageSize int, pageToken string, jobID gocql.UUID, status string, lookbackDays int) ([]*models.Execution, string, error) {
+	token, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if jobID != (gocql.UUID{}) {
+		return s.listJobExecutions(ctx, pageSize, token, jobID, status)
+	}
+
+	var query string
+	var args func(day string) []interface{}
+	if status != "" {
+		query = `SELECT job_id, id FROM executions_by_namespace_status WHERE namespace = ? AND status = ? AND day = ?`
+		args = func(day string) []interface{} { return []interface{}{namespace, status, day} }
+	} else {
+		query = `SELECT job_id, id FROM executions_by_namespace_day WHERE namespace = ? AND day = ?`
+		args = func(day string) []interface{} { return []interface{}{namespace, day} }
+	}
+
+	db := s.db(ctx)
+	return walkExecutionDays(time.Now(), token, pageSize, lookbackDays, func(day string, limit int, state []byte) ([]*models.Execution, []byte, error) {
+		iter := db.Query(query, args(day)...).PageSize(limit).PageState(state).Iter()
+		nextState := iter.PageState()
+
+		var executions []*models.Execution
+		var rowJobID, id gocql.UUID
+		for iter.Scan(&rowJobID, &id) {
+			execution, err := s.GetJobExecution(ctx, rowJobID, id)
+			if err != nil {
+				iter.Close()
+				return nil, nil, err
+			}
+			executions = append(executions, execution)
+		}
+		if err := iter.Close(); err != nil {
+			return nil, nil, err
+		}
+		return executions, nextState, nil
+	})
+}
+
+// walkExecutionDays fills a page from the day buckets of the last
+// lookbackDays before now, newest first, starting where token left off.
+// readDay reads at most limit executions of a day from the paging state
+// state, and returns the state of the rest of the day, if any.
+func walkExecutionDays(now time.Time, token executionPageToken, pageSize, lookbackDays int, readDay func(day string, limit int, state []byte) ([]*models.Execution, []byte, error)) ([]*models.Execution, string, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	day := today
+	if token.Day != "" {
+		day, _ = time.Parse(executionDayLayout, token.Day)
+	}
+	oldestDay := today.AddDate(0, 0, -lookbackDays)
+
+	var executions []*models.Execution
+	state := token.State
+	for ; !day.Before(oldestDay); day, state = day.AddDate(0, 0, -1), nil {
+		dayKey := day.Format(executionDayLayout)
+		dayExecutions, nextState, err := readDay(dayKey, pageSize-len(executions), state)
+		if err != nil {
+			return nil, "", err
+		}
+		executions = append(executions, dayExecutions...)
+
+		if len(nextState) > 0 {
+			return executions, encodePageToken(executionPageToken{Day: dayKey, State: nextState}), nil
+		}
+		if len(executions) >= pageSize {
+			previousDay := day.AddDate(0, 0, -1)
+			if previousDay.Before(oldestDay) {
+				return executions, "", nil
+			}
+			return executions, encodePageToken(executionPageToken{Day: previousDay.Format(executionDayLayout)}), nil
+		}
+	}
+
+	return executions, "", nil
+}
+
+func (s *CassandraStore) listJobExecutions(ctx context.Context, pageSize int, token executionPageToken, jobID gocql.UUID, status string) ([]*models.Execution, string, error) {
+	query := `SELECT ` + executionColumns + ` FROM job_executions WHERE job_id = ? ORDER BY id DESC`
+	args := []interface{}{jobID}
+	if status != "" {
+		// Filtering is bounded by the job's partition
+		query = `SELECT ` + executionColumns + ` FROM job_executions WHERE job_id = ? AND status = ? ORDER BY id DESC ALLOW FILTERING`
+		args = append(args, status)
+	}
+
+	iter := s.db(ctx).Query(query, args...).PageSize(pageSize).PageState(token.State).Iter()
+	nextState := iter.PageState()
+
+	var executions []*models.Execution
+	for {
+		var execution models.Execution
+		var endTime time.Time
+		if !iter.Scan(executionScanDest(&execution, &endTime)...) {
+			break
+		}
+		normalizeExecution(&execution, endTime)
+		executions = append(executions, &execution)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return executions, encodePageToken(executionPageToken{State: nextState}), nil
+}
+
+// UpdateExecution saves an execution and, if its status changed, moves it
+// between the executions_by_namespace_status partitions.
+func (s *CassandraStore) UpdateExecution(ctx context.Context, execution *models.Execution) error {
+	db := s.db(ctx)
+	var previousStatus string
+	err := db.Query(`SELECT status FROM job_executions WHERE job_id = ? AND id = ?`, execution.JobID, execution.ID).Scan(&previousStatus)
+	if err != nil && !errors.Is(err, gocql.ErrNotFound) {
+		return err
+	}
+
+	batch := db.NewBatch(gocql.LoggedBatch)
+	batch.Query(`UPDATE job_executions SET status = ?, end_time = ?, result = ?, error = ?, worker_id = ?, attempts = ? WHERE id = ? AND job_id = ?`,
+		execution.Status, execution.EndTime, execution.Result, execution.Error, execution.WorkerID, execution.AttemptCount, execution.ID, execution.JobID)
+	if previousStatus != execution.Status {
+		day := executionDay(execution.ID)
+		batch.Query(`DELETE FROM executions_by_namespace_status WHERE namespace = ? AND status = ? AND day = ? AND id = ?`, execution.Namespace, previousStatus, day, execution.ID)
+		batch.Query(`INSERT INTO executions_by_namespace_status (namespace, status, day, id, job_id) VALUES (?, ?, ?, ?, ?)`, execution.Namespace, execution.Status, day, execution.ID, execution.JobID)
+	}
+	return db.Session.ExecuteBatch(batch)
+}
+
+func (s *CassandraStore) CreateExecutionAttempt(ctx context.Context, attempt *models.ExecutionAttempt) error {
+	query := `INSERT INTO execution_attempts (execution_id, attempt, job_id, status, start_time, end_time, worker_id, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	return s.db(ctx).Query(query, attempt.ExecutionID, attempt.Attempt, attempt.JobID, attempt.Status, attempt.StartTime, attempt.EndTime, attempt.WorkerID, attempt.Error).Exec()
+}
+
+func (s *CassandraStore) UpdateExecutionAttempt(ctx context.Context, attempt *models.ExecutionAttempt) error {
+	query := `UPDATE execution_attempts SET status = ?, end_time = ?, error = ? WHERE execution_id = ? AND attempt = ?`
+	return s.db(ctx).Query(query, attempt.Status, attempt.EndTime, attempt.Error, attempt.ExecutionID, attempt.Attempt).Exec()
+}
+
+func (s *CassandraStore) ListExecutionAttempts(ctx context.Context, executionID gocql.UUID) ([]*models.ExecutionAttempt, error) {
+	var attempts []*models.ExecutionAttempt
+	query := `SELECT execution_id, attempt, job_id, status, start_time, end_time, worker_id, error FROM execution_attempts WHERE execution_id = ?`
+	iter := s.db(ctx).Query(query, executionID).Iter()
+	for {
+		var attempt models.ExecutionAttempt
+		var endTime time.Time
+		if !iter.Scan(&attempt.ExecutionID, &attempt.Attempt, &attempt.JobID, &attempt.Status, &attempt.StartTime, &endTime, &attempt.WorkerID, &attempt.Error) {
+			break
+		}
+		if !endTime.IsZero() {
+			attempt.EndTime = &endTime
+		}
+		attempts = append(attempts, &attempt)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func logChunk(seq int64) int {
+	return int(seq / executionLogChunkSize)
+}
+
+func (s *CassandraStore) CreateExecutionLogLine(ctx context.Context, line *models.ExecutionLogLine) error {
+	query := `INSERT INTO execution_logs (execution_id, chunk, seq, stream, timestamp, line) VALUES (?, ?, ?, ?, ?, ?)`
+	return s.db(ctx).Query(query, line.ExecutionID, logChunk(line.Seq), line.Seq, line.Stream, line.Timestamp, line.Line).Exec()
+}
+
+func (s *CassandraStore) ListExecutionLogLines(ctx context.Context, executionID gocql.UUID, afterSeq int64, limit int) ([]*models.ExecutionLogLine, error) {
+	db := s.db(ctx)
+	var lines []*models.ExecutionLogLine
+	query := `SELECT execution_id, seq, stream, timestamp, line FROM execution_logs WHERE execution_id = ? AND chunk = ? AND seq > ? LIMIT ?`
+
+	for chunk := logChunk(afterSeq + 1); len(lines) < limit; chunk++ {
+		iter := db.Query(query, executionID, chunk, afterSeq, limit-len(lines)).Iter()
+		found := 0
+		for {
+			var line models.ExecutionLogLine
+			if !iter.Scan(&line.ExecutionID, &line.Seq, &line.Stream, &line.Timestamp, &line.Line) {
+				break
+			}
+			lines = append(lines, &line)
+			afterSeq = line.Seq
+			found++
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+
+		// Only move on to the next chunk once this one is full
+		if found == 0 || logChunk(afterSeq+1) == chunk {
+			break
+		}
+	}
+
+	return lines, nil
+}
+
+func (s *CassandraStore) GetLastExecutionLogSeq(ctx context.Context, executionID gocql.UUID) (int64, error) {
+	db := s.db(ctx)
+	var lastSeq int64
+	query := `SELECT seq FROM execution_logs WHERE execution_id = ? AND chunk = ? ORDER BY seq DESC LIMIT 1`
+	for chunk := 0; ; chunk++ {
+		var seq int64
+		err := db.Query(query, executionID, chunk).Scan(&seq)
+		if errors.Is(err, gocql.ErrNotFound) {
+			return lastSeq, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		lastSeq = seq
+	}
+}
+
+func (s *CassandraStore) CreateHeartbeat(ctx context.Context, heartbeat *models.ExecutionHeartbeat) error {
+	query := `INSERT INTO execution_heartbeats (execution_id, job_id, worker_id, idempotency_key, retry_count, start_time, heartbeat_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	return s.db(ctx).Query(query, heartbeat.ExecutionID, heartbeat.JobID, heartbeat.WorkerID, heartbeat.IdempotencyKey, heartbeat.RetryCount, heartbeat.StartTime, heartbeat.HeartbeatAt).Exec()
+}
+
+func (s *CassandraStore) TouchHeartbeat(ctx context.Context, executionID gocql.UUID, heartbeatAt time.Time) (bool, error) {
+	query := `UPDATE execution_heartbeats SET heartbeat_at = ? WHERE execution_id = ? IF EXISTS`
+	return s.db(ctx).Query(query, heartbeatAt, executionID).MapScanCAS(map[string]interface{}{})
+}
+
+func (s *CassandraStore) DeleteHeartbeat(ctx context.Context, executionID gocql.UUID) error {
+	query := `DELETE FROM execution_heartbeats WHERE execution_id = ?`
+	return s.db(ctx).Query(query, executionID).Exec()
+}
+
+func (s *CassandraStore) ListHeartbeats(ctx context.Context) ([]*models.ExecutionHeartbeat, error) {
+	var heartbeats []*models.ExecutionHeartbeat
+	query := `SELECT execution_id, job_id, worker_id, idempotency_key, retry_count, start_time, heartbeat_at FROM execution_heartbeats`
+	iter := s.db(ctx).Query(query).Iter()
+	for {
+		var heartbeat models.ExecutionHeartbeat
+		if !iter.Scan(&heartbeat.ExecutionID, &heartbeat.JobID, &heartbeat.WorkerID, &heartbeat.IdempotencyKey, &heartbeat.RetryCount, &heartbeat.StartTime, &heartbeat.HeartbeatAt) {
+			break
+		}
+		heartbeats = append(heartbeats, &heartbeat)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return heartbeats, nil
+}
+
+func (s *CassandraStore) ClaimStaleHeartbeat(ctx context.Context, heartbeat *models.ExecutionHeartbeat) (bool, error) {
+	query := `DELETE FROM execution_heartbeats WHERE execution_id = ? IF heartbeat_at = ?`
+	return s.db(ctx).Query(query, heartbeat.ExecutionID, heartbeat.HeartbeatAt).MapScanCAS(map[string]interface{}{})
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+func TestExecutionPageTokenRoundTrip(t *testing.T) {
+	if token := encodePageToken(executionPageToken{}); token != "" {
+		t.Fatalf("got %q for the end of the listing, want no token", token)
+	}
+	for _, want := range []executionPageToken{
+		{Day: "2026-02-28", State: []byte{0x00, 0x04, 0xff}},
+		{Day: "2026-02-28"},
+		{State: []byte("job partition state")},
+	} {
+		got, err := decodePageToken(encodePageToken(want))
+		if err != nil {
+			t.Fatalf("decoding the token of %+v: %v", want, err)
+		}
+		if got.Day != want.Day || !slices.Equal(got.State, want.State) {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestDecodePageTokenRejectsMalformedTokens(t *testing.T) {
+	encoded := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for name, token := range map[string]string{
+		"not base64":    "not a token!",
+		"not JSON":      encoded("day=2026-02-28"),
+		"wrong types":   encoded(`{"d":20260228}`),
+		"malformed day": encoded(`{"d":"yesterday"}`),
+	} {
+		if _, err := decodePageToken(token); !errors.Is(err, ErrInvalidPageToken) {
+			t.Errorf("%s: got %v, want ErrInvalidPageToken", name, err)
+		}
+	}
+}
+
+// fakeDays serves the executions of each day bucket the way Cassandra pages
+// a partition, with the offset of the next row as the paging state.
+type fakeDays map[string][]string
+
+func (d fakeDays) read(day string, limit int, state []byte) ([]*models.Execution, []byte, error) {
+	offset := 0
+	if len(state) > 0 {
+		var err error
+		if offset, err = strconv.Atoi(string(state)); err != nil {
+			return nil, nil, err
+		}
+	}
+	rows := d[day][offset:]
+	var nextState []byte
+	if len(rows) > limit {
+		rows = rows[:limit]
+		nextState = []byte(strconv.Itoa(offset + limit))
+	}
+	executions := make([]*models.Execution, len(rows))
+	for i, row := range rows {
+		executions[i] = &models.Execution{Result: row}
+	}
+	return executions, nextState, nil
+}
+
+func TestWalkExecutionDaysAcrossDayBoundary(t *testing.T) {
+	days := fakeDays{
+		"2026-03-01": {"mar1-a", "mar1-b", "mar1-c"},
+		"2026-02-28": {"feb28-a", "feb28-b", "feb28-c", "feb28-d"},
+		"2026-02-26": {"feb26-a", "feb26-b"},
+		// Before the lookback
+		"2026-02-25": {"feb25-a"},
+	}
+	want := []string{"mar1-a", "mar1-b", "mar1-c", "feb28-a", "feb28-b", "feb28-c", "feb28-d", "feb26-a", "feb26-b"}
+	// Half an hour into March 1st in UTC, still February 28th west of it
+	now := time.Date(2026, 2, 28, 19, 30, 0, 0, time.FixedZone("UTC-5", -5*60*60))
+
+	for pageSize := 1; pageSize <= len(want)+1; pageSize++ {
+		t.Run(fmt.Sprintf("page size %d", pageSize), func(t *testing.T) {
+			var got []string
+			pageToken := ""
+			for pages := 0; ; pages++ {
+				if pages > len(want) {
+					t.Fatalf("still listing after %d pages, got %v", pages, got)
+				}
+				token, err := decodePageToken(pageToken)
+				if err != nil {
+					t.Fatal(err)
+				}
+				executions, next, err := walkExecutionDays(now, token, pageSize, 3, days.read)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(executions) > pageSize {
+					t.Fatalf("got a page of %d executions, want at most %d", len(executions), pageSize)
+				}
+				for _, execution := range executions {
+					got = append(got, execution.Result)
+				}
+				if next == "" {
+					break
+				}
+				pageToken = next
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+
+	// A page that ends with a day continues from the start of the day before
+	_, next, err := walkExecutionDays(now, executionPageToken{}, 3, 3, days.read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := decodePageToken(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Day != "2026-02-28" || len(token.State) != 0 {
+		t.Fatalf("got token %+v, want the start of 2026-02-28", token)
+	}
+}
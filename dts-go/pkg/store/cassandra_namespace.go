@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+const auditDayLayout = "2006-01-02"
+
+func (s *CassandraStore) CreateNamespace(ctx context.Context, namespace *models.Namespace) (bool, error) {
+	query := `INSERT INTO namespaces (name, description, created_at, updated_at) VALUES (?, ?, ?, ?) IF NOT EXISTS`
+	return s.db(ctx).Query(query, namespace.Name, namespace.Description, namespace.CreatedAt, namespace.UpdatedAt).MapScanCAS(map[string]interface{}{})
+}
+
+func (s *CassandraStore) GetNamespace(ctx context.Context, name string) (*models.Namespace, error) {
+	var namespace models.Namespace
+	query := `SELECT name, description, created_at, updated_at FROM namespaces WHERE name = ?`
+	if err := s.db(ctx).Query(query, name).Scan(&namespace.Name, &namespace.Description, &namespace.CreatedAt, &namespace.UpdatedAt); err != nil {
+		return nil, notFound(err)
+	}
+	return &namespace, nil
+}
+
+func (s *CassandraStore) ListNamespaces(ctx context.Context) ([]*models.Namespace, error) {
+	var namespaces []*models.Namespace
+	iter := s.db(ctx).Query(`SELECT name, description, created_at, updated_at FROM namespaces`).Iter()
+	for {
+		var namespace models.Namespace
+		if !iter.Scan(&namespace.Name, &namespace.Description, &namespace.CreatedAt, &namespace.UpdatedAt) {
+			break
+		}
+		namespaces = append(namespaces, &namespace)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+func (s *CassandraStore) UpdateNamespace(ctx context.Context, namespace *models.Namespace) (bool, error) {
+	query := `UPDATE namespaces SET description = ?, updated_at = ? WHERE name = ? IF EXISTS`
+	return s.db(ctx).Query(query, namespace.Description, namespace.UpdatedAt, namespace.Name).MapScanCAS(map[string]interface{}{})
+}
+
+func (s *CassandraStore) DeleteNamespace(ctx context.Context, name string) error {
+	db := s.db(ctx)
+	if err := db.Query(`DELETE FROM namespaces WHERE name = ?`, name).Exec(); err != nil {
+		return err
+	}
+	if err := db.Query(`DELETE FROM role_bindings WHERE namespace = ?`, name).Exec(); err != nil {
+		return err
+	}
+	return db.Query(`DELETE FROM namespace_quotas WHERE namespace = ?`, name).Exec()
+}
+
+func (s *CassandraStore) GetQuota(ctx context.Context, namespace string, defaults models.Quota) (models.Quota, error) {
+	var quota models.Quota
+	var minIntervalSeconds int
+	query := `SELECT max_jobs, min_schedule_interval_seconds, max_concurrent_executions, max_executions_per_hour FROM namespace_quotas WHERE namespace = ?`
+	err := s.db(ctx).Query(query, namespace).Scan(&quota.MaxJobs, &minIntervalSeconds, &quota.MaxConcurrentExecutions, &quota.MaxExecutionsPerHour)
+	if err != nil && !errors.Is(err, gocql.ErrNotFound) {
+		return models.Quota{}, err
+	}
+	quota.MinScheduleInterval = time.Duration(minIntervalSeconds) * time.Second
+	return quota.WithDefaults(defaults), nil
+}
+
+func (s *CassandraStore) SetQuota(ctx context.Context, namespace string, quota models.Quota) error {
+	query := `INSERT INTO namespace_quotas (namespace, max_jobs, min_schedule_interval_seconds, max_concurrent_executions, max_executions_per_hour) VALUES (?, ?, ?, ?, ?)`
+	return s.db(ctx).Query(query, namespace, quota.MaxJobs, int(quota.MinScheduleInterval/time.Second), quota.MaxConcurrentExecutions, quota.MaxExecutionsPerHour).Exec()
+}
+
+func (s *CassandraStore) SetRoleBinding(ctx context.Context, binding *models.RoleBinding) error {
+	query := `INSERT INTO role_bindings (namespace, subject, role, created_at, created_by) VALUES (?, ?, ?, ?, ?)`
+	return s.db(ctx).Query(query, binding.Namespace, binding.Subject, binding.Role, binding.CreatedAt, binding.CreatedBy).Exec()
+}
+
+func (s *CassandraStore) GetRoleBinding(ctx context.Context, namespace, subject string) (*models.RoleBinding, error) {
+	binding := models.RoleBinding{Namespace: namespace, Subject: subject}
+	query := `SELECT role, created_at, created_by FROM role_bindings WHERE namespace = ? AND subject = ?`
+	if err := s.db(ctx).Query(query, namespace, subject).Scan(&binding.Role, &binding.CreatedAt, &binding.CreatedBy); err != nil {
+		return nil, notFound(err)
+	}
+	return &binding, nil
+}
+
+func (s *CassandraStore) ListRoleBindings(ctx context.Context, namespace string) ([]*models.RoleBinding, error) {
+	var bindings []*models.RoleBinding
+	query := `SELECT subject, role, created_at, created_by FROM role_bindings WHERE namespace = ?`
+	iter := s.db(ctx).Query(query, namespace).Iter()
+	for {
+		binding := models.RoleBinding{Namespace: namespace}
+		if !iter.Scan(&binding.Subject, &binding.Role, &binding.CreatedAt, &binding.CreatedBy) {
+			break
+		}
+		bindings = append(bindings, &binding)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+func (s *CassandraStore) DeleteRoleBinding(ctx context.Context, namespace, subject string) (bool, error) {
+	query := `DELETE FROM role_bindings WHERE namespace = ? AND subject = ? IF EXISTS`
+	return s.db(ctx).Query(query, namespace, subject).MapScanCAS(map[string]interface{}{})
+}
+
+// CreateAuditEvent appends an event to the per-namespace and per-job logs.
+func (s *CassandraStore) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	changes, err := json.Marshal(event.Changes)
+	if err != nil {
+		return err
+	}
+	day := event.ID.Time().UTC().Format(auditDayLayout)
+
+	db := s.db(ctx)
+	batch := db.NewBatch(gocql.LoggedBatch)
+	batch.Query(`INSERT INTO audit_events (namespace, day, id, job_id, action, actor, request_id, changes) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.Namespace, day, event.ID, event.JobID, event.Action, event.Actor, event.RequestID, string(changes))
+	batch.Query(`INSERT INTO audit_events_by_job (job_id, id, namespace, action, actor, request_id, changes) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		event.JobID, event.ID, event.Namespace, event.Action, event.Actor, event.RequestID, string(changes))
+	return db.Session.ExecuteBatch(batch)
+}
+
+func (s *CassandraStore) ListAuditEvents(ctx context.Context, filter models.AuditEventFilter, pageSize int, before gocql.UUID) ([]*models.AuditEvent, gocql.UUID, error) {
+	var upper interface{} = filter.End
+	upperOp := `id <= maxTimeuuid(?)`
+	end := filter.End
+	if before != (gocql.UUID{}) {
+		upper, upperOp = before, `id < ?`
+		end = before.Time()
+	}
+
+	db := s.db(ctx)
+	var events []*models.AuditEvent
+	collect := func(query string, args ...interface{}) (bool, error) {
+		iter := db.Query(query, args...).Iter()
+		for {
+			event := models.AuditEvent{}
+			var changes string
+			if !iter.Scan(&event.ID, &event.Namespace, &event.JobID, &event.Action, &event.Actor, &event.RequestID, &changes) {
+				break
+			}
+			if event.Namespace != filter.Namespace || (filter.Actor != "" && event.Actor != filter.Actor) {
+				continue
+			}
+			if err := json.Unmarshal([]byte(changes), &event.Changes); err != nil {
+				iter.Close()
+				return false, err
+			}
+			events = append(events, &event)
+			if len(events) == pageSize {
+				iter.Close()
+				return true, nil
+			}
+		}
+		return false, iter.Close()
+	}
+
+	if filter.JobID != (gocql.UUID{}) {
+		query := `SELECT id, namespace, job_id, action, actor, request_id, changes FROM audit_events_by_job WHERE job_id = ? AND id >= minTimeuuid(?) AND ` + upperOp
+		full, err := collect(query, filter.JobID, filter.Start, upper)
+		if err != nil || !full {
+			return events, gocql.UUID{}, err
+		}
+		return events, events[len(events)-1].ID, nil
+	}
+
+	query := `SELECT id, namespace, job_id, action, actor, request_id, changes FROM audit_events WHERE namespace = ? AND day = ? AND id >= minTimeuuid(?) AND ` + upperOp
+	oldestDay := filter.Start.UTC().Truncate(24 * time.Hour)
+	for day := end.UTC().Truncate(24 * time.Hour); !day.Before(oldestDay); day = day.AddDate(0, 0, -1) {
+		full, err := collect(query, filter.Namespace, day.Format(auditDayLayout), filter.Start, upper)
+		if err != nil {
+			return nil, gocql.UUID{}, err
+		}
+		if full {
+			return events, events[len(events)-1].ID, nil
+		}
+	}
+	return events, gocql.UUID{}, nil
+}
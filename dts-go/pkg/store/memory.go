@@ -0,0 +1,384 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+// defaultResourcePool is the capacity the pool of a MemoryStore starts with,
+// the same the available_resources migration seeds Cassandra with.
+var defaultResourcePool = models.Resources{CPU: 100, Memory: 1024000, Storage: 1024000}
+
+// MemoryStore keeps everything in maps guarded by a single mutex. Records are
+// copied in and out, so callers may modify what they pass or get back. It
+// honours the same conditional updates as CassandraStore, which makes it
+// suitable for exercising the services in tests, as the scheduler tests and
+// the pipeline test of the execution service do.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	jobs         map[gocql.UUID]*models.Job
+	namespaces   map[string]*models.Namespace
+	quotas       map[string]models.Quota
+	roleBindings map[string]map[string]*models.RoleBinding
+	auditEvents  []*models.AuditEvent
+
+	executions map[gocql.UUID]*models.Execution
+	attempts   map[gocql.UUID]map[int]*models.ExecutionAttempt
+	logLines   map[gocql.UUID][]*models.ExecutionLogLine
+	heartbeats map[gocql.UUID]*models.ExecutionHeartbeat
+
+	// dispatches holds the dispatch time of running executions by namespace
+	// and idempotency key; hourlyExecutions counts dispatches by namespace
+	// and hour.
+	dispatches       map[string]map[string]time.Time
+	hourlyExecutions map[hourKey]int
+	resourcePool     models.ResourcePool
+	outbox           map[gocql.UUID]*models.OutboxEntry
+}
+
+type hourKey struct {
+	namespace string
+	hour      int64
+}
+
+// NewMemoryStore returns a store holding only the default namespace and the
+// resource pool the migrations seed the other backends with.
+func NewMemoryStore() *MemoryStore {
+	now := time.Now()
+	return &MemoryStore{
+		jobs: make(map[gocql.UUID]*models.Job),
+		namespaces: map[string]*models.Namespace{
+			models.DefaultNamespace: {Name: models.DefaultNamespace, Description: "Default namespace", CreatedAt: now, UpdatedAt: now},
+		},
+		quotas:           make(map[string]models.Quota),
+		roleBindings:     make(map[string]map[string]*models.RoleBinding),
+		executions:       make(map[gocql.UUID]*models.Execution),
+		attempts:         make(map[gocql.UUID]map[int]*models.ExecutionAttempt),
+		logLines:         make(map[gocql.UUID][]*models.ExecutionLogLine),
+		heartbeats:       make(map[gocql.UUID]*models.ExecutionHeartbeat),
+		dispatches:       make(map[string]map[string]time.Time),
+		hourlyExecutions: make(map[hourKey]int),
+		resourcePool: models.ResourcePool{
+			Available:    defaultResourcePool,
+			Reservations: make(map[string]models.Resources),
+		},
+		outbox: make(map[gocql.UUID]*models.OutboxEntry),
+	}
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) Close() {}
+
+// uuidLess orders IDs by their bytes, which is stable but, unlike the time
+// order used for newest-first listings, arbitrary.
+func uuidLess(a, b gocql.UUID) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+// newerFirst orders time-based IDs from newest to oldest.
+func newerFirst(a, b gocql.UUID) bool {
+	if ta, tb := a.Time(), b.Time(); !ta.Equal(tb) {
+		return ta.After(tb)
+	}
+	return uuidLess(b, a)
+}
+
+func copyJob(job *models.Job) *models.Job {
+	c := *job
+	c.Metadata = maps.Clone(job.Metadata)
+	c.TraceContext = maps.Clone(job.TraceContext)
+	return &c
+}
+
+func (s *MemoryStore) CreateJob(ctx context.Context, job *models.Job) error {
+	if err := prepareJob(job); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (s *MemoryStore) GetJob(ctx context.Context, id gocql.UUID) (*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyJob(job), nil
+}
+
+// ListJobs pages through jobs in ID order.
+func (s *MemoryStore) ListJobs(ctx context.Context, namespace string, pageSize int, lastID gocql.UUID, status string) ([]*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matching []*models.Job
+	for _, job := range s.jobs {
+		if job.Namespace != namespace || (status != "" && job.Status != status) {
+			continue
+		}
+		if lastID != (gocql.UUID{}) && !uuidLess(lastID, job.ID) {
+			continue
+		}
+		matching = append(matching, job)
+	}
+	sort.Slice(matching, func(i, j int) bool { return uuidLess(matching[i].ID, matching[j].ID) })
+
+	var jobs []*models.Job
+	for _, job := range matching {
+		if len(jobs) == pageSize {
+			break
+		}
+		jobs = append(jobs, copyJob(job))
+	}
+	return jobs, nil
+}
+
+func (s *MemoryStore) UpdateJob(ctx context.Context, job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := copyJob(job)
+	if existing, ok := s.jobs[job.ID]; ok {
+		updated.NextRun = existing.NextRun
+		updated.Namespace = existing.Namespace
+		updated.CreatedAt = existing.CreatedAt
+		updated.TraceContext = existing.TraceContext
+	}
+	s.jobs[job.ID] = updated
+	return nil
+}
+
+func (s *MemoryStore) SetJobNextRun(ctx context.Context, jobID gocql.UUID, nextRun time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[jobID]; ok {
+		job.NextRun = nextRun
+	}
+	return nil
+}
+
+func (s *MemoryStore) AdvanceJobNextRun(ctx context.Context, job *models.Job, previousNextRun time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.jobs[job.ID]
+	if !ok || !existing.NextRun.Equal(previousNextRun) {
+		return false, nil
+	}
+	existing.Status = job.Status
+	existing.UpdatedAt = job.UpdatedAt
+	existing.NextRun = job.NextRun
+	return true, nil
+}
+
+func (s *MemoryStore) DeleteJob(ctx context.Context, job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, job.ID)
+	return nil
+}
+
+func (s *MemoryStore) GetJobsDueForExecution(ctx context.Context, limit int) ([]*models.Job, error) {
+	now := time.Now().Truncate(time.Minute)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*models.Job
+	for _, job := range s.jobs {
+		if !job.NextRun.After(now) {
+			due = append(due, job)
+		}
+	}
+
+	var jobs []*models.Job
+	for _, job := range firstDueJobs(due, limit) {
+		jobs = append(jobs, copyJob(job))
+	}
+	return jobs, nil
+}
+
+// ReleaseDueJob does nothing: GetJobsDueForExecution takes no claims.
+func (s *MemoryStore) ReleaseDueJob(ctx context.Context, jobID gocql.UUID) error {
+	return nil
+}
+
+func (s *MemoryStore) CountNamespaceJobs(ctx context.Context, namespace string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, job := range s.jobs {
+		if job.Namespace == namespace {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) CreateNamespace(ctx context.Context, namespace *models.Namespace) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.namespaces[namespace.Name]; ok {
+		return false, nil
+	}
+	c := *namespace
+	s.namespaces[namespace.Name] = &c
+	return true, nil
+}
+
+func (s *MemoryStore) GetNamespace(ctx context.Context, name string) (*models.Namespace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	namespace, ok := s.namespaces[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *namespace
+	return &c, nil
+}
+
+func (s *MemoryStore) ListNamespaces(ctx context.Context) ([]*models.Namespace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var namespaces []*models.Namespace
+	for _, namespace := range s.namespaces {
+		c := *namespace
+		namespaces = append(namespaces, &c)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Name < namespaces[j].Name })
+	return namespaces, nil
+}
+
+func (s *MemoryStore) UpdateNamespace(ctx context.Context, namespace *models.Namespace) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.namespaces[namespace.Name]
+	if !ok {
+		return false, nil
+	}
+	existing.Description = namespace.Description
+	existing.UpdatedAt = namespace.UpdatedAt
+	return true, nil
+}
+
+func (s *MemoryStore) DeleteNamespace(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.namespaces, name)
+	delete(s.roleBindings, name)
+	delete(s.quotas, name)
+	return nil
+}
+
+func (s *MemoryStore) GetQuota(ctx context.Context, namespace string, defaults models.Quota) (models.Quota, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quotas[namespace].WithDefaults(defaults), nil
+}
+
+func (s *MemoryStore) SetQuota(ctx context.Context, namespace string, quota models.Quota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotas[namespace] = quota
+	return nil
+}
+
+func (s *MemoryStore) SetRoleBinding(ctx context.Context, binding *models.RoleBinding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bindings, ok := s.roleBindings[binding.Namespace]
+	if !ok {
+		bindings = make(map[string]*models.RoleBinding)
+		s.roleBindings[binding.Namespace] = bindings
+	}
+	c := *binding
+	bindings[binding.Subject] = &c
+	return nil
+}
+
+func (s *MemoryStore) GetRoleBinding(ctx context.Context, namespace, subject string) (*models.RoleBinding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	binding, ok := s.roleBindings[namespace][subject]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *binding
+	return &c, nil
+}
+
+func (s *MemoryStore) ListRoleBindings(ctx context.Context, namespace string) ([]*models.RoleBinding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var bindings []*models.RoleBinding
+	for _, binding := range s.roleBindings[namespace] {
+		c := *binding
+		bindings = append(bindings, &c)
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Subject < bindings[j].Subject })
+	return bindings, nil
+}
+
+func (s *MemoryStore) DeleteRoleBinding(ctx context.Context, namespace, subject string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.roleBindings[namespace][subject]; !ok {
+		return false, nil
+	}
+	delete(s.roleBindings[namespace], subject)
+	return true, nil
+}
+
+func (s *MemoryStore) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *event
+	c.Changes = append([]models.FieldChange(nil), event.Changes...)
+	s.auditEvents = append(s.auditEvents, &c)
+	return nil
+}
+
+func (s *MemoryStore) ListAuditEvents(ctx context.Context, filter models.AuditEventFilter, pageSize int, before gocql.UUID) ([]*models.AuditEvent, gocql.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matching []*models.AuditEvent
+	for _, event := range s.auditEvents {
+		at := event.ID.Time()
+		switch {
+		case event.Namespace != filter.Namespace,
+			filter.JobID != (gocql.UUID{}) && event.JobID != filter.JobID,
+			filter.Actor != "" && event.Actor != filter.Actor,
+			at.Before(filter.Start), at.After(filter.End),
+			before != (gocql.UUID{}) && !newerFirst(before, event.ID):
+			continue
+		}
+		matching = append(matching, event)
+	}
+	sort.Slice(matching, func(i, j int) bool { return newerFirst(matching[i].ID, matching[j].ID) })
+
+	var events []*models.AuditEvent
+	for _, event := range matching {
+		c := *event
+		events = append(events, &c)
+		if len(events) == pageSize {
+			return events, event.ID, nil
+		}
+	}
+	return events, gocql.UUID{}, nil
+}
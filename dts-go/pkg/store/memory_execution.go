@@ -0,0 +1,395 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+func copyExecution(execution *models.Execution) *models.Execution {
+	c := *execution
+	c.Attempts = nil
+	return &c
+}
+
+func (s *MemoryStore) CreateExecution(ctx context.Context, execution *models.Execution) error {
+	if execution.Namespace == "" {
+		execution.Namespace = models.DefaultNamespace
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executions[execution.ID] = copyExecution(execution)
+	return nil
+}
+
+func (s *MemoryStore) GetJobExecution(ctx context.Context, jobID, id gocql.UUID) (*models.Execution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	execution, ok := s.executions[id]
+	if !ok || execution.JobID != jobID {
+		return nil, ErrNotFound
+	}
+	return copyExecution(execution), nil
+}
+
+func (s *MemoryStore) GetExecution(ctx context.Context, namespace string, id gocql.UUID) (*models.Execution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	execution, ok := s.executions[id]
+	if !ok || execution.Namespace != namespace {
+		return nil, ErrNotFound
+	}
+	return copyExecution(execution), nil
+}
+
+// ListExecutions uses the ID of the last execution of a page as the token of
+// the next one.
+func (s *MemoryStore) ListExecutions(ctx context.Context, namespace string, pageSize int, pageToken string, jobID gocql.UUID, status string, lookbackDays int) ([]*models.Execution, string, error) {
+	var after gocql.UUID
+	if pageToken != "" {
+		var err error
+		if after, err = gocql.ParseUUID(pageToken); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+	}
+	oldestDay := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -lookbackDays)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matching []*models.Execution
+	for _, execution := range s.executions {
+		switch {
+		case execution.Namespace != namespace,
+			status != "" && execution.Status != status,
+			pageToken != "" && !newerFirst(after, execution.ID):
+			continue
+		case jobID != (gocql.UUID{}):
+			if execution.JobID != jobID {
+				continue
+			}
+		case execution.ID.Time().Before(oldestDay):
+			continue
+		}
+		matching = append(matching, execution)
+	}
+	sort.Slice(matching, func(i, j int) bool { return newerFirst(matching[i].ID, matching[j].ID) })
+
+	var executions []*models.Execution
+	for _, execution := range matching {
+		if len(executions) == pageSize {
+			return executions, executions[len(executions)-1].ID.String(), nil
+		}
+		executions = append(executions, copyExecution(execution))
+	}
+	return executions, "", nil
+}
+
+func (s *MemoryStore) UpdateExecution(ctx context.Context, execution *models.Execution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.executions[execution.ID]
+	if !ok {
+		s.executions[execution.ID] = copyExecution(execution)
+		return nil
+	}
+	existing.Status = execution.Status
+	existing.EndTime = execution.EndTime
+	existing.Result = execution.Result
+	existing.Error = execution.Error
+	existing.WorkerID = execution.WorkerID
+	existing.AttemptCount = execution.AttemptCount
+	return nil
+}
+
+func (s *MemoryStore) CreateExecutionAttempt(ctx context.Context, attempt *models.ExecutionAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts, ok := s.attempts[attempt.ExecutionID]
+	if !ok {
+		attempts = make(map[int]*models.ExecutionAttempt)
+		s.attempts[attempt.ExecutionID] = attempts
+	}
+	c := *attempt
+	attempts[attempt.Attempt] = &c
+	return nil
+}
+
+func (s *MemoryStore) UpdateExecutionAttempt(ctx context.Context, attempt *models.ExecutionAttempt) error {
+	s.mu.Lock()
+	existing, ok := s.attempts[attempt.ExecutionID][attempt.Attempt]
+	if ok {
+		existing.Status = attempt.Status
+		existing.EndTime = attempt.EndTime
+		existing.Error = attempt.Error
+	}
+	s.mu.Unlock()
+	if !ok {
+		return s.CreateExecutionAttempt(ctx, attempt)
+	}
+	return nil
+}
+
+func (s *MemoryStore) ListExecutionAttempts(ctx context.Context, executionID gocql.UUID) ([]*models.ExecutionAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var attempts []*models.ExecutionAttempt
+	for _, attempt := range s.attempts[executionID] {
+		c := *attempt
+		attempts = append(attempts, &c)
+	}
+	sort.Slice(attempts, func(i, j int) bool { return attempts[i].Attempt < attempts[j].Attempt })
+	return attempts, nil
+}
+
+func (s *MemoryStore) CreateExecutionLogLine(ctx context.Context, line *models.ExecutionLogLine) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *line
+	lines := s.logLines[line.ExecutionID]
+	i := sort.Search(len(lines), func(i int) bool { return lines[i].Seq >= line.Seq })
+	if i < len(lines) && lines[i].Seq == line.Seq {
+		lines[i] = &c
+		return nil
+	}
+	lines = append(lines, nil)
+	copy(lines[i+1:], lines[i:])
+	lines[i] = &c
+	s.logLines[line.ExecutionID] = lines
+	return nil
+}
+
+func (s *MemoryStore) ListExecutionLogLines(ctx context.Context, executionID gocql.UUID, afterSeq int64, limit int) ([]*models.ExecutionLogLine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := s.logLines[executionID]
+	i := sort.Search(len(all), func(i int) bool { return all[i].Seq > afterSeq })
+	var lines []*models.ExecutionLogLine
+	for ; i < len(all) && len(lines) < limit; i++ {
+		c := *all[i]
+		lines = append(lines, &c)
+	}
+	return lines, nil
+}
+
+func (s *MemoryStore) GetLastExecutionLogSeq(ctx context.Context, executionID gocql.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := s.logLines[executionID]
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	return lines[len(lines)-1].Seq, nil
+}
+
+func (s *MemoryStore) CreateHeartbeat(ctx context.Context, heartbeat *models.ExecutionHeartbeat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *heartbeat
+	s.heartbeats[heartbeat.ExecutionID] = &c
+	return nil
+}
+
+func (s *MemoryStore) TouchHeartbeat(ctx context.Context, executionID gocql.UUID, heartbeatAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	heartbeat, ok := s.heartbeats[executionID]
+	if !ok {
+		return false, nil
+	}
+	heartbeat.HeartbeatAt = heartbeatAt
+	return true, nil
+}
+
+func (s *MemoryStore) DeleteHeartbeat(ctx context.Context, executionID gocql.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.heartbeats, executionID)
+	return nil
+}
+
+func (s *MemoryStore) ListHeartbeats(ctx context.Context) ([]*models.ExecutionHeartbeat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var heartbeats []*models.ExecutionHeartbeat
+	for _, heartbeat := range s.heartbeats {
+		c := *heartbeat
+		heartbeats = append(heartbeats, &c)
+	}
+	return heartbeats, nil
+}
+
+func (s *MemoryStore) ClaimStaleHeartbeat(ctx context.Context, heartbeat *models.ExecutionHeartbeat) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.heartbeats[heartbeat.ExecutionID]
+	if !ok || !existing.HeartbeatAt.Equal(heartbeat.HeartbeatAt) {
+		return false, nil
+	}
+	delete(s.heartbeats, heartbeat.ExecutionID)
+	return true, nil
+}
+
+func (s *MemoryStore) AcquireDispatch(ctx context.Context, namespace, idempotencyKey string, jobID gocql.UUID, dispatchedAt time.Time, maxConcurrent int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	running, ok := s.dispatches[namespace]
+	if !ok {
+		running = make(map[string]time.Time)
+		s.dispatches[namespace] = running
+	}
+	if _, held := s.runningDispatches(namespace)[idempotencyKey]; !held && maxConcurrent > 0 && len(running) >= maxConcurrent {
+		return false, nil
+	}
+	running[idempotencyKey] = dispatchedAt
+	s.hourlyExecutions[hourKey{namespace, dispatchedAt.Truncate(time.Hour).Unix()}]++
+	return true, nil
+}
+
+// runningDispatches returns the dispatches of a namespace that still hold a
+// concurrency slot, dropping those older than runningExecutionTTL.
+func (s *MemoryStore) runningDispatches(namespace string) map[string]time.Time {
+	running := s.dispatches[namespace]
+	expired := time.Now().Add(-runningExecutionTTL)
+	maps.DeleteFunc(running, func(_ string, dispatchedAt time.Time) bool {
+		return dispatchedAt.Before(expired)
+	})
+	return running
+}
+
+func (s *MemoryStore) IsDispatchRecorded(ctx context.Context, namespace, idempotencyKey string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.runningDispatches(namespace)[idempotencyKey]
+	return ok, nil
+}
+
+func (s *MemoryStore) ReleaseDispatch(ctx context.Context, namespace, idempotencyKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.dispatches[namespace], idempotencyKey)
+	return nil
+}
+
+func (s *MemoryStore) CountRunningExecutions(ctx context.Context, namespace string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.runningDispatches(namespace)), nil
+}
+
+func (s *MemoryStore) GetHourlyExecutions(ctx context.Context, namespace string, at time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hourlyExecutions[hourKey{namespace, at.Truncate(time.Hour).Unix()}], nil
+}
+
+func (s *MemoryStore) GetResourcePool(ctx context.Context) (*models.ResourcePool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &models.ResourcePool{
+		Available:    s.resourcePool.Available,
+		Reservations: maps.Clone(s.resourcePool.Reservations),
+	}, nil
+}
+
+func (s *MemoryStore) ReserveResources(ctx context.Context, reservationID string, req models.Resources) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pool := &s.resourcePool
+	if _, ok := pool.Reservations[reservationID]; ok {
+		return true, nil
+	}
+	if !req.Fits(pool.Available) {
+		return false, nil
+	}
+	pool.Available = pool.Available.Sub(req)
+	pool.Reservations[reservationID] = req
+	return true, nil
+}
+
+func (s *MemoryStore) ReleaseResources(ctx context.Context, reservationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pool := &s.resourcePool
+	if reserved, ok := pool.Reservations[reservationID]; ok {
+		pool.Available = pool.Available.Add(reserved)
+		delete(pool.Reservations, reservationID)
+	}
+	return nil
+}
+
+func copyOutboxEntry(entry *models.OutboxEntry) *models.OutboxEntry {
+	c := *entry
+	c.TraceContext = maps.Clone(entry.TraceContext)
+	return &c
+}
+
+func (s *MemoryStore) CreateOutboxEntry(ctx context.Context, entry *models.OutboxEntry) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.outbox[entry.ID]; ok {
+		return false, nil
+	}
+	c := copyOutboxEntry(entry)
+	c.ClaimedUntil = time.Unix(0, 0)
+	c.SentAt = nil
+	s.outbox[entry.ID] = c
+	return true, nil
+}
+
+// ListPendingOutboxEntries also drops the entries that were sent longer than
+// outboxSentTTL ago.
+func (s *MemoryStore) ListPendingOutboxEntries(ctx context.Context, shard int, limit int) ([]*models.OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := time.Now().Add(-outboxSentTTL)
+	var pending []*models.OutboxEntry
+	for id, entry := range s.outbox {
+		if entry.SentAt != nil {
+			if entry.SentAt.Before(expired) {
+				delete(s.outbox, id)
+			}
+			continue
+		}
+		if entry.Shard == shard {
+			pending = append(pending, entry)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return uuidLess(pending[i].ID, pending[j].ID) })
+
+	var entries []*models.OutboxEntry
+	for _, entry := range pending {
+		if len(entries) == limit {
+			break
+		}
+		entries = append(entries, copyOutboxEntry(entry))
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) ClaimOutboxEntry(ctx context.Context, entry *models.OutboxEntry, now, leaseUntil time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.outbox[entry.ID]
+	if !ok || !existing.ClaimedUntil.Before(now) {
+		return false, nil
+	}
+	existing.ClaimedUntil = leaseUntil
+	entry.ClaimedUntil = leaseUntil
+	return true, nil
+}
+
+func (s *MemoryStore) MarkOutboxEntrySent(ctx context.Context, entry *models.OutboxEntry, sentAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := copyOutboxEntry(entry)
+	c.SentAt = &sentAt
+	s.outbox[entry.ID] = c
+	entry.SentAt = &sentAt
+	return nil
+}
@@ -0,0 +1,357 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	_ "github.com/lib/pq"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	_ "modernc.org/sqlite"
+)
+
+// dueJobClaimTTL is how long a job returned by GetJobsDueForExecution is kept
+// from other schedulers if it is neither advanced nor released, e.g. because
+// its scheduler died.
+const dueJobClaimTTL = time.Minute
+
+// unclaimed is the claimed_until of jobs and outbox entries no one holds.
+var unclaimed = time.Unix(0, 0)
+
+// sqlDialect is what SQLStore does differently per database.
+type sqlDialect struct {
+	// name is also the directory of the dialect's migrations.
+	name string
+	// lockRows is appended to a SELECT whose rows the transaction is about
+	// to update, and skipLocked to one that may pass over rows another
+	// transaction holds.
+	lockRows   string
+	skipLocked string
+	// arg, if set, converts every statement argument before it is handed to
+	// the driver.
+	arg func(v interface{}) interface{}
+}
+
+var postgresDialect = &sqlDialect{
+	name:       BackendPostgres,
+	lockRows:   " FOR UPDATE",
+	skipLocked: " FOR UPDATE SKIP LOCKED",
+}
+
+// SQLite locks the whole database for the duration of a write transaction,
+// so it has no row locks. It stores times as text, which only compares
+// correctly when every time is in the same zone.
+var sqliteDialect = &sqlDialect{
+	name: BackendSQLite,
+	arg: func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case time.Time:
+			return v.UTC()
+		case *time.Time:
+			if v != nil {
+				return v.UTC()
+			}
+		}
+		return v
+	},
+}
+
+// sqlConn runs statements on a database or a transaction, converting their
+// arguments for the dialect.
+type sqlConn struct {
+	q interface {
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+		QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+		QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	}
+	dialect *sqlDialect
+}
+
+func (c sqlConn) args(args []interface{}) []interface{} {
+	if c.dialect.arg == nil {
+		return args
+	}
+	converted := make([]interface{}, len(args))
+	for i, v := range args {
+		converted[i] = c.dialect.arg(v)
+	}
+	return converted
+}
+
+func (c sqlConn) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.q.ExecContext(ctx, query, c.args(args)...)
+}
+
+func (c sqlConn) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.q.QueryContext(ctx, query, c.args(args)...)
+}
+
+func (c sqlConn) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.q.QueryRowContext(ctx, query, c.args(args)...)
+}
+
+// SQLStore keeps everything in a relational database, Postgres or SQLite,
+// laid out by the migrations in migrations/postgres/ and migrations/sqlite/.
+type SQLStore struct {
+	sqlConn
+	db *sql.DB
+}
+
+// OpenPostgres connects to the Postgres database at dsn. Its schema is
+// managed by cmd/migrate.
+func OpenPostgres(dsn string) (*SQLStore, error) {
+	logger.Info().Msg("Connecting to Postgres")
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		logger.Error().Err(err).Msg("Error connecting to Postgres")
+		return nil, err
+	}
+	return newSQLStore(db, postgresDialect), nil
+}
+
+// OpenSQLite opens the SQLite database file at path, creating it if it does
+// not exist, and brings its schema up to date, so that no separate migration
+// step is needed. Services on the same host may share the file.
+func OpenSQLite(path string) (*SQLStore, error) {
+	logger.Info().Msgf("Opening SQLite database %s", path)
+	// Write transactions take the database lock up front, and writers of
+	// other processes wait for it rather than fail
+	dsn := "file:" + path + "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has a single writer; one connection queues the statements of
+	// this process instead of having them contend for the lock
+	db.SetMaxOpenConns(1)
+
+	s := newSQLStore(db, sqliteDialect)
+	if err := s.Migrate(context.Background()); err != nil {
+		db.Close()
+		logger.Error().Err(err).Msg("Error migrating SQLite database")
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSQLStore(db *sql.DB, dialect *sqlDialect) *SQLStore {
+	return &SQLStore{sqlConn: sqlConn{q: db, dialect: dialect}, db: db}
+}
+
+func (s *SQLStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLStore) Close() {
+	s.db.Close()
+	logger.Info().Str("backend", s.dialect.name).Msg("SQL database closed")
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds.
+func (s *SQLStore) inTx(ctx context.Context, fn func(tx sqlConn) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(sqlConn{q: tx, dialect: s.dialect}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// noRows translates the no rows error of database/sql into ErrNotFound.
+func noRows(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// affected reports whether a statement changed any row.
+func affected(result sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// uuidColumn scans a uuid column into a gocql.UUID.
+type uuidColumn struct {
+	id *gocql.UUID
+}
+
+func (c uuidColumn) Scan(src interface{}) error {
+	var text string
+	switch src := src.(type) {
+	case []byte:
+		text = string(src)
+	case string:
+		text = src
+	default:
+		return fmt.Errorf("cannot scan %T into a UUID", src)
+	}
+	id, err := gocql.ParseUUID(text)
+	if err != nil {
+		return err
+	}
+	*c.id = id
+	return nil
+}
+
+// jsonColumn stores v as JSON, NULL when v marshals to null, and scans it
+// back, leaving v untouched when the column is NULL.
+type jsonColumn struct {
+	v interface{}
+}
+
+func (c jsonColumn) Value() (driver.Value, error) {
+	data, err := json.Marshal(c.v)
+	if err != nil || string(data) == "null" {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c jsonColumn) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(src, c.v)
+	case string:
+		return json.Unmarshal([]byte(src), c.v)
+	default:
+		return fmt.Errorf("cannot scan %T as JSON", src)
+	}
+}
+
+func sqlJobScanDest(j *models.Job) []interface{} {
+	return []interface{}{uuidColumn{&j.ID}, &j.Name, &j.Description, &j.CronExpression, &j.Status, &j.CreatedAt, &j.UpdatedAt, &j.LastRun, &j.NextRun, jsonColumn{&j.Metadata}, &j.Resources.CPU, &j.Resources.Memory, &j.Resources.Storage, &j.Priority, &j.Namespace, jsonColumn{&j.TraceContext}}
+}
+
+const sqlJobColumns = "id, name, description, cron_expression, status, created_at, updated_at, last_run, next_run, metadata, cpu, memory, storage, priority, namespace, trace_context"
+
+func scanJobs(rows *sql.Rows, err error) ([]*models.Job, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []*models.Job
+	for rows.Next() {
+		var job models.Job
+		if err := rows.Scan(sqlJobScanDest(&job)...); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *SQLStore) CreateJob(ctx context.Context, job *models.Job) error {
+	if err := prepareJob(job); err != nil {
+		return err
+	}
+
+	_, err := s.exec(ctx,
+		"INSERT INTO jobs ("+sqlJobColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)",
+		job.ID.String(), job.Name, job.Description, job.CronExpression, job.Status, job.CreatedAt, job.UpdatedAt, job.LastRun, job.NextRun, jsonColumn{job.Metadata}, job.Resources.CPU, job.Resources.Memory, job.Resources.Storage, job.Priority, job.Namespace, jsonColumn{job.TraceContext},
+	)
+	return err
+}
+
+func (s *SQLStore) GetJob(ctx context.Context, id gocql.UUID) (*models.Job, error) {
+	var job models.Job
+	err := s.queryRow(ctx, "SELECT "+sqlJobColumns+" FROM jobs WHERE id = $1", id.String()).Scan(sqlJobScanDest(&job)...)
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return &job, nil
+}
+
+// ListJobs pages through jobs in ID order.
+func (s *SQLStore) ListJobs(ctx context.Context, namespace string, pageSize int, lastID gocql.UUID, status string) ([]*models.Job, error) {
+	if status != "" {
+		return scanJobs(s.query(ctx,
+			"SELECT "+sqlJobColumns+" FROM jobs WHERE namespace = $1 AND status = $2 AND id > $3 ORDER BY id LIMIT $4",
+			namespace, status, lastID.String(), pageSize,
+		))
+	}
+	return scanJobs(s.query(ctx,
+		"SELECT "+sqlJobColumns+" FROM jobs WHERE namespace = $1 AND id > $2 ORDER BY id LIMIT $3",
+		namespace, lastID.String(), pageSize,
+	))
+}
+
+func (s *SQLStore) UpdateJob(ctx context.Context, job *models.Job) error {
+	_, err := s.exec(ctx,
+		"UPDATE jobs SET name = $1, description = $2, cron_expression = $3, status = $4, updated_at = $5, last_run = $6, metadata = $7, cpu = $8, memory = $9, storage = $10, priority = $11 WHERE id = $12",
+		job.Name, job.Description, job.CronExpression, job.Status, job.UpdatedAt, job.LastRun, jsonColumn{job.Metadata}, job.Resources.CPU, job.Resources.Memory, job.Resources.Storage, job.Priority, job.ID.String(),
+	)
+	return err
+}
+
+func (s *SQLStore) SetJobNextRun(ctx context.Context, jobID gocql.UUID, nextRun time.Time) error {
+	_, err := s.exec(ctx, "UPDATE jobs SET next_run = $1, claimed_until = $2 WHERE id = $3", nextRun, unclaimed, jobID.String())
+	return err
+}
+
+// AdvanceJobNextRun also releases the claim GetJobsDueForExecution took on
+// the job.
+func (s *SQLStore) AdvanceJobNextRun(ctx context.Context, job *models.Job, previousNextRun time.Time) (bool, error) {
+	return affected(s.exec(ctx,
+		"UPDATE jobs SET status = $1, updated_at = $2, next_run = $3, claimed_until = $4 WHERE id = $5 AND next_run = $6",
+		job.Status, job.UpdatedAt, job.NextRun, unclaimed, job.ID.String(), previousNextRun,
+	))
+}
+
+func (s *SQLStore) DeleteJob(ctx context.Context, job *models.Job) error {
+	_, err := s.exec(ctx, "DELETE FROM jobs WHERE id = $1", job.ID.String())
+	return err
+}
+
+// GetJobsDueForExecution claims the most urgent due jobs for dueJobClaimTTL.
+// On Postgres rows locked by another scheduler's claim are skipped rather than
+// waited for, so concurrent schedulers get disjoint batches; SQLite runs the
+// claims one after the other under its database lock.
+func (s *SQLStore) GetJobsDueForExecution(ctx context.Context, limit int) ([]*models.Job, error) {
+	now := time.Now()
+	jobs, err := scanJobs(s.query(ctx, `
+		UPDATE jobs SET claimed_until = $1
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE next_run <= $2 AND claimed_until < $3
+			ORDER BY priority DESC, next_run
+			LIMIT $4`+s.dialect.skipLocked+`
+		)
+		RETURNING `+sqlJobColumns,
+		now.Add(dueJobClaimTTL), now.Truncate(time.Minute), now, limit,
+	))
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the order of the subquery
+	return firstDueJobs(jobs, limit), nil
+}
+
+func (s *SQLStore) ReleaseDueJob(ctx context.Context, jobID gocql.UUID) error {
+	_, err := s.exec(ctx, "UPDATE jobs SET claimed_until = $1 WHERE id = $2", unclaimed, jobID.String())
+	return err
+}
+
+func (s *SQLStore) CountNamespaceJobs(ctx context.Context, namespace string) (int, error) {
+	var count int
+	err := s.queryRow(ctx, "SELECT COUNT(*) FROM jobs WHERE namespace = $1", namespace).Scan(&count)
+	return count, err
+}
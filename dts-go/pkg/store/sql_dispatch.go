@@ -0,0 +1,253 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+// AcquireDispatch serializes the dispatches of a namespace on its row in
+// namespace_dispatch_locks, so the running dispatches it counts cannot change
+// until it commits. It also drops the dispatches of the namespace that have
+// outlived runningExecutionTTL, which Cassandra expires on its own.
+func (s *SQLStore) AcquireDispatch(ctx context.Context, namespace, idempotencyKey string, jobID gocql.UUID, dispatchedAt time.Time, maxConcurrent int) (bool, error) {
+	acquired := false
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		// The upsert locks the row even when it already exists
+		_, err := tx.exec(ctx, `
+			INSERT INTO namespace_dispatch_locks (namespace) VALUES ($1)
+			ON CONFLICT (namespace) DO UPDATE SET namespace = EXCLUDED.namespace`,
+			namespace,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, `DELETE FROM namespace_running_executions WHERE namespace = $1 AND dispatched_at < $2`, namespace, time.Now().Add(-runningExecutionTTL))
+		if err != nil {
+			return err
+		}
+		if maxConcurrent > 0 {
+			var running int
+			err := tx.queryRow(ctx,
+				`SELECT COUNT(*) FROM namespace_running_executions WHERE namespace = $1 AND idempotency_key <> $2`,
+				namespace, idempotencyKey,
+			).Scan(&running)
+			if err != nil {
+				return err
+			}
+			if running >= maxConcurrent {
+				return nil
+			}
+		}
+		_, err = tx.exec(ctx, `
+			INSERT INTO namespace_running_executions (namespace, idempotency_key, job_id, dispatched_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (namespace, idempotency_key) DO UPDATE SET job_id = EXCLUDED.job_id, dispatched_at = EXCLUDED.dispatched_at`,
+			namespace, idempotencyKey, jobID.String(), dispatchedAt,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, `
+			INSERT INTO namespace_hourly_executions (namespace, hour, executions) VALUES ($1, $2, 1)
+			ON CONFLICT (namespace, hour) DO UPDATE SET executions = namespace_hourly_executions.executions + 1`,
+			namespace, dispatchedAt.UTC().Truncate(time.Hour),
+		)
+		acquired = err == nil
+		return err
+	})
+	return acquired, err
+}
+
+func (s *SQLStore) IsDispatchRecorded(ctx context.Context, namespace, idempotencyKey string) (bool, error) {
+	var recorded bool
+	err := s.queryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM namespace_running_executions WHERE namespace = $1 AND idempotency_key = $2 AND dispatched_at >= $3)`,
+		namespace, idempotencyKey, time.Now().Add(-runningExecutionTTL),
+	).Scan(&recorded)
+	return recorded, err
+}
+
+func (s *SQLStore) ReleaseDispatch(ctx context.Context, namespace, idempotencyKey string) error {
+	_, err := s.exec(ctx, `DELETE FROM namespace_running_executions WHERE namespace = $1 AND idempotency_key = $2`, namespace, idempotencyKey)
+	return err
+}
+
+func (s *SQLStore) CountRunningExecutions(ctx context.Context, namespace string) (int, error) {
+	var count int
+	err := s.queryRow(ctx,
+		`SELECT COUNT(*) FROM namespace_running_executions WHERE namespace = $1 AND dispatched_at >= $2`,
+		namespace, time.Now().Add(-runningExecutionTTL),
+	).Scan(&count)
+	return count, err
+}
+
+func (s *SQLStore) GetHourlyExecutions(ctx context.Context, namespace string, at time.Time) (int, error) {
+	var count int
+	err := s.queryRow(ctx,
+		`SELECT executions FROM namespace_hourly_executions WHERE namespace = $1 AND hour = $2`,
+		namespace, at.UTC().Truncate(time.Hour),
+	).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (s *SQLStore) GetResourcePool(ctx context.Context) (*models.ResourcePool, error) {
+	var pool models.ResourcePool
+	query := `SELECT cpu, memory, storage FROM available_resources WHERE id = $1`
+	if err := s.queryRow(ctx, query, globalResourcePool).Scan(&pool.Available.CPU, &pool.Available.Memory, &pool.Available.Storage); err != nil {
+		return nil, noRows(err)
+	}
+
+	rows, err := s.query(ctx, `SELECT id, cpu, memory, storage FROM resource_reservations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pool.Reservations = make(map[string]models.Resources)
+	for rows.Next() {
+		var id string
+		var r models.Resources
+		if err := rows.Scan(&id, &r.CPU, &r.Memory, &r.Storage); err != nil {
+			return nil, err
+		}
+		pool.Reservations[id] = r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+// lockResourcePool locks the pool row for the rest of tx and returns its
+// remaining capacity.
+func lockResourcePool(ctx context.Context, tx sqlConn) (models.Resources, error) {
+	var available models.Resources
+	query := `SELECT cpu, memory, storage FROM available_resources WHERE id = $1` + tx.dialect.lockRows
+	err := tx.queryRow(ctx, query, globalResourcePool).Scan(&available.CPU, &available.Memory, &available.Storage)
+	return available, noRows(err)
+}
+
+// getReservation returns the capacity held by reservationID, reporting false
+// if it holds none.
+func getReservation(ctx context.Context, tx sqlConn, reservationID string) (models.Resources, bool, error) {
+	var reserved models.Resources
+	query := `SELECT cpu, memory, storage FROM resource_reservations WHERE id = $1`
+	err := tx.queryRow(ctx, query, reservationID).Scan(&reserved.CPU, &reserved.Memory, &reserved.Storage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Resources{}, false, nil
+	}
+	return reserved, err == nil, err
+}
+
+func setResourcePool(ctx context.Context, tx sqlConn, available models.Resources) error {
+	query := `UPDATE available_resources SET cpu = $1, memory = $2, storage = $3 WHERE id = $4`
+	_, err := tx.exec(ctx, query, available.CPU, available.Memory, available.Storage, globalResourcePool)
+	return err
+}
+
+// ReserveResources holds a lock on the pool row while it takes the capacity.
+func (s *SQLStore) ReserveResources(ctx context.Context, reservationID string, req models.Resources) (bool, error) {
+	reserved := false
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		available, err := lockResourcePool(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if _, ok, err := getReservation(ctx, tx, reservationID); err != nil || ok {
+			reserved = ok
+			return err
+		}
+		if !req.Fits(available) {
+			return nil
+		}
+
+		if err := setResourcePool(ctx, tx, available.Sub(req)); err != nil {
+			return err
+		}
+		query := `INSERT INTO resource_reservations (id, cpu, memory, storage) VALUES ($1, $2, $3, $4)`
+		if _, err := tx.exec(ctx, query, reservationID, req.CPU, req.Memory, req.Storage); err != nil {
+			return err
+		}
+		reserved = true
+		return nil
+	})
+	return reserved, err
+}
+
+func (s *SQLStore) ReleaseResources(ctx context.Context, reservationID string) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		available, err := lockResourcePool(ctx, tx)
+		if err != nil {
+			return err
+		}
+		reserved, ok, err := getReservation(ctx, tx, reservationID)
+		if err != nil || !ok {
+			return err
+		}
+
+		if err := setResourcePool(ctx, tx, available.Add(reserved)); err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, `DELETE FROM resource_reservations WHERE id = $1`, reservationID)
+		return err
+	})
+}
+
+const sqlOutboxColumns = `shard, id, topic, message_key, payload, created_at, claimed_until, sent_at, trace_context`
+
+func (s *SQLStore) CreateOutboxEntry(ctx context.Context, entry *models.OutboxEntry) (bool, error) {
+	return affected(s.exec(ctx,
+		`INSERT INTO outbox (shard, id, topic, message_key, payload, created_at, claimed_until, trace_context) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (id) DO NOTHING`,
+		entry.Shard, entry.ID.String(), entry.Topic, entry.Key, entry.Payload, entry.CreatedAt, unclaimed, jsonColumn{entry.TraceContext},
+	))
+}
+
+// ListPendingOutboxEntries also deletes the entries that were sent longer
+// than outboxSentTTL ago.
+func (s *SQLStore) ListPendingOutboxEntries(ctx context.Context, shard int, limit int) ([]*models.OutboxEntry, error) {
+	if _, err := s.exec(ctx, `DELETE FROM outbox WHERE sent_at < $1`, time.Now().Add(-outboxSentTTL)); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + sqlOutboxColumns + ` FROM outbox WHERE shard = $1 AND sent_at IS NULL ORDER BY id LIMIT $2`
+	rows, err := s.query(ctx, query, shard, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []*models.OutboxEntry
+	for rows.Next() {
+		var entry models.OutboxEntry
+		if err := rows.Scan(&entry.Shard, uuidColumn{&entry.ID}, &entry.Topic, &entry.Key, &entry.Payload, &entry.CreatedAt, &entry.ClaimedUntil, &entry.SentAt, jsonColumn{&entry.TraceContext}); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *SQLStore) ClaimOutboxEntry(ctx context.Context, entry *models.OutboxEntry, now, leaseUntil time.Time) (bool, error) {
+	claimed, err := affected(s.exec(ctx,
+		`UPDATE outbox SET claimed_until = $1 WHERE id = $2 AND claimed_until < $3`,
+		leaseUntil, entry.ID.String(), now,
+	))
+	if err != nil || !claimed {
+		return claimed, err
+	}
+	entry.ClaimedUntil = leaseUntil
+	return true, nil
+}
+
+func (s *SQLStore) MarkOutboxEntrySent(ctx context.Context, entry *models.OutboxEntry, sentAt time.Time) error {
+	if _, err := s.exec(ctx, `UPDATE outbox SET sent_at = $1 WHERE id = $2`, sentAt, entry.ID.String()); err != nil {
+		return err
+	}
+	entry.SentAt = &sentAt
+	return nil
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+const sqlExecutionColumns = `id, job_id, status, start_time, end_time, result, error, worker_id, attempts, scheduled_time, trigger_source, namespace`
+
+func sqlExecutionScanDest(e *models.Execution) []interface{} {
+	return []interface{}{uuidColumn{&e.ID}, uuidColumn{&e.JobID}, &e.Status, &e.StartTime, &e.EndTime, &e.Result, &e.Error, &e.WorkerID, &e.AttemptCount, &e.ScheduledTime, &e.TriggerSource, &e.Namespace}
+}
+
+func (s *SQLStore) CreateExecution(ctx context.Context, execution *models.Execution) error {
+	if execution.Namespace == "" {
+		execution.Namespace = models.DefaultNamespace
+	}
+	_, err := s.exec(ctx,
+		`INSERT INTO job_executions (`+sqlExecutionColumns+`, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		execution.ID.String(), execution.JobID.String(), execution.Status, execution.StartTime, execution.EndTime, execution.Result, execution.Error, execution.WorkerID, execution.AttemptCount, execution.ScheduledTime, execution.TriggerSource, execution.Namespace, execution.ID.Time(),
+	)
+	return err
+}
+
+func (s *SQLStore) GetJobExecution(ctx context.Context, jobID, id gocql.UUID) (*models.Execution, error) {
+	var execution models.Execution
+	query := `SELECT ` + sqlExecutionColumns + ` FROM job_executions WHERE job_id = $1 AND id = $2`
+	if err := s.queryRow(ctx, query, jobID.String(), id.String()).Scan(sqlExecutionScanDest(&execution)...); err != nil {
+		return nil, noRows(err)
+	}
+	return &execution, nil
+}
+
+func (s *SQLStore) GetExecution(ctx context.Context, namespace string, id gocql.UUID) (*models.Execution, error) {
+	var execution models.Execution
+	query := `SELECT ` + sqlExecutionColumns + ` FROM job_executions WHERE namespace = $1 AND id = $2`
+	if err := s.queryRow(ctx, query, namespace, id.String()).Scan(sqlExecutionScanDest(&execution)...); err != nil {
+		return nil, noRows(err)
+	}
+	return &execution, nil
+}
+
+// ListExecutions uses the ID of the last execution of a page as the token of
+// the next one.
+func (s *SQLStore) ListExecutions(ctx context.Context, namespace string, pageSize int, pageToken string, jobID gocql.UUID, status string, lookbackDays int) ([]*models.Execution, string, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where = append(where, "namespace = "+arg(namespace))
+	if status != "" {
+		where = append(where, "status = "+arg(status))
+	}
+	if jobID != (gocql.UUID{}) {
+		where = append(where, "job_id = "+arg(jobID.String()))
+	} else {
+		oldestDay := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -lookbackDays)
+		where = append(where, "created_at >= "+arg(oldestDay))
+	}
+	if pageToken != "" {
+		after, err := gocql.ParseUUID(pageToken)
+		if err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+		where = append(where, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(after.Time()), arg(after.String())))
+	}
+	// One more than a page tells whether there is a next one
+	query := `SELECT ` + sqlExecutionColumns + ` FROM job_executions WHERE ` + strings.Join(where, " AND ") +
+		` ORDER BY created_at DESC, id DESC LIMIT ` + arg(pageSize+1)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	var executions []*models.Execution
+	for rows.Next() {
+		var execution models.Execution
+		if err := rows.Scan(sqlExecutionScanDest(&execution)...); err != nil {
+			return nil, "", err
+		}
+		executions = append(executions, &execution)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	if len(executions) > pageSize {
+		executions = executions[:pageSize]
+		return executions, executions[pageSize-1].ID.String(), nil
+	}
+	return executions, "", nil
+}
+
+func (s *SQLStore) UpdateExecution(ctx context.Context, execution *models.Execution) error {
+	_, err := s.exec(ctx,
+		`UPDATE job_executions SET status = $1, end_time = $2, result = $3, error = $4, worker_id = $5, attempts = $6 WHERE id = $7`,
+		execution.Status, execution.EndTime, execution.Result, execution.Error, execution.WorkerID, execution.AttemptCount, execution.ID.String(),
+	)
+	return err
+}
+
+func (s *SQLStore) CreateExecutionAttempt(ctx context.Context, attempt *models.ExecutionAttempt) error {
+	_, err := s.exec(ctx, `
+		INSERT INTO execution_attempts (execution_id, attempt, job_id, status, start_time, end_time, worker_id, error) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (execution_id, attempt) DO UPDATE SET
+			job_id = EXCLUDED.job_id, status = EXCLUDED.status, start_time = EXCLUDED.start_time,
+			end_time = EXCLUDED.end_time, worker_id = EXCLUDED.worker_id, error = EXCLUDED.error`,
+		attempt.ExecutionID.String(), attempt.Attempt, attempt.JobID.String(), attempt.Status, attempt.StartTime, attempt.EndTime, attempt.WorkerID, attempt.Error,
+	)
+	return err
+}
+
+func (s *SQLStore) UpdateExecutionAttempt(ctx context.Context, attempt *models.ExecutionAttempt) error {
+	_, err := s.exec(ctx,
+		`UPDATE execution_attempts SET status = $1, end_time = $2, error = $3 WHERE execution_id = $4 AND attempt = $5`,
+		attempt.Status, attempt.EndTime, attempt.Error, attempt.ExecutionID.String(), attempt.Attempt,
+	)
+	return err
+}
+
+func (s *SQLStore) ListExecutionAttempts(ctx context.Context, executionID gocql.UUID) ([]*models.ExecutionAttempt, error) {
+	query := `SELECT execution_id, attempt, job_id, status, start_time, end_time, worker_id, error FROM execution_attempts WHERE execution_id = $1 ORDER BY attempt`
+	rows, err := s.query(ctx, query, executionID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var attempts []*models.ExecutionAttempt
+	for rows.Next() {
+		var attempt models.ExecutionAttempt
+		if err := rows.Scan(uuidColumn{&attempt.ExecutionID}, &attempt.Attempt, uuidColumn{&attempt.JobID}, &attempt.Status, &attempt.StartTime, &attempt.EndTime, &attempt.WorkerID, &attempt.Error); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, &attempt)
+	}
+	return attempts, rows.Err()
+}
+
+func (s *SQLStore) CreateExecutionLogLine(ctx context.Context, line *models.ExecutionLogLine) error {
+	_, err := s.exec(ctx, `
+		INSERT INTO execution_logs (execution_id, seq, stream, timestamp, line) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (execution_id, seq) DO UPDATE SET stream = EXCLUDED.stream, timestamp = EXCLUDED.timestamp, line = EXCLUDED.line`,
+		line.ExecutionID.String(), line.Seq, line.Stream, line.Timestamp, line.Line,
+	)
+	return err
+}
+
+func (s *SQLStore) ListExecutionLogLines(ctx context.Context, executionID gocql.UUID, afterSeq int64, limit int) ([]*models.ExecutionLogLine, error) {
+	query := `SELECT execution_id, seq, stream, timestamp, line FROM execution_logs WHERE execution_id = $1 AND seq > $2 ORDER BY seq LIMIT $3`
+	rows, err := s.query(ctx, query, executionID.String(), afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lines []*models.ExecutionLogLine
+	for rows.Next() {
+		var line models.ExecutionLogLine
+		if err := rows.Scan(uuidColumn{&line.ExecutionID}, &line.Seq, &line.Stream, &line.Timestamp, &line.Line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, &line)
+	}
+	return lines, rows.Err()
+}
+
+func (s *SQLStore) GetLastExecutionLogSeq(ctx context.Context, executionID gocql.UUID) (int64, error) {
+	var lastSeq int64
+	err := s.queryRow(ctx, `SELECT COALESCE(MAX(seq), 0) FROM execution_logs WHERE execution_id = $1`, executionID.String()).Scan(&lastSeq)
+	return lastSeq, err
+}
+
+func (s *SQLStore) CreateHeartbeat(ctx context.Context, heartbeat *models.ExecutionHeartbeat) error {
+	_, err := s.exec(ctx, `
+		INSERT INTO execution_heartbeats (execution_id, job_id, worker_id, idempotency_key, retry_count, start_time, heartbeat_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (execution_id) DO UPDATE SET
+			job_id = EXCLUDED.job_id, worker_id = EXCLUDED.worker_id, idempotency_key = EXCLUDED.idempotency_key,
+			retry_count = EXCLUDED.retry_count, start_time = EXCLUDED.start_time, heartbeat_at = EXCLUDED.heartbeat_at`,
+		heartbeat.ExecutionID.String(), heartbeat.JobID.String(), heartbeat.WorkerID, heartbeat.IdempotencyKey, heartbeat.RetryCount, heartbeat.StartTime, heartbeat.HeartbeatAt,
+	)
+	return err
+}
+
+func (s *SQLStore) TouchHeartbeat(ctx context.Context, executionID gocql.UUID, heartbeatAt time.Time) (bool, error) {
+	return affected(s.exec(ctx, `UPDATE execution_heartbeats SET heartbeat_at = $1 WHERE execution_id = $2`, heartbeatAt, executionID.String()))
+}
+
+func (s *SQLStore) DeleteHeartbeat(ctx context.Context, executionID gocql.UUID) error {
+	_, err := s.exec(ctx, `DELETE FROM execution_heartbeats WHERE execution_id = $1`, executionID.String())
+	return err
+}
+
+func (s *SQLStore) ListHeartbeats(ctx context.Context) ([]*models.ExecutionHeartbeat, error) {
+	query := `SELECT execution_id, job_id, worker_id, idempotency_key, retry_count, start_time, heartbeat_at FROM execution_heartbeats`
+	rows, err := s.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var heartbeats []*models.ExecutionHeartbeat
+	for rows.Next() {
+		var heartbeat models.ExecutionHeartbeat
+		if err := rows.Scan(uuidColumn{&heartbeat.ExecutionID}, uuidColumn{&heartbeat.JobID}, &heartbeat.WorkerID, &heartbeat.IdempotencyKey, &heartbeat.RetryCount, &heartbeat.StartTime, &heartbeat.HeartbeatAt); err != nil {
+			return nil, err
+		}
+		heartbeats = append(heartbeats, &heartbeat)
+	}
+	return heartbeats, rows.Err()
+}
+
+func (s *SQLStore) ClaimStaleHeartbeat(ctx context.Context, heartbeat *models.ExecutionHeartbeat) (bool, error) {
+	return affected(s.exec(ctx,
+		`DELETE FROM execution_heartbeats WHERE execution_id = $1 AND heartbeat_at = $2`,
+		heartbeat.ExecutionID.String(), heartbeat.HeartbeatAt,
+	))
+}
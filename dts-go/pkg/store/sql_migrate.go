@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nedson202/dts-go/migrations"
+	"github.com/nedson202/dts-go/pkg/logger"
+)
+
+// Migrate applies the .sql files of the dialect's migrations directory that
+// have not been applied yet, in name order. Each file runs in a transaction
+// together with its record in schema_migrations, so a failed migration leaves
+// nothing behind and stops the run.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	_, err := s.exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			id text PRIMARY KEY,
+			applied_at timestamp NOT NULL
+		)`)
+	if err != nil {
+		return err
+	}
+
+	files, err := fs.ReadDir(migrations.SQL, s.dialect.name)
+	if err != nil {
+		return err
+	}
+	var migrationFiles []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".sql") {
+			migrationFiles = append(migrationFiles, file.Name())
+		}
+	}
+	sort.Strings(migrationFiles)
+
+	for _, file := range migrationFiles {
+		if err := s.applyMigration(ctx, file); err != nil {
+			return fmt.Errorf("migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) applyMigration(ctx context.Context, filename string) error {
+	content, err := fs.ReadFile(migrations.SQL, path.Join(s.dialect.name, filename))
+	if err != nil {
+		return err
+	}
+
+	applied := false
+	err = s.inTx(ctx, func(tx sqlConn) error {
+		var count int
+		if err := tx.queryRow(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE id = $1`, filename).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if _, err := tx.exec(ctx, string(content)); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, `INSERT INTO schema_migrations (id, applied_at) VALUES ($1, $2)`, filename, time.Now().UTC()); err != nil {
+			return err
+		}
+		applied = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if applied {
+		logger.Info().Msgf("Successfully applied migration: %s", filename)
+	} else {
+		logger.Debug().Msgf("Migration %s has already been applied, skipping", filename)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/models"
+)
+
+func (s *SQLStore) CreateNamespace(ctx context.Context, namespace *models.Namespace) (bool, error) {
+	return affected(s.exec(ctx,
+		`INSERT INTO namespaces (name, description, created_at, updated_at) VALUES ($1, $2, $3, $4) ON CONFLICT (name) DO NOTHING`,
+		namespace.Name, namespace.Description, namespace.CreatedAt, namespace.UpdatedAt,
+	))
+}
+
+func (s *SQLStore) GetNamespace(ctx context.Context, name string) (*models.Namespace, error) {
+	var namespace models.Namespace
+	query := `SELECT name, description, created_at, updated_at FROM namespaces WHERE name = $1`
+	if err := s.queryRow(ctx, query, name).Scan(&namespace.Name, &namespace.Description, &namespace.CreatedAt, &namespace.UpdatedAt); err != nil {
+		return nil, noRows(err)
+	}
+	return &namespace, nil
+}
+
+func (s *SQLStore) ListNamespaces(ctx context.Context) ([]*models.Namespace, error) {
+	rows, err := s.query(ctx, `SELECT name, description, created_at, updated_at FROM namespaces ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var namespaces []*models.Namespace
+	for rows.Next() {
+		var namespace models.Namespace
+		if err := rows.Scan(&namespace.Name, &namespace.Description, &namespace.CreatedAt, &namespace.UpdatedAt); err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, &namespace)
+	}
+	return namespaces, rows.Err()
+}
+
+func (s *SQLStore) UpdateNamespace(ctx context.Context, namespace *models.Namespace) (bool, error) {
+	return affected(s.exec(ctx,
+		`UPDATE namespaces SET description = $1, updated_at = $2 WHERE name = $3`,
+		namespace.Description, namespace.UpdatedAt, namespace.Name,
+	))
+}
+
+func (s *SQLStore) DeleteNamespace(ctx context.Context, name string) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		for _, query := range []string{
+			`DELETE FROM namespaces WHERE name = $1`,
+			`DELETE FROM role_bindings WHERE namespace = $1`,
+			`DELETE FROM namespace_quotas WHERE namespace = $1`,
+		} {
+			if _, err := tx.exec(ctx, query, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) GetQuota(ctx context.Context, namespace string, defaults models.Quota) (models.Quota, error) {
+	var quota models.Quota
+	var minIntervalSeconds int
+	query := `SELECT max_jobs, min_schedule_interval_seconds, max_concurrent_executions, max_executions_per_hour FROM namespace_quotas WHERE namespace = $1`
+	err := s.queryRow(ctx, query, namespace).Scan(&quota.MaxJobs, &minIntervalSeconds, &quota.MaxConcurrentExecutions, &quota.MaxExecutionsPerHour)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.Quota{}, err
+	}
+	quota.MinScheduleInterval = time.Duration(minIntervalSeconds) * time.Second
+	return quota.WithDefaults(defaults), nil
+}
+
+func (s *SQLStore) SetQuota(ctx context.Context, namespace string, quota models.Quota) error {
+	_, err := s.exec(ctx, `
+		INSERT INTO namespace_quotas (namespace, max_jobs, min_schedule_interval_seconds, max_concurrent_executions, max_executions_per_hour)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (namespace) DO UPDATE SET
+			max_jobs = EXCLUDED.max_jobs,
+			min_schedule_interval_seconds = EXCLUDED.min_schedule_interval_seconds,
+			max_concurrent_executions = EXCLUDED.max_concurrent_executions,
+			max_executions_per_hour = EXCLUDED.max_executions_per_hour`,
+		namespace, quota.MaxJobs, int(quota.MinScheduleInterval/time.Second), quota.MaxConcurrentExecutions, quota.MaxExecutionsPerHour,
+	)
+	return err
+}
+
+func (s *SQLStore) SetRoleBinding(ctx context.Context, binding *models.RoleBinding) error {
+	_, err := s.exec(ctx, `
+		INSERT INTO role_bindings (namespace, subject, role, created_at, created_by) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (namespace, subject) DO UPDATE SET role = EXCLUDED.role, created_at = EXCLUDED.created_at, created_by = EXCLUDED.created_by`,
+		binding.Namespace, binding.Subject, binding.Role, binding.CreatedAt, binding.CreatedBy,
+	)
+	return err
+}
+
+func (s *SQLStore) GetRoleBinding(ctx context.Context, namespace, subject string) (*models.RoleBinding, error) {
+	binding := models.RoleBinding{Namespace: namespace, Subject: subject}
+	query := `SELECT role, created_at, created_by FROM role_bindings WHERE namespace = $1 AND subject = $2`
+	if err := s.queryRow(ctx, query, namespace, subject).Scan(&binding.Role, &binding.CreatedAt, &binding.CreatedBy); err != nil {
+		return nil, noRows(err)
+	}
+	return &binding, nil
+}
+
+func (s *SQLStore) ListRoleBindings(ctx context.Context, namespace string) ([]*models.RoleBinding, error) {
+	query := `SELECT subject, role, created_at, created_by FROM role_bindings WHERE namespace = $1 ORDER BY subject`
+	rows, err := s.query(ctx, query, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var bindings []*models.RoleBinding
+	for rows.Next() {
+		binding := models.RoleBinding{Namespace: namespace}
+		if err := rows.Scan(&binding.Subject, &binding.Role, &binding.CreatedAt, &binding.CreatedBy); err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, &binding)
+	}
+	return bindings, rows.Err()
+}
+
+func (s *SQLStore) DeleteRoleBinding(ctx context.Context, namespace, subject string) (bool, error) {
+	return affected(s.exec(ctx, `DELETE FROM role_bindings WHERE namespace = $1 AND subject = $2`, namespace, subject))
+}
+
+func (s *SQLStore) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO audit_events (id, namespace, created_at, job_id, action, actor, request_id, changes) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		event.ID.String(), event.Namespace, event.ID.Time(), event.JobID.String(), event.Action, event.Actor, event.RequestID, jsonColumn{event.Changes},
+	)
+	return err
+}
+
+func (s *SQLStore) ListAuditEvents(ctx context.Context, filter models.AuditEventFilter, pageSize int, before gocql.UUID) ([]*models.AuditEvent, gocql.UUID, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where = append(where, "namespace = "+arg(filter.Namespace), "created_at >= "+arg(filter.Start), "created_at <= "+arg(filter.End))
+	if filter.JobID != (gocql.UUID{}) {
+		where = append(where, "job_id = "+arg(filter.JobID.String()))
+	}
+	if filter.Actor != "" {
+		where = append(where, "actor = "+arg(filter.Actor))
+	}
+	if before != (gocql.UUID{}) {
+		where = append(where, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(before.Time()), arg(before.String())))
+	}
+	query := `SELECT id, namespace, job_id, action, actor, request_id, changes FROM audit_events WHERE ` + strings.Join(where, " AND ") +
+		` ORDER BY created_at DESC, id DESC LIMIT ` + arg(pageSize)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, gocql.UUID{}, err
+	}
+	defer rows.Close()
+	var events []*models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := rows.Scan(uuidColumn{&event.ID}, &event.Namespace, uuidColumn{&event.JobID}, &event.Action, &event.Actor, &event.RequestID, jsonColumn{&event.Changes}); err != nil {
+			return nil, gocql.UUID{}, err
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, gocql.UUID{}, err
+	}
+	if len(events) == pageSize {
+		return events, events[len(events)-1].ID, nil
+	}
+	return events, gocql.UUID{}, nil
+}
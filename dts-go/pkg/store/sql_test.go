@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/models"
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+)
+
+// openTestSQLite opens a fresh SQLite database with the schema applied.
+func openTestSQLite(t *testing.T) *SQLStore {
+	t.Helper()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "dts.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestSQLiteHasDefaultNamespace(t *testing.T) {
+	db := openTestSQLite(t)
+	if _, err := db.GetNamespace(context.Background(), models.DefaultNamespace); err != nil {
+		t.Fatalf("getting the default namespace of a fresh database: %v", err)
+	}
+}
+
+// createDueJob stores a job with the given priority that became due dueAgo
+// before now.
+func createDueJob(t *testing.T, db *SQLStore, name string, priority int, dueAgo time.Duration) *models.Job {
+	t.Helper()
+	ctx := context.Background()
+	job := &models.Job{ID: gocql.TimeUUID(), Name: name, CronExpression: "0 3 * * *", Status: pb.JobStatus_PENDING.String(), Priority: priority}
+	if err := db.CreateJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	job.NextRun = time.Now().Add(-dueAgo).Truncate(time.Second)
+	if err := db.SetJobNextRun(ctx, job.ID, job.NextRun); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// claimDue returns the names of the jobs GetJobsDueForExecution claims.
+func claimDue(t *testing.T, db *SQLStore, limit int) []string {
+	t.Helper()
+	jobs, err := db.GetJobsDueForExecution(context.Background(), limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		names[i] = job.Name
+	}
+	return names
+}
+
+func TestSQLiteDueJobsInPriorityOrder(t *testing.T) {
+	db := openTestSQLite(t)
+	createDueJob(t, db, "low", 0, 10*time.Minute)
+	createDueJob(t, db, "high-recent", 5, 2*time.Minute)
+	createDueJob(t, db, "high-overdue", 5, 5*time.Minute)
+	createDueJob(t, db, "normal", 1, 20*time.Minute)
+	createDueJob(t, db, "not-due", 9, -time.Hour)
+
+	if got, want := claimDue(t, db, 3), []string{"high-overdue", "high-recent", "normal"}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := claimDue(t, db, 3), []string{"low"}; !slices.Equal(got, want) {
+		t.Fatalf("second batch: got %v, want %v", got, want)
+	}
+}
+
+func TestSQLiteClaimedJobsAreSkipped(t *testing.T) {
+	db := openTestSQLite(t)
+	for i := 0; i < 20; i++ {
+		createDueJob(t, db, fmt.Sprintf("job-%d", i), i%3, time.Duration(i+2)*time.Minute)
+	}
+
+	// Schedulers claiming at the same time get disjoint batches
+	batches := make([][]string, 4)
+	var wg sync.WaitGroup
+	for i := range batches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jobs, err := db.GetJobsDueForExecution(context.Background(), 5)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for _, job := range jobs {
+				batches[i] = append(batches[i], job.Name)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	claimed := map[string]bool{}
+	for _, batch := range batches {
+		for _, name := range batch {
+			if claimed[name] {
+				t.Fatalf("%s was claimed twice", name)
+			}
+			claimed[name] = true
+		}
+	}
+	if len(claimed) != 20 {
+		t.Fatalf("got %d jobs claimed, want all 20", len(claimed))
+	}
+	if got := claimDue(t, db, 5); len(got) != 0 {
+		t.Fatalf("claimed %v again while their claims were held", got)
+	}
+}
+
+func TestSQLiteClaimExpires(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+	job := createDueJob(t, db, "orphaned", 0, 2*time.Minute)
+
+	before := time.Now()
+	claimDue(t, db, 10)
+	after := time.Now()
+	var claimedUntil time.Time
+	if err := db.queryRow(ctx, "SELECT claimed_until FROM jobs WHERE id = $1", job.ID.String()).Scan(&claimedUntil); err != nil {
+		t.Fatal(err)
+	}
+	if claimedUntil.Before(before.Add(dueJobClaimTTL).Truncate(time.Second)) || claimedUntil.After(after.Add(dueJobClaimTTL)) {
+		t.Fatalf("claimed until %v, want %v after the claim", claimedUntil, dueJobClaimTTL)
+	}
+	if got := claimDue(t, db, 10); len(got) != 0 {
+		t.Fatalf("claimed %v again within the claim TTL", got)
+	}
+
+	// The scheduler holding the claim died; once the TTL has passed another
+	// one picks the job up
+	if _, err := db.exec(ctx, "UPDATE jobs SET claimed_until = $1 WHERE id = $2", time.Now().Add(-time.Second), job.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := claimDue(t, db, 10), []string{"orphaned"}; !slices.Equal(got, want) {
+		t.Fatalf("after the claim expired: got %v, want %v", got, want)
+	}
+}
+
+func TestSQLiteReleaseDueJob(t *testing.T) {
+	db := openTestSQLite(t)
+	job := createDueJob(t, db, "deferred", 0, 2*time.Minute)
+
+	claimDue(t, db, 10)
+	if err := db.ReleaseDueJob(context.Background(), job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := claimDue(t, db, 10), []string{"deferred"}; !slices.Equal(got, want) {
+		t.Fatalf("after the release: got %v, want %v", got, want)
+	}
+}
+
+func TestSQLiteAdvanceJobNextRun(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+	job := createDueJob(t, db, "recurring", 0, 2*time.Minute)
+
+	claimed, err := db.GetJobsDueForExecution(ctx, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("got %d jobs, %v, want the due one", len(claimed), err)
+	}
+	previousNextRun := claimed[0].NextRun
+
+	// Another scheduler advanced the job first
+	stale := *claimed[0]
+	stale.NextRun = previousNextRun.Add(time.Hour)
+	if advanced, err := db.AdvanceJobNextRun(ctx, &stale, previousNextRun.Add(-time.Minute)); err != nil || advanced {
+		t.Fatalf("advancing from a next run the job no longer has: got %v, %v, want false", advanced, err)
+	}
+
+	// Advancing it to a run that is already due again releases the claim
+	next := *claimed[0]
+	next.NextRun = previousNextRun.Add(time.Second)
+	if advanced, err := db.AdvanceJobNextRun(ctx, &next, previousNextRun); err != nil || !advanced {
+		t.Fatalf("got %v, %v, want the job advanced", advanced, err)
+	}
+	if got, want := claimDue(t, db, 10), []string{"recurring"}; !slices.Equal(got, want) {
+		t.Fatalf("after advancing: got %v, want %v", got, want)
+	}
+	stored, err := db.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.NextRun.Equal(next.NextRun) {
+		t.Fatalf("got next run %v, want %v", stored.NextRun, next.NextRun)
+	}
+}
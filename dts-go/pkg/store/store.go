@@ -0,0 +1,272 @@
+// Package store persists jobs, executions and the state the services keep
+// around them.
+//
+// The services depend only on the JobStore and ExecutionStore interfaces.
+// CassandraStore and SQLStore are the production implementations;
+// MemoryStore keeps everything in the process, for tests and for trying out a
+// single service without a database.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/nedson202/dts-go/pkg/config"
+	"github.com/nedson202/dts-go/pkg/logger"
+	"github.com/nedson202/dts-go/pkg/models"
+	"github.com/nedson202/dts-go/pkg/utils"
+	pb "github.com/nedson202/dts-go/proto/job/v1"
+)
+
+const (
+	BackendCassandra = "cassandra"
+	BackendPostgres  = "postgres"
+	BackendSQLite    = "sqlite"
+	BackendMemory    = "memory"
+)
+
+// ErrNotFound is returned when a requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidPageToken is returned when a page token was not produced by
+// ListExecutions.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// JobStore persists jobs and the namespaces they are organised in, along with
+// the quotas, role bindings and audit log of those namespaces.
+type JobStore interface {
+	// CreateJob inserts a job, defaulting its status to PENDING and its
+	// namespace to the default one, and computes its first next run.
+	CreateJob(ctx context.Context, job *models.Job) error
+	GetJob(ctx context.Context, id gocql.UUID) (*models.Job, error)
+	// ListJobs returns up to pageSize jobs of a namespace after lastID, in a
+	// stable order, optionally only those with the given status.
+	ListJobs(ctx context.Context, namespace string, pageSize int, lastID gocql.UUID, status string) ([]*models.Job, error)
+	// UpdateJob writes the mutable fields of a job. It leaves next run alone:
+	// the scheduler owns its advancement (see AdvanceJobNextRun) and a stale
+	// read here must not roll it back. Use SetJobNextRun when the schedule
+	// itself changes.
+	UpdateJob(ctx context.Context, job *models.Job) error
+	// SetJobNextRun unconditionally overwrites the next run of a job, e.g.
+	// after its cron expression has been changed.
+	SetJobNextRun(ctx context.Context, jobID gocql.UUID, nextRun time.Time) error
+	// AdvanceJobNextRun saves the status of a job and moves its next run to
+	// job.NextRun, but only if the next run still equals previousNextRun. It
+	// reports false if another scheduler advanced the job first or its
+	// schedule was changed.
+	AdvanceJobNextRun(ctx context.Context, job *models.Job, previousNextRun time.Time) (bool, error)
+	DeleteJob(ctx context.Context, job *models.Job) error
+	// GetJobsDueForExecution returns up to limit jobs whose next run is at or
+	// before the current minute, highest priority first and among equals the
+	// ones that have been due longest, so that a full batch leaves out the
+	// least urgent jobs. Backends that can may claim the returned jobs
+	// until they are advanced, so that concurrent schedulers are handed
+	// different jobs.
+	GetJobsDueForExecution(ctx context.Context, limit int) ([]*models.Job, error)
+	// ReleaseDueJob gives up the claim GetJobsDueForExecution took on a job
+	// that was left due, e.g. because it was deferred, so that the next pass
+	// of any scheduler picks it up again. Backends without claims do nothing.
+	ReleaseDueJob(ctx context.Context, jobID gocql.UUID) error
+	CountNamespaceJobs(ctx context.Context, namespace string) (int, error)
+
+	// CreateNamespace inserts a namespace. It reports false if one with the
+	// same name already exists.
+	CreateNamespace(ctx context.Context, namespace *models.Namespace) (bool, error)
+	GetNamespace(ctx context.Context, name string) (*models.Namespace, error)
+	ListNamespaces(ctx context.Context) ([]*models.Namespace, error)
+	// UpdateNamespace saves the description of a namespace. It reports false
+	// if the namespace does not exist.
+	UpdateNamespace(ctx context.Context, namespace *models.Namespace) (bool, error)
+	// DeleteNamespace removes a namespace together with its quota and role
+	// bindings.
+	DeleteNamespace(ctx context.Context, name string) error
+
+	// GetQuota returns the effective quota of a namespace: its stored limits
+	// with unset ones taken from defaults.
+	GetQuota(ctx context.Context, namespace string, defaults models.Quota) (models.Quota, error)
+	SetQuota(ctx context.Context, namespace string, quota models.Quota) error
+
+	// SetRoleBinding saves a binding, replacing the role the subject had in
+	// the namespace.
+	SetRoleBinding(ctx context.Context, binding *models.RoleBinding) error
+	GetRoleBinding(ctx context.Context, namespace, subject string) (*models.RoleBinding, error)
+	ListRoleBindings(ctx context.Context, namespace string) ([]*models.RoleBinding, error)
+	// DeleteRoleBinding removes a binding. It reports false if the subject had
+	// no role in the namespace.
+	DeleteRoleBinding(ctx context.Context, namespace, subject string) (bool, error)
+
+	// CreateAuditEvent appends an event to the audit log.
+	CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	// ListAuditEvents returns up to pageSize matching events, newest first,
+	// starting after the event ID before when it is set. The returned cursor
+	// is the ID to continue from, or the zero UUID after the last page.
+	ListAuditEvents(ctx context.Context, filter models.AuditEventFilter, pageSize int, before gocql.UUID) ([]*models.AuditEvent, gocql.UUID, error)
+}
+
+// ExecutionStore persists the runs of jobs and what it takes to dispatch
+// them: the outbox of dispatch messages, the quota accounting of dispatches
+// and the shared resource pool.
+type ExecutionStore interface {
+	CreateExecution(ctx context.Context, execution *models.Execution) error
+	// GetJobExecution looks up an execution by the job it belongs to and its
+	// ID.
+	GetJobExecution(ctx context.Context, jobID, id gocql.UUID) (*models.Execution, error)
+	// GetExecution looks up an execution of a namespace by ID. Executions of
+	// other namespaces are not found.
+	GetExecution(ctx context.Context, namespace string, id gocql.UUID) (*models.Execution, error)
+	// ListExecutions returns a page of executions of a namespace, newest
+	// first, along with the token for the next page, which is empty after the
+	// last one. When jobID is set only that job's executions are listed, and
+	// callers must check that the job belongs to the namespace; otherwise
+	// executions older than lookbackDays are left out.
+	ListExecutions(ctx context.Context, namespace string, pageSize int, pageToken string, jobID gocql.UUID, status string, lookbackDays int) ([]*models.Execution, string, error)
+	UpdateExecution(ctx context.Context, execution *models.Execution) error
+
+	CreateExecutionAttempt(ctx context.Context, attempt *models.ExecutionAttempt) error
+	UpdateExecutionAttempt(ctx context.Context, attempt *models.ExecutionAttempt) error
+	ListExecutionAttempts(ctx context.Context, executionID gocql.UUID) ([]*models.ExecutionAttempt, error)
+
+	CreateExecutionLogLine(ctx context.Context, line *models.ExecutionLogLine) error
+	// ListExecutionLogLines returns up to limit log lines of an execution with
+	// a sequence number greater than afterSeq, in order.
+	ListExecutionLogLines(ctx context.Context, executionID gocql.UUID, afterSeq int64, limit int) ([]*models.ExecutionLogLine, error)
+	// GetLastExecutionLogSeq returns the highest sequence number logged for an
+	// execution, or 0 if nothing has been logged yet.
+	GetLastExecutionLogSeq(ctx context.Context, executionID gocql.UUID) (int64, error)
+
+	CreateHeartbeat(ctx context.Context, heartbeat *models.ExecutionHeartbeat) error
+	// TouchHeartbeat refreshes the heartbeat of a running execution. It
+	// reports false if the heartbeat no longer exists, i.e. the execution has
+	// been reaped.
+	TouchHeartbeat(ctx context.Context, executionID gocql.UUID, heartbeatAt time.Time) (bool, error)
+	DeleteHeartbeat(ctx context.Context, executionID gocql.UUID) error
+	ListHeartbeats(ctx context.Context) ([]*models.ExecutionHeartbeat, error)
+	// ClaimStaleHeartbeat removes a heartbeat only if it has not been
+	// refreshed since it was read, so that exactly one reaper takes ownership
+	// of it.
+	ClaimStaleHeartbeat(ctx context.Context, heartbeat *models.ExecutionHeartbeat) (bool, error)
+
+	// AcquireDispatch counts a dispatch against the concurrency and hourly
+	// quotas of its namespace, but only if the namespace holds fewer than
+	// maxConcurrent concurrency slots; zero means no limit. The check and the
+	// record are one atomic step, so concurrent schedulers cannot together
+	// exceed the limit. It reports false if the namespace is at its limit.
+	// Callers should acquire a dispatch once: acquiring the same idempotency
+	// key again counts it again against the hourly quota.
+	AcquireDispatch(ctx context.Context, namespace, idempotencyKey string, jobID gocql.UUID, dispatchedAt time.Time, maxConcurrent int) (bool, error)
+	// IsDispatchRecorded reports whether a dispatch still holds a concurrency
+	// slot.
+	IsDispatchRecorded(ctx context.Context, namespace, idempotencyKey string) (bool, error)
+	// ReleaseDispatch frees the concurrency slot taken by a dispatch once its
+	// execution is over.
+	ReleaseDispatch(ctx context.Context, namespace, idempotencyKey string) error
+	CountRunningExecutions(ctx context.Context, namespace string) (int, error)
+	// GetHourlyExecutions returns how many dispatches of a namespace were
+	// recorded in the hour of at.
+	GetHourlyExecutions(ctx context.Context, namespace string, at time.Time) (int, error)
+
+	GetResourcePool(ctx context.Context) (*models.ResourcePool, error)
+	// ReserveResources takes req out of the pool under reservationID. It
+	// reports false if the pool does not currently have enough capacity.
+	// Reserving an id that already holds a reservation succeeds without
+	// taking capacity again.
+	ReserveResources(ctx context.Context, reservationID string, req models.Resources) (bool, error)
+	// ReleaseResources returns the capacity held by reservationID to the pool.
+	// It is a no-op if the reservation does not exist.
+	ReleaseResources(ctx context.Context, reservationID string) error
+
+	// CreateOutboxEntry records a message to publish. Entry IDs are derived
+	// from what they dispatch, so writing the same entry twice, even after it
+	// has been sent, leaves a single entry. It reports whether the entry was
+	// newly created.
+	CreateOutboxEntry(ctx context.Context, entry *models.OutboxEntry) (bool, error)
+	// ListPendingOutboxEntries returns up to limit entries of a shard that
+	// have not been sent yet.
+	ListPendingOutboxEntries(ctx context.Context, shard int, limit int) ([]*models.OutboxEntry, error)
+	// ClaimOutboxEntry takes a lease on an entry until leaseUntil. It reports
+	// false if another relay holds an unexpired lease on it.
+	ClaimOutboxEntry(ctx context.Context, entry *models.OutboxEntry, now, leaseUntil time.Time) (bool, error)
+	// MarkOutboxEntrySent records that an entry has been published. Sent
+	// entries are eventually removed.
+	MarkOutboxEntrySent(ctx context.Context, entry *models.OutboxEntry, sentAt time.Time) error
+}
+
+// Store is a complete storage backend.
+type Store interface {
+	JobStore
+	ExecutionStore
+	// Ping checks that the backend can still be reached.
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// Open connects to the storage backend selected by cfg.
+func Open(cfg *config.Config) (Store, error) {
+	switch cfg.StorageBackend {
+	case BackendCassandra:
+		return OpenCassandra(cfg.CassandraHosts, cfg.CassandraKeyspace)
+	case BackendPostgres:
+		return OpenPostgres(cfg.PostgresDSN)
+	case BackendSQLite:
+		return OpenSQLite(cfg.SQLitePath)
+	case BackendMemory:
+		logger.Warn().Msg("Using the in-memory store; nothing is persisted or shared with other services")
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
+
+// GetQuotaUsage returns what the jobs of a namespace currently use of its
+// quota.
+func GetQuotaUsage(ctx context.Context, jobs JobStore, executions ExecutionStore, namespace string, at time.Time) (models.QuotaUsage, error) {
+	var usage models.QuotaUsage
+	var err error
+	if usage.Jobs, err = jobs.CountNamespaceJobs(ctx, namespace); err != nil {
+		return models.QuotaUsage{}, err
+	}
+	if usage.ConcurrentExecutions, err = executions.CountRunningExecutions(ctx, namespace); err != nil {
+		return models.QuotaUsage{}, err
+	}
+	if usage.ExecutionsThisHour, err = executions.GetHourlyExecutions(ctx, namespace, at); err != nil {
+		return models.QuotaUsage{}, err
+	}
+	return usage, nil
+}
+
+// firstDueJobs orders due jobs the way GetJobsDueForExecution returns them
+// and keeps the first limit of them.
+func firstDueJobs(jobs []*models.Job, limit int) []*models.Job {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].Priority != jobs[j].Priority {
+			return jobs[i].Priority > jobs[j].Priority
+		}
+		return jobs[i].NextRun.Before(jobs[j].NextRun)
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
+
+// prepareJob fills in the defaults of a job about to be created and computes
+// its first next run.
+func prepareJob(job *models.Job) error {
+	if job.Status == pb.JobStatus_UNSPECIFIED.String() {
+		job.Status = pb.JobStatus_PENDING.String()
+	}
+	nextRun, err := utils.CalculateNextRun(job.CronExpression, time.Now())
+	if err != nil {
+		logger.Error().Err(err).Msgf("Error calculating next run time for job %s", job.ID)
+		return err
+	}
+	job.NextRun = nextRun
+	if job.Namespace == "" {
+		job.Namespace = models.DefaultNamespace
+	}
+	return nil
+}
@@ -28,3 +28,27 @@ func ValidateCronExpression(expression string) error {
 	}
 	return nil
 }
+
+// MinScheduleInterval returns the shortest gap between consecutive runs of a
+// cron expression among the first samples runs after from.
+func MinScheduleInterval(cronExpression string, from time.Time, samples int) (time.Duration, error) {
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	schedule, err := parser.Parse(cronExpression)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse cron expression: %w", err)
+	}
+
+	var minInterval time.Duration
+	previous := schedule.Next(from)
+	for i := 0; i < samples; i++ {
+		next := schedule.Next(previous)
+		if next.IsZero() {
+			break
+		}
+		if interval := next.Sub(previous); minInterval == 0 || interval < minInterval {
+			minInterval = interval
+		}
+		previous = next
+	}
+	return minInterval, nil
+}
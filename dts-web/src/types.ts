@@ -12,6 +12,8 @@ export interface Job {
     metadata: Record<string, string>;
     nextRun: string;
     lastRun: string | null;
+    resources?: Resources;
+    namespace?: string;
 }
 
 export interface Resources {
@@ -27,14 +29,48 @@ export interface ScheduledJob {
     nextExecutionTime: string;
 }
 
+export type ExecutionStatus =
+    | 'UNSPECIFIED'
+    | 'QUEUED'
+    | 'RUNNING'
+    | 'SUCCEEDED'
+    | 'FAILED'
+    | 'TIMED_OUT'
+    | 'CANCELLED'
+    | 'SKIPPED'
+    | 'LOST';
+
+export interface ExecutionAttempt {
+    attempt: number;
+    status: ExecutionStatus;
+    startTime: string;
+    endTime?: string;
+    workerId?: string;
+    error?: string;
+}
+
 export interface Execution {
     id: string;
     jobId: string;
-    status: string;
+    status: ExecutionStatus;
     startTime: string;
     endTime?: string;
     result?: string;
     error?: string;
+    duration?: string;
+    attempt?: number;
+    workerId?: string;
+    scheduledTime?: string;
+    triggerSource?: string;
+    attempts?: ExecutionAttempt[];
+    namespace?: string;
+}
+
+export interface Namespace {
+    name: string;
+    description?: string;
+    createdAt: string;
+    updatedAt: string;
 }
 
 export type JobEdit = Omit<Job, 'createdAt' | 'updatedAt' | 'lastRun' | 'nextRun' | 'status'>;